@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -38,8 +39,14 @@ func (app *Application) CreateAccount(c *gin.Context) {
 	}
 
 	formattedTime := time.Now().Format(time.RFC3339)
+	owner := payload.HolderId
+	if LogInUser, ok := c.Get("user"); ok {
+		if Entity, ok := LogInUser.(db.AuthenticatedEntity); ok {
+			owner = Entity.GetID()
+		}
+	}
 	acc := db.Account{
-		Id:       uuid.New().String(),
+		Id:       newRequestID(c, owner),
 		HolderId: payload.HolderId,
 		Type:     payload.Type,
 		Amount:   payload.Amount,
@@ -48,6 +55,10 @@ func (app *Application) CreateAccount(c *gin.Context) {
 	// open the account for the user
 	err := app.store.TransactionInterface.OpenAccount(ctx, &acc)
 	if err != nil {
+		if errors.Is(err, db.ErrDuplicateTx) {
+			c.JSON(http.StatusConflict, WriteError("account already opened"))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError(err.Error()))
 		return
 	}
@@ -166,6 +177,10 @@ func (app *Application) DeleteUserAccount(c *gin.Context) {
 	// disable the user account
 	err = app.store.TransactionInterface.DeleteAccount(ctx, acc_id)
 	if err != nil {
+		if errors.Is(err, db.ErrAccountHistory) {
+			c.JSON(http.StatusConflict, WriteError("account has transactions, disable it instead"))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("server error"))
 		return
 	}
@@ -225,22 +240,31 @@ func (app *Application) WithdrawBalance(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, WriteError("account deactivated/not avaialable"))
 		return
 	}
-	tx := db.Transaction{
-		Id:       uuid.NewString(),
-		FromId:   accountID,
-		ToId:     accountID,
-		Currency: payload.Currency,
-		Amount:   payload.Amount,
-		Type:     "withdraw",
+	// withdrawals need admin approval, the amount is held until then
+	request := db.Withdrawal{
+		Id:        newRequestID(c, User.Id),
+		AccountId: accountID,
+		HolderId:  User.Id,
+		Currency:  payload.Currency,
+		Amount:    payload.Amount,
 	}
-	err = app.store.TransactionInterface.Withdraw(ctx, &tx)
+	err = app.store.WithdrawalInterface.RequestWithdrawal(ctx, &request)
 	if err != nil {
+		if errors.Is(err, db.ErrInsufficientFund) {
+			c.JSON(http.StatusBadRequest, WriteError("insufficient balance"))
+			return
+		}
+		if errors.Is(err, db.ErrDuplicateTx) {
+			c.JSON(http.StatusConflict, WriteError("withdrawal already requested"))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError(err.Error()))
 		return
 	}
 	// update the cache
 	app.cache.UpdateUserBalance(ctx, User.GetID(), -payload.Amount)
-	c.JSON(http.StatusOK, WriteResponse("Withdraw successful."))
+	go app.notifyWithdrawal(User.Email, request)
+	c.JSON(http.StatusAccepted, WriteResponse(request))
 
 }
 
@@ -275,7 +299,7 @@ func (app *Application) DepositBalance(c *gin.Context) {
 	}
 
 	tx := db.Transaction{
-		Id:       uuid.NewString(),
+		Id:       newRequestID(c, User.Id),
 		FromId:   accountID,
 		ToId:     accountID,
 		Currency: payload.Currency,
@@ -284,6 +308,10 @@ func (app *Application) DepositBalance(c *gin.Context) {
 	}
 	err = app.store.TransactionInterface.Deposit(ctx, &tx)
 	if err != nil {
+		if errors.Is(err, db.ErrDuplicateTx) {
+			c.JSON(http.StatusConflict, WriteError("deposit already processed"))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError(err.Error()))
 		return
 	}
@@ -291,3 +319,63 @@ func (app *Application) DepositBalance(c *gin.Context) {
 	app.cache.UpdateUserBalance(ctx, User.GetID(), payload.Amount)
 	c.JSON(http.StatusOK, WriteResponse("Deposit successful."))
 }
+
+func (app *Application) GetAccountLedger(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	User, ok := LogInUser.(*db.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	acc_id := c.Param("acc_id")
+	if ok := uuid.Validate(acc_id); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid credentials"))
+		return
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+
+	acc, err := app.store.TransactionInterface.GetAccount(ctx, acc_id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid credentials"))
+		return
+	}
+	// check if the user is the owner of the account
+	if acc.HolderId != User.Id && User.Role != "admin" {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorized request"))
+		return
+	}
+
+	lines, err := app.store.TransactionInterface.GetStatement(ctx, acc_id, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(lines))
+}
+
+// lists the accounts whose balance does not match the ledger (admin only)
+func (app *Application) ReconcileAccounts(c *gin.Context) {
+	ctx := c.Request.Context()
+	mismatches, err := app.store.TransactionInterface.ReconcileAccounts(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(mismatches))
+}
@@ -6,6 +6,7 @@ package api
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -87,6 +88,11 @@ func (app *Application) CreateApplication(c *gin.Context) {
 	// Create a Database entry for the application made
 	err := app.store.ApplicationInterface.CreateApplication(ctx, appl)
 	if err != nil {
+		if errors.Is(err, db.ErrIneligible) {
+			// tell the creator which rule they failed
+			c.JSON(http.StatusForbidden, WriteError(err.Error()))
+			return
+		}
 		log.Printf("error creating application: %v", err.Error())
 		c.JSON(http.StatusInternalServerError, WriteError("server error"))
 		return
@@ -218,6 +224,10 @@ func (app *Application) AcceptApplication(c *gin.Context) {
 		return
 	}
 	if err = app.setApplicationStatus(ctx, applID, db.AcceptedStatus); err != nil {
+		if errors.Is(err, db.ErrCreatorLimit) {
+			c.JSON(http.StatusConflict, WriteError(err.Error()))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("server error. try again"))
 		return
 	}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/cache"
+	"github.com/gin-gonic/gin"
+)
+
+// lists the batch updates that ran out of retries (admin only)
+func (app *Application) GetDeadLetters(c *gin.Context) {
+	ctx := c.Request.Context()
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	cursor := c.Query("cursor") // id of the last dead letter seen
+	if cursor != "" && !cache.ValidStreamID(cursor) {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+
+	letters, err := app.cache.GetDeadBatchUpdates(ctx, cursor, int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+	total, err := app.cache.GetDeadLetterLength(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(map[string]any{
+		"total":        total,
+		"dead_letters": letters,
+	}))
+}
+
+// puts a dead letter back on the batch queue (admin only)
+func (app *Application) ReplayDeadLetter(c *gin.Context) {
+	ctx := c.Request.Context()
+	ID := c.Param("letter_id")
+	if !cache.ValidStreamID(ID) {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+
+	err := app.cache.ReplayDeadBatchUpdate(ctx, ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, cache.ErrDeadLetterNotFound):
+			c.JSON(http.StatusNotFound, WriteError("dead letter not found"))
+		case errors.Is(err, cache.ErrMalformedUpdate):
+			c.JSON(http.StatusUnprocessableEntity, WriteError("malformed update cannot be replayed"))
+		default:
+			c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse("update queued for replay"))
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// returns the eligibility rules of a campaign so creators know why
+// they can or cannot apply
+func (app *Application) GetCampaignEligibility(c *gin.Context) {
+	ctx := c.Request.Context()
+	ID := c.Param("campaign_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	rules, err := app.store.CampaignInterace.GetCampaignEligibility(ctx, ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(rules))
+}
+
+// replaces the eligibility rules of a campaign, an empty body opens it to everyone
+func (app *Application) SetCampaignEligibility(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("campaign_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	var payload db.Eligibility
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError(err.Error()))
+		return
+	}
+	campaign, err := app.store.CampaignInterace.GetCampaign(ctx, ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, WriteError("campaign not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	if campaign.BrandId != Entity.GetID() && Entity.GetRole() != "admin" {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	if campaign.Status == db.ExpiredStatus {
+		c.JSON(http.StatusConflict, WriteError("campaign has ended"))
+		return
+	}
+	err = app.store.CampaignInterace.SetCampaignEligibility(ctx, ID, &payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrInvalidArgs):
+			c.JSON(http.StatusBadRequest, WriteError(err.Error()))
+		case errors.Is(err, db.ErrNotFound):
+			c.JSON(http.StatusNotFound, WriteError("campaign not found"))
+		default:
+			log.Printf("error setting campaign eligibility: %v", err.Error())
+			c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		}
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(&payload))
+}
@@ -1,13 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/Alter-Sitanshu/campaignHub/internals/chats"
 	"github.com/Alter-Sitanshu/campaignHub/internals/db"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,13 +21,24 @@ const (
 type CampaignPayload struct {
 	BrandId string  `json:"brand_id" binding:"required"`
 	Title   string  `json:"title" binding:"required"`
-	Budget  float64 `json:"budget" binding:"required"`
+	Budget  float64 `json:"budget" binding:"required,gte=1000"`
 	CPM     float64 `json:"cpm" binding:"required"`
 	Req     string  `json:"requirements" binding:"required"`
 	// added this to segregate the campaigns on the basis of platform
 	Platform string `json:"platform"`
 	DocLink  string `json:"doc_link" binding:"required"`
-	Status   *int   `json:"status" binding:"required,oneof=0 1 3"`
+	Status   *int   `json:"status" binding:"required,oneof=0 1 2"`
+	// optional schedule, the scheduler activates and ends the campaign on time
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	// optional rules on which creators see and can apply to the campaign
+	Eligibility *db.Eligibility `json:"eligibility"`
+	// optional tiered CPM, milestone bonuses and flat fee
+	PayoutRules []db.PayoutRule `json:"payout_rules"`
+	// optional payout limits, unlimited when left out
+	MaxCreatorEarnings *float64 `json:"max_creator_earnings" binding:"omitempty,gt=0"`
+	MaxPaidViews       *int     `json:"max_paid_views" binding:"omitempty,gt=0"`
+	MaxCreators        *int     `json:"max_creators" binding:"omitempty,gt=0"`
 }
 
 type Meta struct {
@@ -37,6 +49,11 @@ type FeedResponse struct {
 	Campaigns []db.CampaignResp `json:"campaigns"`
 	Meta      Meta              `json:"meta"`
 }
+type SearchResponse struct {
+	Campaigns []db.CampaignResp `json:"campaigns"`
+	Facets    db.SearchFacets   `json:"facets"`
+	Meta      Meta              `json:"meta"`
+}
 
 func (app *Application) CreateCampaign(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -63,6 +80,14 @@ func (app *Application) CreateCampaign(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
 		return
 	}
+	if !validSchedule(payload.StartsAt, payload.EndsAt) {
+		c.JSON(http.StatusBadRequest, WriteError("invalid campaign schedule"))
+		return
+	}
+	// a campaign goes live only after an admin reviewed it
+	if *payload.Status == db.ActiveStatus {
+		*payload.Status = db.PendingReviewStatus
+	}
 	// making the payload
 	campaign := db.Campaign{
 		Id:       uuid.New().String(),
@@ -74,9 +99,26 @@ func (app *Application) CreateCampaign(c *gin.Context) {
 		Platform: payload.Platform,
 		DocLink:  payload.DocLink,
 		Status:   *payload.Status,
+		StartsAt: payload.StartsAt,
+		EndsAt:   payload.EndsAt,
+
+		Eligibility: payload.Eligibility,
+		PayoutRules: payload.PayoutRules,
+		CampaignCaps: db.CampaignCaps{
+			MaxCreatorEarnings: payload.MaxCreatorEarnings,
+			MaxPaidViews:       payload.MaxPaidViews,
+			MaxCreators:        payload.MaxCreators,
+		},
 	}
 	err := app.store.CampaignInterace.LaunchCampaign(ctx, &campaign)
 	if err != nil {
+		if escrowFailed(c, err) || transitionFailed(c, err) {
+			return
+		}
+		if errors.Is(err, db.ErrInvalidArgs) {
+			c.JSON(http.StatusBadRequest, WriteError(err.Error()))
+			return
+		}
 		log.Printf("error campaign: %v", err.Error())
 		c.JSON(http.StatusInternalServerError, WriteError("server error"))
 		return
@@ -152,16 +194,56 @@ func (app *Application) ActivateCampaign(c *gin.Context) {
 	}
 
 	// activate the campaign
-	if err := app.store.CampaignInterace.ActivateCampaign(ctx, ID); err != nil {
+	if err := app.transitionCampaign(ctx, Entity, ID, db.ActiveStatus, ""); err != nil {
+		if escrowFailed(c, err) || transitionFailed(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
 		return
 	}
-	// validate the active campaign
-	app.cache.AddActiveCampaign(ctx, campaign.Id)
 	// successfully activated the campaign
 	c.JSON(http.StatusNoContent, WriteResponse("campaign activated"))
 }
 
+// sends a draft campaign to the admin review
+func (app *Application) SubmitCampaign(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("campaign_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	campaign, err := app.store.CampaignInterace.GetCampaign(ctx, ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	if campaign.BrandId != Entity.GetID() {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+
+	if err := app.transitionCampaign(ctx, Entity, ID, db.PendingReviewStatus, ""); err != nil {
+		if transitionFailed(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	// successfully submitted the campaign
+	c.JSON(http.StatusNoContent, WriteResponse("campaign submitted for review"))
+}
+
 func (app *Application) StopApplications(c *gin.Context) {
 	ctx := c.Request.Context()
 	LogInUser, ok := c.Get("user")
@@ -227,22 +309,30 @@ func (app *Application) StopCampaign(c *gin.Context) {
 		return
 	}
 
-	// delete the campaign
-	if err := app.store.CampaignInterace.EndCampaign(ctx, ID); err != nil {
+	// end the campaign, the conversations close with it
+	if err := app.transitionCampaign(ctx, Entity, ID, db.ExpiredStatus, ""); err != nil {
+		if transitionFailed(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
 		return
 	}
-	// invalidate the active campaign
-	app.cache.RemoveActiveCampaign(ctx, campaign.Id)
-	go app.handleCampaignConversation(&chats.Conversation{
-		CampaignID: &campaign.Id,
-	}, db.ExpiredStatus)
 	// successfully deleted the campaign
 	c.JSON(http.StatusNoContent, WriteResponse("campaign ended"))
 }
 
 func (app *Application) DeleteCampaign(c *gin.Context) {
 	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
 
 	ID := c.Param("campaign_id")
 	if ok := uuid.Validate(ID); ok != nil {
@@ -255,8 +345,17 @@ func (app *Application) DeleteCampaign(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
 		return
 	}
+	// deleting releases the escrow, only the owning brand or an admin may
+	if campaign.BrandId != Entity.GetID() && Entity.GetRole() != "admin" {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
 	// delete the campaign
 	if err := app.store.CampaignInterace.DeleteCampaign(ctx, ID); err != nil {
+		if errors.Is(err, db.ErrUnsettledCampaign) {
+			c.JSON(http.StatusConflict, WriteError(err.Error()))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
 		return
 	}
@@ -364,8 +463,39 @@ func (app *Application) UpdateCampaign(c *gin.Context) {
 	}
 
 	// update request
+	if payload.Budget != nil && *payload.Budget < 0 {
+		c.JSON(http.StatusBadRequest, WriteError("invalid parameters"))
+		return
+	}
+	if (payload.MaxCreatorEarnings != nil && *payload.MaxCreatorEarnings < 0) ||
+		(payload.MaxPaidViews != nil && *payload.MaxPaidViews < 0) ||
+		(payload.MaxCreators != nil && *payload.MaxCreators < 0) {
+		c.JSON(http.StatusBadRequest, WriteError("invalid parameters"))
+		return
+	}
+	if payload.StartsAt != nil || payload.EndsAt != nil {
+		// only a campaign that has not started yet can move its start
+		if payload.StartsAt != nil && campaign.Status != db.DraftStatus {
+			c.JSON(http.StatusBadRequest, WriteError("campaign already started"))
+			return
+		}
+		startsAt, endsAt := campaign.StartsAt, campaign.EndsAt
+		if payload.StartsAt != nil {
+			startsAt = payload.StartsAt
+		}
+		if payload.EndsAt != nil {
+			endsAt = payload.EndsAt
+		}
+		if !validSchedule(startsAt, endsAt) {
+			c.JSON(http.StatusBadRequest, WriteError("invalid campaign schedule"))
+			return
+		}
+	}
 	err = app.store.CampaignInterace.UpdateCampaign(ctx, campaign_id, payload)
 	if err != nil {
+		if escrowFailed(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("server error"))
 		return
 	}
@@ -388,30 +518,81 @@ func (app *Application) GetCampaignFeed(c *gin.Context) {
 	}
 	Entity, _ := LogInUser.(db.AuthenticatedEntity)
 	cursor := c.Query("cursor")
-	var lastSeq string
+	var lastPos string
 	if cursor != "" {
-		seqBytes, err := base64.RawStdEncoding.DecodeString(cursor)
+		posBytes, err := base64.RawStdEncoding.DecodeString(cursor)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, WriteError("bad request parameters"))
+			return
 		}
-		lastSeq = string(seqBytes)
+		lastPos = string(posBytes)
 	}
-	output, next, hasMore, err := app.store.CampaignInterace.GetRecentCampaigns(ctx, FeedLimit, lastSeq, Entity.GetID())
+	output, next, hasMore, err := app.store.CampaignInterace.GetRecentCampaigns(ctx, FeedLimit, lastPos, Entity.GetID())
 	if err != nil {
+		if errors.Is(err, db.ErrInvalidArgs) {
+			c.JSON(http.StatusBadRequest, WriteError("bad request parameters"))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("server error"))
 		return
 	}
-	var buf []byte
-	// return the campaign feed
+	// return the ranked campaign feed
 	c.JSON(http.StatusOK, WriteResponse(FeedResponse{
 		Campaigns: output,
 		Meta: Meta{
-			Cursor:  base64.RawStdEncoding.EncodeToString(fmt.Appendf(buf, "%d", next)),
+			Cursor:  base64.RawStdEncoding.EncodeToString([]byte(next)),
 			HasMore: hasMore,
 		},
 	}))
 }
 
+// searches the live campaigns by keywords and filters
+// query parameters: q, platform, min_cpm, max_cpm, min_budget, sector, sort, cursor
+func (app *Application) SearchCampaigns(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	var search db.CampaignSearch
+	if err := c.ShouldBindQuery(&search); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("bad request parameters"))
+		return
+	}
+	var lastPos string
+	if cursor := c.Query("cursor"); cursor != "" {
+		posBytes, err := base64.RawStdEncoding.DecodeString(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WriteError("bad request parameters"))
+			return
+		}
+		lastPos = string(posBytes)
+	}
+	result, err := app.store.CampaignInterace.SearchCampaigns(ctx, Entity.GetID(), search, FeedLimit, lastPos)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidArgs) {
+			c.JSON(http.StatusBadRequest, WriteError("bad request parameters"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(SearchResponse{
+		Campaigns: result.Campaigns,
+		Facets:    result.Facets,
+		Meta: Meta{
+			Cursor:  base64.RawStdEncoding.EncodeToString([]byte(result.Cursor)),
+			HasMore: result.HasMore,
+		},
+	}))
+}
+
 func (app *Application) GetCampaign(c *gin.Context) {
 	ctx := c.Request.Context()
 	campaign_id := c.Param("campaign_id")
@@ -447,3 +628,111 @@ func (app *Application) GetCampaign(c *gin.Context) {
 	// successfully fetched the campaign
 	c.JSON(http.StatusOK, WriteResponse(campaignResponse))
 }
+
+// lists the status changes of a campaign to its brand or an admin
+func (app *Application) GetCampaignStatusHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("campaign_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	campaign, err := app.store.CampaignInterace.GetCampaign(ctx, ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	if campaign.BrandId != Entity.GetID() && Entity.GetRole() != "admin" {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	history, err := app.store.CampaignInterace.GetCampaignStatusHistory(ctx, ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(history))
+}
+
+// transitionCampaign moves a campaign through the state machine for the
+// logged in entity and refreshes the caches the new status affects
+func (app *Application) transitionCampaign(
+	ctx context.Context, entity db.AuthenticatedEntity, id string, to int, reason string,
+) error {
+	err := app.store.CampaignInterace.TransitionCampaign(ctx, db.CampaignTransition{
+		CampaignID: id,
+		To:         to,
+		ActorID:    entity.GetID(),
+		Admin:      entity.GetRole() == "admin",
+		Reason:     reason,
+	})
+	if err != nil {
+		return err
+	}
+	app.refreshCampaignCache(ctx, id, to)
+	return nil
+}
+
+// refreshes the caches affected by a campaign moving to a new status
+func (app *Application) refreshCampaignCache(ctx context.Context, id string, status int) {
+	switch status {
+	case db.ActiveStatus:
+		app.cache.AddActiveCampaign(ctx, id)
+		app.cache.InvalidateCampaign(ctx, id)
+	case db.ExpiredStatus:
+		app.cache.RemoveEndedCampaign(ctx, id)
+	default:
+		app.cache.InvalidateCampaign(ctx, id)
+	}
+}
+
+// writes the response when the campaign state machine refused a status
+// change and reports whether it did
+func transitionFailed(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, db.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, WriteError("campaign status change not allowed"))
+	case errors.Is(err, db.ErrIncompleteCampaign):
+		c.JSON(http.StatusBadRequest, WriteError("campaign details incomplete"))
+	case errors.Is(err, db.ErrReviewRequired):
+		c.JSON(http.StatusForbidden, WriteError("campaign needs an admin review"))
+	default:
+		return false
+	}
+	return true
+}
+
+// a schedule has to end in the future and after it starts
+func validSchedule(startsAt, endsAt *time.Time) bool {
+	if endsAt == nil {
+		return true
+	}
+	if !endsAt.After(time.Now()) {
+		return false
+	}
+	return startsAt == nil || endsAt.After(*startsAt)
+}
+
+// writes the response when the campaign budget could not be escrowed
+// from the brand wallet and reports whether it did
+func escrowFailed(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, db.ErrInsufficientFund):
+		c.JSON(http.StatusPaymentRequired, WriteError("insufficient balance for the campaign budget"))
+	case errors.Is(err, db.ErrAccountInactive):
+		c.JSON(http.StatusBadRequest, WriteError("brand account deactivated/not available"))
+	default:
+		return false
+	}
+	return true
+}
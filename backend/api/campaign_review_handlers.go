@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/Alter-Sitanshu/campaignHub/internals/mailer"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CampaignReviewPayload struct {
+	Reason string `json:"reason"`
+}
+
+// lists the campaigns waiting for a review (admin only)
+func (app *Application) GetPendingCampaigns(c *gin.Context) {
+	ctx := c.Request.Context()
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+
+	campaigns, err := app.store.CampaignInterace.GetPendingCampaigns(ctx, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(campaigns))
+}
+
+// approves a campaign, it goes live or waits for its start time (admin only)
+func (app *Application) ApproveCampaign(c *gin.Context) {
+	app.reviewCampaign(c, db.CampaignApproved)
+}
+
+// rejects a campaign for good (admin only)
+func (app *Application) RejectCampaign(c *gin.Context) {
+	app.reviewCampaign(c, db.CampaignRejected)
+}
+
+// sends a campaign back to its brand as a draft (admin only)
+func (app *Application) RequestCampaignChanges(c *gin.Context) {
+	app.reviewCampaign(c, db.CampaignChangesRequested)
+}
+
+func (app *Application) reviewCampaign(c *gin.Context, decision string) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("campaign_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	var payload CampaignReviewPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid parameters"))
+		return
+	}
+	// the brand has to know what to fix
+	if decision != db.CampaignApproved && payload.Reason == "" {
+		c.JSON(http.StatusBadRequest, WriteError("reason is required"))
+		return
+	}
+
+	status, err := app.store.CampaignInterace.ReviewCampaign(ctx, ID, Entity.GetID(), decision, payload.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			c.JSON(http.StatusNotFound, WriteError("campaign not found"))
+		case errors.Is(err, db.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, WriteError("campaign is not pending review"))
+		default:
+			// an approval can still fail on the launch preconditions
+			if !escrowFailed(c, err) && !transitionFailed(c, err) {
+				c.JSON(http.StatusInternalServerError, WriteError("server error"))
+			}
+		}
+		return
+	}
+	app.refreshCampaignCache(ctx, ID, status)
+
+	campaign, err := app.store.CampaignInterace.GetCampaign(ctx, ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+	app.notifyCampaignReview(*campaign, decision, payload.Reason)
+
+	c.JSON(http.StatusOK, WriteResponse(campaign))
+}
+
+// tells the brand about a review decision over the websocket and email
+func (app *Application) notifyCampaignReview(campaign db.CampaignResp, decision, reason string) {
+	app.msgHub.Notify(campaign.BrandId, map[string]any{
+		"type":        "campaign:" + decision,
+		"campaign_id": campaign.Id,
+		"status":      campaign.Status,
+		"reason":      reason,
+	})
+	go func() {
+		brand, err := app.store.BrandInterface.GetBrandById(context.Background(), campaign.BrandId)
+		if err != nil {
+			log.Printf("error fetching brand %s: %v\n", campaign.BrandId, err)
+			return
+		}
+		mail := mailer.EmailRequest{
+			To:      brand.Email,
+			Subject: "Review of your campaign " + campaign.Title,
+			Body:    mailer.GenerateCampaignReviewEmail(campaign, decision, reason),
+		}
+		// Implementing a retry fallback
+		for tries := 1; tries <= app.cfg.MailCfg.MailRetries; tries++ {
+			if err = app.mailer.PushMail(mail); err == nil {
+				return
+			}
+		}
+		log.Printf("error sending campaign review to %s: %v\n", brand.Email, err.Error())
+	}()
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FraudReviewPayload struct {
+	Note string `json:"note" binding:"required"`
+}
+
+// lists the fraud flags, the open ones form the review queue (admin only)
+func (app *Application) GetFraudFlags(c *gin.Context) {
+	ctx := c.Request.Context()
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	status := c.DefaultQuery("status", db.FraudOpen)
+	switch status {
+	case "", db.FraudOpen, db.FraudCleared, db.FraudConfirmed:
+	default:
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+
+	flags, err := app.store.FraudInterface.GetFraudFlags(ctx, status, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(flags))
+}
+
+// returns a fraud flag with the decisions taken on it (admin only)
+func (app *Application) GetFraudFlag(c *gin.Context) {
+	ctx := c.Request.Context()
+	ID := c.Param("flag_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+
+	flag, err := app.store.FraudInterface.GetFraudFlag(ctx, ID)
+	if err != nil {
+		app.fraudError(c, err)
+		return
+	}
+	decisions, err := app.store.FraudInterface.GetFraudDecisions(ctx, ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(gin.H{
+		"flag":      flag,
+		"decisions": decisions,
+	}))
+}
+
+// releases the held payouts of a flagged submission (admin only)
+func (app *Application) ClearFraudFlag(c *gin.Context) {
+	app.reviewFraudFlag(c, db.FraudCleared)
+}
+
+// confirms the fraud, the payouts of the submission stay held (admin only)
+func (app *Application) ConfirmFraudFlag(c *gin.Context) {
+	app.reviewFraudFlag(c, db.FraudConfirmed)
+}
+
+func (app *Application) reviewFraudFlag(c *gin.Context, decision string) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("flag_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	var payload FraudReviewPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid parameters"))
+		return
+	}
+
+	flag, err := app.store.FraudInterface.ReviewFraudFlag(ctx, ID, Entity.GetID(), decision, payload.Note)
+	if err != nil {
+		app.fraudError(c, err)
+		return
+	}
+	app.msgHub.Notify(flag.CreatorId, map[string]any{
+		"type":          "submission:fraud_" + decision,
+		"submission_id": flag.SubmissionId,
+		"note":          flag.Note,
+	})
+
+	c.JSON(http.StatusOK, WriteResponse(flag))
+}
+
+func (app *Application) fraudError(c *gin.Context, err error) {
+	switch err {
+	case db.ErrNotFound:
+		c.JSON(http.StatusNotFound, WriteError("fraud flag not found"))
+	case db.ErrInvalidStatus:
+		c.JSON(http.StatusConflict, WriteError("fraud flag already reviewed"))
+	default:
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+	}
+}
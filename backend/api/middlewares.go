@@ -1,10 +1,20 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/Alter-Sitanshu/campaignHub/internals/cache"
 	"github.com/Alter-Sitanshu/campaignHub/internals/db"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"golang.org/x/time/rate"
 )
 
@@ -91,21 +101,153 @@ func (app *Application) AuthoriseAdmin() gin.HandlerFunc {
 		// Fetching the logged in user
 		LogInUser, ok := c.Get("user")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, WriteError("unauthorised request"))
 			return
 		}
 		user, ok := LogInUser.(db.AuthenticatedEntity)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, WriteError("unauthorised request"))
 			return
 		}
 		if user.GetRole() != "admin" {
-			c.JSON(http.StatusForbidden, WriteError("forbidden method or operation"))
+			c.AbortWithStatusJSON(http.StatusForbidden, WriteError("forbidden method or operation"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// captures the response written by the handlers so it can be replayed
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Idempotent makes the endpoint safe to retry with an Idempotency-Key header.
+// The first response for a key is stored and replayed for the repeats, a key
+// reused with a different request is rejected. Requests without the header
+// are served as usual.
+func (app *Application) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, WriteError("invalid idempotency key"))
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, WriteError("invalid request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		hash := hex.EncodeToString(sum[:])
+
+		// keys are scoped to the logged in entity, anonymous requests (signups)
+		// to the endpoint so a key reused with another body is still refused
+		owner := "public:" + c.Request.Method + ":" + c.Request.URL.Path
+		if LogInUser, ok := c.Get("user"); ok {
+			if Entity, ok := LogInUser.(db.AuthenticatedEntity); ok {
+				owner = Entity.GetID()
+			}
+		}
+
+		// the outcome is stored even if the client gave up waiting
+		ctx := context.WithoutCancel(c.Request.Context())
+		reserved, existing, err := app.cache.ReserveIdempotencyKey(ctx, owner, key, hash)
+		if err != nil {
+			log.Printf("error reserving idempotency key: %v\n", err.Error())
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, WriteError("try again later"))
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.RequestHash != hash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+					WriteError("idempotency key already used for a different request"))
+			case !existing.Done:
+				c.AbortWithStatusJSON(http.StatusConflict, WriteError("request with this idempotency key is in progress"))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.Status, existing.ContentType, existing.Body)
+				c.Abort()
+			}
 			return
 		}
 
+		// the reservation is kept alive for as long as the request runs
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(cache.TTLIdemInFlight / 3)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := app.cache.ExtendIdempotencyKey(ctx, owner, key); err != nil {
+						log.Printf("error extending idempotency key: %v\n", err.Error())
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		recorder := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		stop := sync.OnceFunc(func() {
+			close(done)
+			<-stopped
+		})
+		// a panicking handler must not keep the key alive forever
+		defer stop()
 		c.Next()
+		stop()
+
+		// server errors are not cached so the client can retry with the same key
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := app.cache.ReleaseIdempotencyKey(ctx, owner, key); err != nil {
+				log.Printf("error releasing idempotency key: %v\n", err.Error())
+			}
+			return
+		}
+		err = app.cache.SaveIdempotentResponse(ctx, owner, key, &cache.IdempotentResponse{
+			RequestHash: hash,
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			log.Printf("error saving idempotent response: %v\n", err.Error())
+		}
+	}
+}
+
+// newRequestID derives the record id from the Idempotency-Key so that a repeat
+// which slips past the cache still collides on the primary key in the db
+func newRequestID(c *gin.Context, ownerID string) string {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		return uuid.NewString()
 	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(ownerID+":"+c.Request.URL.Path+":"+key)).String()
 }
 
 // Rate limiting middleware checks and moderates the number
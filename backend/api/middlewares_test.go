@@ -0,0 +1,129 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Alter-Sitanshu/campaignHub/env"
+	"github.com/Alter-Sitanshu/campaignHub/internals/cache"
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+)
+
+var MockApp *Application
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DOCKER_ENV") != "true" {
+		if err := godotenv.Load("../../.env"); err != nil {
+			log.Println("No .env file found — relying on environment variables")
+		} else {
+			log.Println(".env file loaded successfully")
+		}
+	}
+	client, err := cache.NewClient(env.GetString("REDIS_URL", ""))
+	if err != nil {
+		log.Fatalf("failed to ping redis: %v", err)
+	}
+	MockApp = &Application{cache: cache.NewService(client)}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	router := gin.New()
+	// logs in the user named by the test
+	router.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-User"); id != "" {
+			c.Set("user", db.User{Id: id})
+		}
+	})
+	router.POST("/payouts", MockApp.Idempotent(), func(c *gin.Context) {
+		calls.Add(1)
+		if c.Query("slow") == "true" {
+			<-release
+		}
+		c.JSON(http.StatusCreated, WriteResponse("paid"))
+	})
+	user := uuid.NewString()
+	send := func(key, body, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payouts"+query, strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("repeat is replayed", func(t *testing.T) {
+		calls.Store(0)
+		key := uuid.NewString()
+		first := send(key, `{"amount":100}`, "")
+		second := send(key, `{"amount":100}`, "")
+		if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+			t.Fail()
+		}
+		if second.Header().Get("Idempotent-Replayed") != "true" || second.Body.String() != first.Body.String() {
+			t.Fail()
+		}
+		if calls.Load() != 1 {
+			t.Fail()
+		}
+	})
+	t.Run("key reused for another request", func(t *testing.T) {
+		key := uuid.NewString()
+		send(key, `{"amount":100}`, "")
+		if w := send(key, `{"amount":200}`, ""); w.Code != http.StatusUnprocessableEntity {
+			t.Fail()
+		}
+	})
+	t.Run("anonymous key reused for another request", func(t *testing.T) {
+		calls.Store(0)
+		key := uuid.NewString()
+		anonymous := func(body string) int {
+			req := httptest.NewRequest(http.MethodPost, "/payouts", strings.NewReader(body))
+			req.Header.Set("Idempotency-Key", key)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+		if anonymous(`{"email":"a@mail.com"}`) != http.StatusCreated {
+			t.Fail()
+		}
+		if anonymous(`{"email":"a@mail.com"}`) != http.StatusCreated || calls.Load() != 1 {
+			t.Fail()
+		}
+		if anonymous(`{"email":"b@mail.com"}`) != http.StatusUnprocessableEntity || calls.Load() != 1 {
+			t.Fail()
+		}
+	})
+	t.Run("repeat while in flight", func(t *testing.T) {
+		calls.Store(0)
+		key := uuid.NewString()
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- send(key, `{"amount":100}`, "?slow=true") }()
+		// wait for the first request to reach the handler
+		for calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if w := send(key, `{"amount":100}`, "?slow=true"); w.Code != http.StatusConflict {
+			t.Fail()
+		}
+		close(release)
+		if w := <-done; w.Code != http.StatusCreated {
+			t.Fail()
+		}
+		if calls.Load() != 1 {
+			t.Fail()
+		}
+	})
+}
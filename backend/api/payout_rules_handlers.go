@@ -0,0 +1,88 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PayoutRulesPayload struct {
+	Rules []db.PayoutRule `json:"rules"`
+}
+
+// returns the payout rules of a campaign so creators know what they can earn
+func (app *Application) GetPayoutRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	ID := c.Param("campaign_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	rules, err := app.store.CampaignInterace.GetPayoutRules(ctx, ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(&PayoutRulesPayload{Rules: rules}))
+}
+
+// replaces the payout rules of a campaign, no rules pay the plain CPM
+func (app *Application) SetPayoutRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("campaign_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	var payload PayoutRulesPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError(err.Error()))
+		return
+	}
+	campaign, err := app.store.CampaignInterace.GetCampaign(ctx, ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, WriteError("campaign not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		return
+	}
+	if campaign.BrandId != Entity.GetID() && Entity.GetRole() != "admin" {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	if campaign.Status == db.ExpiredStatus {
+		c.JSON(http.StatusConflict, WriteError("campaign has ended"))
+		return
+	}
+	err = app.store.CampaignInterace.SetPayoutRules(ctx, ID, payload.Rules)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrInvalidArgs):
+			c.JSON(http.StatusBadRequest, WriteError(err.Error()))
+		case errors.Is(err, db.ErrNotFound):
+			c.JSON(http.StatusNotFound, WriteError("campaign not found"))
+		default:
+			log.Printf("error setting payout rules: %v", err.Error())
+			c.JSON(http.StatusInternalServerError, WriteError("internal server error"))
+		}
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(&payload))
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reports the API quota left on the platforms (admin only)
+func (app *Application) GetPlatformQuota(c *gin.Context) {
+	ctx := c.Request.Context()
+	reports, err := app.factory.QuotaReports(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(reports))
+}
@@ -48,6 +48,11 @@ type Config struct {
 	RedisCfg   RedisConfig
 	FactoryCfg FactoryConfig
 	B2Cfg      B2Config
+	WorkerCfg  WorkerConfig
+}
+
+type WorkerConfig struct {
+	BudgetAlerts string // comma separated fractions of the budget left that warn the brand
 }
 
 type B2Config struct {
@@ -60,6 +65,12 @@ type B2Config struct {
 type FactoryConfig struct {
 	YouTubeAPIKey string
 	MetaToken     string
+	MetaUserID    string // instagram professional account of the app
+	TikTokKey     string // research API app of tiktok
+	TikTokSecret  string
+	YTDailyQuota  int    // quota units per day
+	YTQuotaReset  string // time of the day the quota resets, HH:MM
+	YTQuotaZone   string // zone of the reset time
 }
 
 type RedisConfig struct {
@@ -103,8 +114,8 @@ func (app *Application) AddRoutes(addr string, router *gin.Engine) {
 	// query parameter: token: Example /verify/?token =
 	base.GET("/verify", app.Verification)
 	base.POST("/login", app.Login)
-	base.POST("/users/signup", app.CreateUserNoVerify)
-	base.POST("/brands/signup", app.CreateBrandNoVerify)
+	base.POST("/users/signup", app.Idempotent(), app.CreateUserNoVerify)
+	base.POST("/brands/signup", app.Idempotent(), app.CreateBrandNoVerify)
 	base.POST("/oauth/callback", app.OAuthCallback)
 	// entity should be in ["users", "brands"]
 	base.POST("/forgot_password/request/:entity", app.ForgotPassword)
@@ -140,13 +151,20 @@ func (app *Application) AddRoutes(addr string, router *gin.Engine) {
 	// campaign routes
 	campaigns := base.Group("/campaigns", app.AuthMiddleware())
 	{
-		campaigns.GET("/feed", app.GetCampaignFeed) // query parametes: cursor
+		campaigns.GET("/feed", app.GetCampaignFeed)   // query parametes: cursor
+		campaigns.GET("/search", app.SearchCampaigns) // query parameters: q, filters, sort, cursor
 		campaigns.GET("/:campaign_id", app.GetCampaign)
+		campaigns.GET("/:campaign_id/history", app.GetCampaignStatusHistory)
+		campaigns.GET("/:campaign_id/eligibility", app.GetCampaignEligibility)
+		campaigns.PUT("/:campaign_id/eligibility", app.SetCampaignEligibility)
+		campaigns.GET("/:campaign_id/payout-rules", app.GetPayoutRules)
+		campaigns.PUT("/:campaign_id/payout-rules", app.SetPayoutRules)
 		campaigns.GET("/user/:user_id", app.GetUserCampaigns, app.AuthoriseUser()) // query parameters: cursor
 		campaigns.GET("/brand/:brand_id", app.GetBrandCampaigns)                   // query parameters: cursor
-		campaigns.POST("", app.CreateCampaign)
+		campaigns.POST("", app.Idempotent(), app.CreateCampaign)
 		campaigns.PUT("/stop/:campaign_id", app.StopCampaign)
 		campaigns.PUT("/activate/:campaign_id", app.ActivateCampaign)
+		campaigns.PUT("/submit/:campaign_id", app.SubmitCampaign)
 		campaigns.PUT("/stop_applications/:campaign_id", app.StopApplications)
 		campaigns.DELETE("/:campaign_id", app.DeleteCampaign)
 		campaigns.PATCH("/:campaign_id", app.UpdateCampaign)
@@ -161,7 +179,7 @@ func (app *Application) AddRoutes(addr string, router *gin.Engine) {
 		applications.PUT("/accept/:application_id", app.AcceptApplication)
 		applications.PUT("/reject/:application_id", app.RejectApplication)
 		applications.DELETE("/delete/:application_id", app.DeleteApplication, app.AuthoriseAdmin())
-		applications.POST("/:campaign_id", app.CreateApplication)
+		applications.POST("/:campaign_id", app.Idempotent(), app.CreateApplication)
 	}
 
 	// tickets routes
@@ -169,7 +187,7 @@ func (app *Application) AddRoutes(addr string, router *gin.Engine) {
 	{
 		tickets.GET("", app.GetRecentTickets, app.AuthoriseAdmin()) // query: status("open"/"close"), limit, offset
 		tickets.GET("/:ticket_id", app.GetTicket, app.AuthoriseAdmin())
-		tickets.POST("", app.RaiseTicket)
+		tickets.POST("", app.Idempotent(), app.RaiseTicket)
 		tickets.PUT("/:ticket_id", app.CloseTicket, app.AuthoriseAdmin())
 		tickets.DELETE("/:ticket_id", app.DeleteTicket, app.AuthoriseAdmin())
 	}
@@ -180,23 +198,76 @@ func (app *Application) AddRoutes(addr string, router *gin.Engine) {
 		submission.GET("", app.FilterSubmissions)               // query: creator_id, campaign_id, time
 		submission.GET("/my-submissions", app.GetMySubmissions) // query: time, limit, offset
 		submission.GET("/:sub_id", app.GetSubmission)
-		submission.POST("", app.CreateSubmission)
+		submission.GET("/:sub_id/metrics", app.GetSubmissionMetrics) // query: from, to, bucket
+		submission.POST("", app.Idempotent(), app.CreateSubmission)
 		submission.DELETE("/:sub_id", app.DeleteSubmission, app.AuthoriseAdmin())
 		submission.PATCH("/:sub_id", app.UpdateSubmission)
+		// review by the brand running the campaign
+		submission.GET("/pending/:campaign_id", app.GetPendingSubmissions) // query: limit, offset
+		submission.PUT("/approve/:sub_id", app.Idempotent(), app.ApproveSubmission)
+		submission.PUT("/reject/:sub_id", app.Idempotent(), app.RejectSubmission)
+		submission.PUT("/revision/:sub_id", app.Idempotent(), app.RequestSubmissionRevision)
 	}
 
 	// accounts routes
 	accounts := base.Group("/accounts", app.AuthMiddleware())
 	{
 		accounts.GET("", app.GetAllAccounts, app.AuthoriseAdmin())
+		accounts.GET("/reconcile", app.AuthoriseAdmin(), app.ReconcileAccounts)
 		accounts.GET("/:acc_id", app.GetUserAccount)
-		accounts.POST("", app.CreateAccount)
-		accounts.PUT("/withdraw", app.WithdrawBalance)
-		accounts.PUT("/deposit", app.DepositBalance)
+		accounts.GET("/:acc_id/ledger", app.GetAccountLedger) // query: offset, limit
+		accounts.POST("", app.Idempotent(), app.CreateAccount)
+		accounts.PUT("/withdraw", app.Idempotent(), app.WithdrawBalance)
+		accounts.PUT("/deposit", app.Idempotent(), app.DepositBalance)
 		accounts.DELETE("/accounts/:acc_id", app.DeleteUserAccount, app.AuthoriseAdmin())
 		accounts.PUT("/accounts/:acc_id", app.DisableUserAccount)
 	}
 
+	// withdrawal routes
+	withdrawals := base.Group("/withdrawals", app.AuthMiddleware())
+	{
+		withdrawals.GET("/me", app.GetMyWithdrawals) // query: limit, offset
+	}
+	adminWithdrawals := withdrawals.Group("", app.AuthoriseAdmin())
+	{
+		adminWithdrawals.GET("", app.GetWithdrawals) // query: status, limit, offset
+		adminWithdrawals.PUT("/approve/:withdrawal_id", app.Idempotent(), app.ApproveWithdrawal)
+		adminWithdrawals.PUT("/reject/:withdrawal_id", app.Idempotent(), app.RejectWithdrawal)
+		adminWithdrawals.PUT("/pay/:withdrawal_id", app.Idempotent(), app.PayWithdrawal)
+		adminWithdrawals.PUT("/fail/:withdrawal_id", app.Idempotent(), app.FailWithdrawal)
+	}
+
+	// campaign review routes (admin only)
+	reviews := base.Group("/campaign-reviews", app.AuthMiddleware(), app.AuthoriseAdmin())
+	{
+		reviews.GET("", app.GetPendingCampaigns) // query: limit, offset
+		reviews.PUT("/approve/:campaign_id", app.Idempotent(), app.ApproveCampaign)
+		reviews.PUT("/reject/:campaign_id", app.Idempotent(), app.RejectCampaign)
+		reviews.PUT("/changes/:campaign_id", app.Idempotent(), app.RequestCampaignChanges)
+	}
+
+	// fraud review routes (admin only)
+	fraud := base.Group("/fraud", app.AuthMiddleware(), app.AuthoriseAdmin())
+	{
+		fraud.GET("/flags", app.GetFraudFlags) // query: status, limit, offset
+		fraud.GET("/flags/:flag_id", app.GetFraudFlag)
+		fraud.PUT("/clear/:flag_id", app.Idempotent(), app.ClearFraudFlag)
+		fraud.PUT("/confirm/:flag_id", app.Idempotent(), app.ConfirmFraudFlag)
+	}
+
+	// batch queue routes (admin only)
+	batch := base.Group("/batch", app.AuthMiddleware(), app.AuthoriseAdmin())
+	{
+		batch.GET("/dead-letters", app.GetDeadLetters) // query: cursor, limit
+		batch.POST("/dead-letters/:letter_id/replay", app.ReplayDeadLetter)
+	}
+
+	// platform routes (admin only)
+	platforms := base.Group("/platforms", app.AuthMiddleware(), app.AuthoriseAdmin())
+	{
+		platforms.GET("/quota", app.GetPlatformQuota)
+	}
+
 	// messaging routes
 	conversations := base.Group("/private/conversations", app.AuthMiddleware())
 	{
@@ -244,10 +315,11 @@ func NewApplication(addr string, store *db.Store, cfg *Config, JWT, PASETO auth.
 		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders: []string{
 			"Origin", "Content-Type", "Authorization",
-			"X-Requested-With", "Accept", "X-Request-ID",
+			"X-Requested-With", "Accept", "X-Request-ID", "Idempotency-Key",
 		},
 		ExposeHeaders: []string{
 			"Content-Length", "Content-Disposition", // for file downloads
+			"Idempotent-Replayed",
 		},
 		AllowCredentials: true,
 		AllowWebSockets:  true,
@@ -310,11 +382,19 @@ func (app *Application) Run() error {
 	// context of the workers
 	ctx, cancel := context.WithCancel(context.Background())
 	app.workers.SetCancel(cancel)
-	app.wg.Add(3) // One for each service running
+	app.wg.Add(5) // One for each service running
 	go func() {
 		defer app.wg.Done()
 		app.workers.Poll.Start(ctx)
 	}()
+	go func() {
+		defer app.wg.Done()
+		app.workers.Metrics.Start(ctx)
+	}()
+	go func() {
+		defer app.wg.Done()
+		app.workers.Scheduler.Start(ctx)
+	}()
 	// Start the batch workers go routines
 	go func() {
 		defer app.wg.Done()
@@ -333,6 +413,8 @@ func (app *Application) Shutdown(ctx context.Context) error {
 	// closing the workers routine
 	app.workers.Batch.Stop()
 	app.workers.Poll.Stop()
+	app.workers.Metrics.Stop()
+	app.workers.Scheduler.Stop()
 
 	// closing the sockets routine
 	app.msgHub.Stop()
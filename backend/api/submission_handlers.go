@@ -1,11 +1,14 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Alter-Sitanshu/campaignHub/internals/cache"
@@ -20,7 +23,6 @@ type SubmissionPayload struct {
 	CreatorId  string `json:"creator_id" binding:"required"`
 	CampaignId string `json:"campaign_id" binding:"required"`
 	Url        string `json:"url" binding:"required"`
-	Status     *int   `json:"status" binding:"required,oneof=1 0 3"`
 }
 
 type SubmissionResponse struct {
@@ -40,6 +42,7 @@ type Submission struct {
 	Thumbnail    string  `json:"thumbnail"`
 	UploadedAt   string  `json:"uploaded_at"`
 	Status       int     `json:"status"`
+	ReviewReason string  `json:"review_reason,omitempty"`
 	VideoStatus  string  `json:"video_status"`
 	Earnings     float64 `json:"earnings"`
 	LastSyncedAt string  `json:"last_synced_at"`
@@ -71,39 +74,49 @@ func (app *Application) CreateSubmission(c *gin.Context) {
 	}
 	// making the submission object
 	formattedTime := time.Now().Format(time.RFC3339) // Format using a reference time
-	submission := db.Submission{
-		Id:          uuid.New().String(),
-		CreatorId:   payload.CreatorId,
-		CampaignId:  payload.CampaignId,
-		Url:         payload.Url,
-		Status:      *payload.Status,
-		VideoStatus: "active",
-	}
-
 	vid, err := platform.ParseVideoURL(payload.Url)
 	if err != nil {
 		log.Printf("error: %s\n", err.Error())
 		c.JSON(http.StatusBadRequest, WriteError(err.Error()))
 		return
 	}
+	submission := db.Submission{
+		Id:            uuid.New().String(),
+		CreatorId:     payload.CreatorId,
+		CampaignId:    payload.CampaignId,
+		Url:           vid.URL,
+		Status:        db.SubmissionPending, // the brand reviews it first
+		VideoPlatform: vid.Name,
+	}
 	// Fetch the Meta Data for the sumission
 
 	Data, err := app.factory.GetVideoDetails(ctx, vid.Name, vid.VideoID)
 	if err != nil {
+		if errors.Is(err, platform.ErrQuotaExhausted) || errors.Is(err, platform.ErrTokenExpired) {
+			c.JSON(http.StatusServiceUnavailable, WriteError("video lookups paused, try again later"))
+			return
+		}
 		log.Printf("error fetching meta data: %s\n", err.Error())
 		c.JSON(http.StatusInternalServerError, WriteError("server error try again"))
 		return
 	}
+	if platform.Unavailable(Data.Status) {
+		c.JSON(http.StatusBadRequest, WriteError("video is "+strings.ReplaceAll(Data.Status, "_", " ")))
+		return
+	}
+	submission.VideoStatus = Data.Status
+	var objKey string
 	ext, _ := mime.ExtensionsByType(Data.Thumbnails.ContentType)
-	extension := ext[0]
-	objKey, _ := b2.GenerateFileKey(submission.Id, "thumbnail", extension)
+	if platform.CapabilitiesOf(vid.Name).Thumbnails && len(Data.Thumbnails.Raw) > 0 && len(ext) > 0 {
+		objKey, _ = b2.GenerateFileKey(submission.Id, "thumbnail", ext[0])
 
-	fileKey := fmt.Sprintf("%s%s", app.s3Store.BucketName, objKey)
+		fileKey := fmt.Sprintf("%s%s", app.s3Store.BucketName, objKey)
 
-	err = app.s3Store.UploadFile(fileKey, Data.Thumbnails.Raw, Data.Thumbnails.ContentType)
-	if err != nil {
-		log.Printf("error uploading submission thumbnail: %s\n", err.Error())
-		// I dont know what to do for that
+		err = app.s3Store.UploadFile(fileKey, Data.Thumbnails.Raw, Data.Thumbnails.ContentType)
+		if err != nil {
+			log.Printf("error uploading submission thumbnail: %s\n", err.Error())
+			// I dont know what to do for that
+		}
 	}
 
 	// populate the meta data
@@ -329,12 +342,62 @@ func (app *Application) UpdateSubmission(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, WriteError("invalid filter request"))
 		return
 	}
+	payload.Id = sub_id
+	if payload.Url != nil {
+		// the new video starts earning from the views it has now
+		vid, err := platform.ParseVideoURL(*payload.Url)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WriteError(err.Error()))
+			return
+		}
+		Data, err := app.factory.GetVideoDetails(ctx, vid.Name, vid.VideoID)
+		if err != nil {
+			if errors.Is(err, platform.ErrQuotaExhausted) || errors.Is(err, platform.ErrTokenExpired) {
+				c.JSON(http.StatusServiceUnavailable, WriteError("video lookups paused, try again later"))
+				return
+			}
+			log.Printf("error fetching meta data: %s\n", err.Error())
+			c.JSON(http.StatusInternalServerError, WriteError("server error try again"))
+			return
+		}
+		if platform.Unavailable(Data.Status) {
+			c.JSON(http.StatusBadRequest, WriteError("video is "+strings.ReplaceAll(Data.Status, "_", " ")))
+			return
+		}
+		var objKey string
+		ext, _ := mime.ExtensionsByType(Data.Thumbnails.ContentType)
+		if platform.CapabilitiesOf(vid.Name).Thumbnails && len(Data.Thumbnails.Raw) > 0 && len(ext) > 0 {
+			objKey, _ = b2.GenerateFileKey(sub_id, "thumbnail", ext[0])
+			fileKey := fmt.Sprintf("%s%s", app.s3Store.BucketName, objKey)
+			if err := app.s3Store.UploadFile(fileKey, Data.Thumbnails.Raw, Data.Thumbnails.ContentType); err != nil {
+				log.Printf("error uploading submission thumbnail: %s\n", err.Error())
+			}
+		}
+		payload.Url = &vid.URL
+		payload.Video = &db.SubmissionVideo{
+			Platform:     vid.Name,
+			VideoID:      Data.VideoID,
+			Title:        Data.Title,
+			ThumbnailURL: objKey,
+			Views:        Data.ViewCount,
+			LikeCount:    Data.LikeCount,
+			VideoStatus:  Data.Status,
+		}
+	}
 
 	// try the update
 	if err := app.store.SubmissionInterface.UpdateSubmission(ctx, payload); err != nil {
+		if errors.Is(err, db.ErrNotPendingReview) {
+			c.JSON(http.StatusConflict, WriteError("only a video under review can be replaced"))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, WriteError("server error"))
 		return
 	}
+	if payload.Url != nil {
+		app.cache.InvalidateVideoMetadata(ctx, sub_id)
+		app.cache.ResetQueuedViews(ctx, sub_id)
+	}
 	sub_response, _ := app.store.SubmissionInterface.FindSubmissionById(ctx, sub_id)
 	resp := []Submission{
 		{
@@ -418,6 +481,19 @@ func (app *Application) GetSubmission(c *gin.Context) {
 	if err == nil {
 		// cache hit
 		metaData = *VideoMetaData
+	} else if app.factory.QuotaLow(ctx, sub.VideoPlatform) {
+		// save the quota for the polling, serve the last synced values
+		metaData = cache.VideoMetadata{
+			SubmissionID: sub.Id,
+			VideoID:      sub.VideoID,
+			Platform:     sub.VideoPlatform,
+			Title:        sub.VideoTitle,
+			ViewCount:    sub.Views,
+			LikeCount:    sub.LikeCount,
+			Thumbnail: cache.Thumbnail{
+				ObjKey: sub.ThumbnailURL,
+			},
+		}
 	} else {
 		vid, err := platform.ParseVideoURL(sub.Url)
 		if err != nil {
@@ -467,6 +543,7 @@ func (app *Application) GetSubmission(c *gin.Context) {
 			Thumbnail:    metaData.Thumbnail.ObjKey,
 			UploadedAt:   sub.CreatedAt,
 			Status:       sub.Status,
+			ReviewReason: sub.ReviewReason,
 			VideoStatus:  sub.VideoStatus,
 			Earnings:     sub.Earnings,
 			LastSyncedAt: sub.LastSyncedAt,
@@ -630,3 +707,90 @@ func (app *Application) GetMySubmissions(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, WriteResponse(response))
 }
+
+// default and longest range of a metrics series per bucket size
+var metricRanges = map[string]struct{ fallback, max time.Duration }{
+	db.MetricsHour: {7 * 24 * time.Hour, 31 * 24 * time.Hour},
+	db.MetricsDay:  {90 * 24 * time.Hour, 366 * 24 * time.Hour},
+}
+
+// GetSubmissionMetrics returns the views history of a submission for charts.
+// query: from, to (RFC3339), bucket (hour|day)
+func (app *Application) GetSubmissionMetrics(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+
+	sub_id := c.Param("sub_id")
+	if err := uuid.Validate(sub_id); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid submission id"))
+		return
+	}
+	bucket := c.DefaultQuery("bucket", db.MetricsHour)
+	ranges, ok := metricRanges[bucket]
+	if !ok {
+		c.JSON(http.StatusBadRequest, WriteError("bucket should be hour or day"))
+		return
+	}
+	to := time.Now()
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WriteError("invalid to time"))
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-ranges.fallback)
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WriteError("invalid from time"))
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) || to.Sub(from) > ranges.max {
+		c.JSON(http.StatusBadRequest, WriteError("invalid time range"))
+		return
+	}
+
+	sub, err := app.store.SubmissionInterface.FindSubmissionById(ctx, sub_id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, WriteError("submission not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+	// the creator, the brand running the campaign and the admins can see the series
+	if sub.CreatorId != Entity.GetID() && Entity.GetRole() != "admin" {
+		campaign, err := app.store.CampaignInterace.GetCampaign(ctx, sub.CampaignId)
+		if err != nil || campaign.BrandId != Entity.GetID() {
+			c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+			return
+		}
+	}
+
+	series, err := app.store.MetricsInterface.GetMetricSeries(ctx, sub.Id, bucket, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(gin.H{
+		"submission_id": sub.Id,
+		"bucket":        bucket,
+		"from":          from.UTC().Format(time.RFC3339),
+		"to":            to.UTC().Format(time.RFC3339),
+		"series":        series,
+	}))
+}
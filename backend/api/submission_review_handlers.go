@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SubmissionReviewPayload struct {
+	Reason string `json:"reason"`
+}
+
+// lists the submissions of a campaign waiting for the brand's review
+func (app *Application) GetPendingSubmissions(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	campaignID := c.Param("campaign_id")
+	if ok := uuid.Validate(campaignID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	campaign, err := app.store.CampaignInterace.GetCampaign(ctx, campaignID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, WriteError("campaign not found"))
+		return
+	}
+	if campaign.BrandId != Entity.GetID() && Entity.GetRole() != "admin" {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+
+	subs, err := app.store.SubmissionInterface.GetPendingSubmissions(ctx, campaignID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+	c.JSON(http.StatusOK, WriteResponse(subs))
+}
+
+// approves a submission, its views start earning
+func (app *Application) ApproveSubmission(c *gin.Context) {
+	app.reviewSubmission(c, db.SubmissionApproved)
+}
+
+// rejects a submission for good
+func (app *Application) RejectSubmission(c *gin.Context) {
+	app.reviewSubmission(c, db.SubmissionRejected)
+}
+
+// asks the creator to submit a new video
+func (app *Application) RequestSubmissionRevision(c *gin.Context) {
+	app.reviewSubmission(c, db.SubmissionRevision)
+}
+
+func (app *Application) reviewSubmission(c *gin.Context, status int) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	subID := c.Param("sub_id")
+	if ok := uuid.Validate(subID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	var payload SubmissionReviewPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid parameters"))
+		return
+	}
+	// the creator has to know what to fix
+	if status != db.SubmissionApproved && payload.Reason == "" {
+		c.JSON(http.StatusBadRequest, WriteError("reason is required"))
+		return
+	}
+	sub, err := app.store.SubmissionInterface.FindSubmissionById(ctx, subID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, WriteError("submission not found"))
+		return
+	}
+	// only the brand running the campaign reviews its submissions
+	campaign, err := app.store.CampaignInterace.GetCampaign(ctx, sub.CampaignId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+	if campaign.BrandId != Entity.GetID() && Entity.GetRole() != "admin" {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+
+	exhausted, err := app.store.SubmissionInterface.ReviewSubmission(ctx, subID, Entity.GetID(), status, payload.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			c.JSON(http.StatusNotFound, WriteError("submission not found"))
+		case errors.Is(err, db.ErrNotPendingReview):
+			c.JSON(http.StatusConflict, WriteError("submission is not pending review"))
+		case errors.Is(err, db.ErrInsufficientFund):
+			c.JSON(http.StatusConflict, WriteError("campaign budget cannot pay the flat fee"))
+		case errors.Is(err, db.ErrInvalidArgs):
+			c.JSON(http.StatusBadRequest, WriteError(err.Error()))
+		default:
+			log.Printf("error reviewing submission %s: %v\n", subID, err)
+			c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		}
+		return
+	}
+	if err := app.cache.SetSubmissionStatus(ctx, subID, status); err != nil {
+		app.cache.InvalidateSubmissionStatus(ctx, subID)
+	}
+	if status == db.SubmissionApproved {
+		// the approval may have paid the flat fee
+		app.cache.InvalidateSubmissionEarnings(ctx, subID)
+		app.cache.InvalidateUserBalance(ctx, sub.CreatorId)
+	}
+	if exhausted {
+		// the flat fee used up the budget, announced like a payout ending it
+		if err := app.cache.RemoveEndedCampaign(ctx, sub.CampaignId); err != nil {
+			log.Printf("error removing campaign %s from the active ones: %v\n", sub.CampaignId, err)
+		}
+		app.msgHub.Notify(campaign.BrandId, map[string]any{
+			"type":        "campaign:budget_exhausted",
+			"campaign_id": sub.CampaignId,
+		})
+		app.msgHub.Notify(sub.CreatorId, map[string]any{
+			"type":        "campaign:budget_exhausted",
+			"campaign_id": sub.CampaignId,
+		})
+	}
+	sub.Status = status
+	sub.ReviewReason = payload.Reason
+
+	app.msgHub.Notify(sub.CreatorId, map[string]any{
+		"type":          "submission:review",
+		"submission_id": sub.Id,
+		"campaign_id":   sub.CampaignId,
+		"status":        status,
+		"reason":        payload.Reason,
+	})
+	c.JSON(http.StatusOK, WriteResponse(sub))
+}
@@ -28,6 +28,7 @@ type UserPayload struct {
 	Password      string     `json:"password" binding:"required"`
 	Gender        string     `json:"gender" binding:"required"`
 	Age           int        `json:"age" binding:"required"`
+	Country       string     `json:"country" binding:"omitempty,iso3166_1_alpha2"`
 	PlatformLinks []db.Links `json:"links" binding:"required"`
 }
 
@@ -41,6 +42,7 @@ type UserResponse struct {
 	Amount         float64    `json:"amount,omitempty"`
 	Currency       string     `json:"currency"`
 	Age            int        `json:"age" binding:"required"`
+	Country        string     `json:"country"`
 	ProfilePicture string     `json:"picture"`
 	PlatformLinks  []db.Links `json:"links" binding:"required"`
 }
@@ -62,6 +64,7 @@ func (app *Application) CreateUserNoVerify(c *gin.Context) {
 		Email:         payload.Email,
 		Gender:        payload.Gender,
 		Age:           payload.Age,
+		Country:       payload.Country,
 		Role:          defaultUserLVL,
 		PlatformLinks: payload.PlatformLinks,
 	}
@@ -113,6 +116,7 @@ func (app *Application) CreateUser(c *gin.Context) {
 		Email:         payload.Email,
 		Gender:        payload.Gender,
 		Age:           payload.Age,
+		Country:       payload.Country,
 		Role:          defaultUserLVL,
 		PlatformLinks: payload.PlatformLinks,
 	}
@@ -232,6 +236,7 @@ func (app *Application) GetUserById(c *gin.Context) {
 		Amount:         user.Amount,
 		Currency:       user.Currency,
 		Age:            user.Age,
+		Country:        user.Country,
 		ProfilePicture: profilePic,
 		PlatformLinks:  user.PlatformLinks,
 	}
@@ -283,6 +288,7 @@ func (app *Application) GetUserByEmail(c *gin.Context) {
 		Amount:         user.Amount,
 		Currency:       user.Currency,
 		Age:            user.Age,
+		Country:        user.Country,
 		ProfilePicture: profilePic,
 		PlatformLinks:  user.PlatformLinks,
 	}
@@ -380,6 +386,7 @@ func (app *Application) UpdateUser(c *gin.Context) {
 		Gender:        user.Gender,
 		Amount:        user.Amount,
 		Age:           user.Age,
+		Country:       user.Country,
 		PlatformLinks: user.PlatformLinks,
 	}
 
@@ -446,6 +453,7 @@ func (app *Application) GetCurrentUser(c *gin.Context) {
 		Amount:     user.Amount,
 		Currency:   user.Currency,
 		Age:        user.Age,
+		Country:    user.Country,
 		IsVerified: user.IsVerified,
 		ProfilePicture: fmt.Sprintf("%s%s",
 			"https://nsyyvtwxyaxcvzjiaynx.supabase.co/storage/v1/object/public/frogmedia/",
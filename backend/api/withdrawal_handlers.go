@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/Alter-Sitanshu/campaignHub/internals/mailer"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RejectWithdrawalPayload struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// lists the withdrawal requests (admin only)
+func (app *Application) GetWithdrawals(c *gin.Context) {
+	ctx := c.Request.Context()
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", db.WithdrawalPending, db.WithdrawalApproved, db.WithdrawalRejected,
+		db.WithdrawalPaid, db.WithdrawalFailed:
+	default:
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+
+	requests, err := app.store.WithdrawalInterface.GetWithdrawals(ctx, status, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(requests))
+}
+
+// lists the withdrawal requests of the logged in user
+func (app *Application) GetMyWithdrawals(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid query"))
+		return
+	}
+
+	requests, err := app.store.WithdrawalInterface.GetHolderWithdrawals(ctx, Entity.GetID(), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WriteResponse(requests))
+}
+
+// approves a pending withdrawal (admin only), the funds stay held until the
+// payout is settled or fails
+func (app *Application) ApproveWithdrawal(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("withdrawal_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+
+	request, err := app.store.WithdrawalInterface.ApproveWithdrawal(ctx, ID, Entity.GetID())
+	if err != nil {
+		app.withdrawalError(c, err)
+		return
+	}
+	app.notifyHolder(*request)
+
+	c.JSON(http.StatusOK, WriteResponse(request))
+}
+
+// marks an approved withdrawal paid once the money was sent out (admin only)
+func (app *Application) PayWithdrawal(c *gin.Context) {
+	ctx := c.Request.Context()
+	ID := c.Param("withdrawal_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+
+	paid, err := app.store.WithdrawalInterface.PayWithdrawal(ctx, ID)
+	if err != nil {
+		app.withdrawalError(c, err)
+		return
+	}
+	app.notifyHolder(*paid)
+
+	c.JSON(http.StatusOK, WriteResponse(paid))
+}
+
+// marks an approved withdrawal whose payout failed and refunds the held
+// amount (admin only)
+func (app *Application) FailWithdrawal(c *gin.Context) {
+	ctx := c.Request.Context()
+	ID := c.Param("withdrawal_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	var payload RejectWithdrawalPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid parameters"))
+		return
+	}
+
+	failed, err := app.store.WithdrawalInterface.FailWithdrawal(ctx, ID, payload.Reason)
+	if err != nil {
+		app.withdrawalError(c, err)
+		return
+	}
+	app.cache.UpdateUserBalance(ctx, failed.HolderId, failed.Amount)
+	app.notifyHolder(*failed)
+
+	c.JSON(http.StatusOK, WriteResponse(failed))
+}
+
+// rejects a pending withdrawal and refunds the held amount (admin only)
+func (app *Application) RejectWithdrawal(c *gin.Context) {
+	ctx := c.Request.Context()
+	LogInUser, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	Entity, ok := LogInUser.(db.AuthenticatedEntity)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, WriteError("unauthorised request"))
+		return
+	}
+	ID := c.Param("withdrawal_id")
+	if ok := uuid.Validate(ID); ok != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid request"))
+		return
+	}
+	var payload RejectWithdrawalPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, WriteError("invalid parameters"))
+		return
+	}
+
+	request, err := app.store.WithdrawalInterface.RejectWithdrawal(ctx, ID, Entity.GetID(), payload.Reason)
+	if err != nil {
+		app.withdrawalError(c, err)
+		return
+	}
+	app.cache.UpdateUserBalance(ctx, request.HolderId, request.Amount)
+	app.notifyHolder(*request)
+
+	c.JSON(http.StatusOK, WriteResponse(request))
+}
+
+func (app *Application) withdrawalError(c *gin.Context, err error) {
+	switch err {
+	case db.ErrNotFound:
+		c.JSON(http.StatusNotFound, WriteError("withdrawal not found"))
+	case db.ErrInvalidStatus:
+		c.JSON(http.StatusConflict, WriteError("withdrawal already processed"))
+	default:
+		c.JSON(http.StatusInternalServerError, WriteError("server error"))
+	}
+}
+
+// looks up the holder's email and sends the status notification, the
+// account type tells a creator from a brand
+func (app *Application) notifyHolder(request db.Withdrawal) {
+	go func() {
+		ctx := context.Background()
+		email, err := app.holderEmail(ctx, request)
+		if err != nil {
+			log.Printf("error fetching withdrawal holder %s: %v\n", request.HolderId, err)
+		}
+		app.notifyWithdrawal(email, request)
+	}()
+}
+
+func (app *Application) holderEmail(ctx context.Context, request db.Withdrawal) (string, error) {
+	acc, err := app.store.TransactionInterface.GetAccount(ctx, request.AccountId)
+	if err != nil {
+		return "", err
+	}
+	if acc.Type == "brand" {
+		brand, err := app.store.BrandInterface.GetBrandById(ctx, request.HolderId)
+		if err != nil {
+			return "", err
+		}
+		return brand.Email, nil
+	}
+	user, err := app.store.UserInterface.GetUserById(ctx, request.HolderId)
+	if err != nil {
+		return "", err
+	}
+	return user.Email, nil
+}
+
+// notifies the holder about a withdrawal state change over the websocket and email
+func (app *Application) notifyWithdrawal(email string, request db.Withdrawal) {
+	app.msgHub.Notify(request.HolderId, map[string]any{
+		"type":       "withdrawal:" + request.Status,
+		"withdrawal": request,
+	})
+	if email == "" {
+		return
+	}
+
+	mail := mailer.EmailRequest{
+		To:      email,
+		Subject: "Your withdrawal request is " + request.Status,
+		Body:    mailer.GenerateWithdrawalEmail(request),
+	}
+	// Implementing a retry fallback
+	var err error
+	for tries := 1; tries <= app.cfg.MailCfg.MailRetries; tries++ {
+		if err = app.mailer.PushMail(mail); err == nil {
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("error sending withdrawal update to %s: %v\n", email, err.Error())
+	}
+}
@@ -0,0 +1,26 @@
+DROP TRIGGER IF EXISTS trg_journal_balanced ON ledger_postings;
+DROP FUNCTION IF EXISTS check_journal_balanced();
+
+DROP INDEX IF EXISTS idx_postings_account;
+DROP INDEX IF EXISTS idx_postings_entry;
+DROP INDEX IF EXISTS idx_journal_tx;
+
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS journal_entries;
+
+DELETE FROM transactions
+WHERE from_id IN (SELECT id FROM accounts WHERE holder_type = 'system')
+OR to_id IN (SELECT id FROM accounts WHERE holder_type = 'system');
+DELETE FROM accounts WHERE holder_type = 'system';
+
+ALTER TABLE accounts
+DROP CONSTRAINT IF EXISTS accounts_amount_check;
+
+ALTER TABLE accounts
+ADD CONSTRAINT accounts_amount_check CHECK (amount >= 0);
+
+ALTER TABLE accounts
+DROP CONSTRAINT IF EXISTS accounts_holder_type_check;
+
+ALTER TABLE accounts
+ADD CONSTRAINT accounts_holder_type_check CHECK (holder_type IN ('user', 'brand'));
@@ -0,0 +1,88 @@
+-- =========================
+-- Double-entry ledger
+-- =========================
+-- system accounts (external money movement) live next to the wallets
+ALTER TABLE accounts
+DROP CONSTRAINT IF EXISTS accounts_holder_type_check;
+
+ALTER TABLE accounts
+ADD CONSTRAINT accounts_holder_type_check CHECK (holder_type IN ('user', 'brand', 'system'));
+
+-- only system accounts are allowed to carry a negative balance
+ALTER TABLE accounts
+DROP CONSTRAINT IF EXISTS accounts_amount_check;
+
+ALTER TABLE accounts
+ADD CONSTRAINT accounts_amount_check CHECK (amount >= 0 OR holder_type = 'system');
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id varchar(36) PRIMARY KEY,
+    tx_id varchar(36), -- transactions row settled by this entry (NULL for internal entries)
+    type varchar(20) NOT NULL,
+    memo text,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id varchar(36) NOT NULL,
+    account_id varchar(36) NOT NULL,
+    direction varchar(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount numeric(12,2) NOT NULL CHECK (amount > 0),
+    created_at timestamptz DEFAULT now(),
+
+    CONSTRAINT fk_posting_entry FOREIGN KEY (entry_id) REFERENCES journal_entries(id),
+    CONSTRAINT fk_posting_account FOREIGN KEY (account_id) REFERENCES accounts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_tx ON journal_entries (tx_id);
+CREATE INDEX IF NOT EXISTS idx_postings_entry ON ledger_postings (entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_account ON ledger_postings (account_id, created_at);
+
+-- every journal entry must balance (sum of debits = sum of credits)
+-- checked at commit so the postings of an entry can be inserted one by one
+CREATE OR REPLACE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+DECLARE
+    net numeric;
+BEGIN
+    SELECT COALESCE(SUM(CASE WHEN direction = 'debit' THEN amount ELSE -amount END), 0)
+    INTO net
+    FROM ledger_postings
+    WHERE entry_id = NEW.entry_id;
+
+    IF net <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is unbalanced by %', NEW.entry_id, net;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER trg_journal_balanced
+AFTER INSERT ON ledger_postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE PROCEDURE check_journal_balanced();
+
+-- system accounts
+INSERT INTO accounts (id, holder_id, holder_type, amount, currency)
+VALUES
+    ('00000000-0000-0000-0000-000000000001', 'external', 'system', 0, 'inr');
+
+-- opening balances for the wallets that existed before the ledger
+INSERT INTO journal_entries (id, type, memo)
+SELECT md5('opening:' || id), 'opening', 'opening balance carried over'
+FROM accounts
+WHERE holder_type <> 'system' AND amount > 0;
+
+INSERT INTO ledger_postings (entry_id, account_id, direction, amount)
+SELECT md5('opening:' || id), '00000000-0000-0000-0000-000000000001', 'debit', amount
+FROM accounts
+WHERE holder_type <> 'system' AND amount > 0;
+
+INSERT INTO ledger_postings (entry_id, account_id, direction, amount)
+SELECT md5('opening:' || id), id, 'credit', amount
+FROM accounts
+WHERE holder_type <> 'system' AND amount > 0;
+
+UPDATE accounts
+SET amount = -(SELECT COALESCE(SUM(amount), 0) FROM accounts WHERE holder_type <> 'system')
+WHERE id = '00000000-0000-0000-0000-000000000001';
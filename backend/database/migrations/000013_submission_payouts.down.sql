@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_submission_payouts_tx;
+DROP TABLE IF EXISTS submission_payouts;
@@ -0,0 +1,16 @@
+-- =========================
+-- Submission payouts
+-- =========================
+-- links the aggregated CPM payout transactions to the submissions that earned them
+CREATE TABLE IF NOT EXISTS submission_payouts (
+    submission_id varchar(36) NOT NULL,
+    tx_id varchar(36) NOT NULL,
+    amount numeric(12,2) NOT NULL CHECK (amount >= 0),
+    created_at timestamptz DEFAULT now(),
+
+    PRIMARY KEY (submission_id, tx_id),
+    CONSTRAINT fk_payout_submission FOREIGN KEY (submission_id) REFERENCES submissions(id) ON DELETE CASCADE,
+    CONSTRAINT fk_payout_tx FOREIGN KEY (tx_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_payouts_tx ON submission_payouts (tx_id);
@@ -0,0 +1,16 @@
+ALTER TABLE campaigns
+DROP CONSTRAINT IF EXISTS campaigns_budget_check;
+
+ALTER TABLE campaigns
+ADD CONSTRAINT campaigns_budget_check CHECK (budget >= 1000) NOT VALID;
+
+-- hold accounts with history are kept, only the empty ones can be dropped
+DELETE FROM accounts a
+WHERE a.holder_type = 'campaign'
+AND NOT EXISTS (SELECT 1 FROM ledger_postings p WHERE p.account_id = a.id);
+
+ALTER TABLE accounts
+DROP CONSTRAINT IF EXISTS accounts_holder_type_check;
+
+ALTER TABLE accounts
+ADD CONSTRAINT accounts_holder_type_check CHECK (holder_type IN ('user', 'brand', 'system')) NOT VALID;
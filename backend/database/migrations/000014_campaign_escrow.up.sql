@@ -0,0 +1,55 @@
+-- =========================
+-- Campaign escrow
+-- =========================
+-- every active campaign holds its remaining budget in a 'campaign' account
+ALTER TABLE accounts
+DROP CONSTRAINT IF EXISTS accounts_holder_type_check;
+
+ALTER TABLE accounts
+ADD CONSTRAINT accounts_holder_type_check CHECK (holder_type IN ('user', 'brand', 'system', 'campaign'));
+
+-- the budget is the remaining escrowed amount, it drains to zero with payouts
+-- (the minimum launch budget is validated by the API)
+ALTER TABLE campaigns
+DROP CONSTRAINT IF EXISTS campaigns_budget_check;
+
+ALTER TABLE campaigns
+ADD CONSTRAINT campaigns_budget_check CHECK (budget >= 0);
+
+-- campaigns already running get their hold funded from the brand wallet, in
+-- launch order while the wallet covers them. The rest are funded on their
+-- first payout.
+CREATE TEMP TABLE escrow_backfill AS
+SELECT c.id AS campaign_id, a.id AS brand_acc, c.budget,
+    md5('hold:' || c.id)::uuid::text AS hold_id
+FROM campaigns c
+JOIN accounts a ON a.holder_id = c.brand_id AND a.holder_type = 'brand' AND a.active
+WHERE c.status = 1 AND c.budget > 0
+AND NOT EXISTS (
+    SELECT 1 FROM accounts h WHERE h.holder_id = c.id AND h.holder_type = 'campaign'
+)
+AND a.amount >= (
+    SELECT SUM(o.budget) FROM campaigns o
+    WHERE o.brand_id = c.brand_id AND o.status = 1 AND o.budget > 0
+    AND (o.created_at, o.id) <= (c.created_at, c.id)
+);
+
+INSERT INTO accounts (id, holder_id, holder_type, amount)
+SELECT hold_id, campaign_id, 'campaign', budget FROM escrow_backfill;
+
+INSERT INTO journal_entries (id, type, memo)
+SELECT md5('escrow:' || campaign_id), 'campaign_hold', 'escrow for campaign ' || campaign_id
+FROM escrow_backfill;
+
+INSERT INTO ledger_postings (entry_id, account_id, direction, amount)
+SELECT md5('escrow:' || campaign_id), brand_acc, 'debit', budget FROM escrow_backfill;
+
+INSERT INTO ledger_postings (entry_id, account_id, direction, amount)
+SELECT md5('escrow:' || campaign_id), hold_id, 'credit', budget FROM escrow_backfill;
+
+UPDATE accounts a
+SET amount = a.amount - b.total
+FROM (SELECT brand_acc, SUM(budget) AS total FROM escrow_backfill GROUP BY brand_acc) b
+WHERE a.id = b.brand_acc;
+
+DROP TABLE escrow_backfill;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_withdrawals_holder;
+DROP INDEX IF EXISTS idx_withdrawals_status;
+DROP TABLE IF EXISTS withdrawal_requests;
+
+DELETE FROM accounts a
+WHERE a.id = '00000000-0000-0000-0000-000000000003'
+AND NOT EXISTS (SELECT 1 FROM ledger_postings p WHERE p.account_id = a.id);
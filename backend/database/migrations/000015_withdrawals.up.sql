@@ -0,0 +1,28 @@
+-- =========================
+-- Withdrawal requests
+-- =========================
+-- pending requests park the funds in the withdrawal hold system account
+INSERT INTO accounts (id, holder_id, holder_type, amount, currency)
+VALUES ('00000000-0000-0000-0000-000000000003', 'withdrawal_hold', 'system', 0, 'inr')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS withdrawal_requests (
+    id varchar(36) PRIMARY KEY,
+    account_id varchar(36) NOT NULL,
+    holder_id varchar(36) NOT NULL,
+    amount numeric(12,2) NOT NULL CHECK (amount > 0),
+    currency varchar(3) NOT NULL DEFAULT 'inr' CHECK (currency IN ('inr', 'usd', 'yen')),
+    status varchar(10) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'paid', 'failed')),
+    reason text, -- rejection/failure reason
+    reviewed_by varchar(36), -- admin who approved/rejected the request
+    tx_id varchar(36), -- withdraw transaction once paid
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+
+    CONSTRAINT fk_withdrawal_account FOREIGN KEY (account_id) REFERENCES accounts(id),
+    CONSTRAINT fk_withdrawal_tx FOREIGN KEY (tx_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_withdrawals_status ON withdrawal_requests (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_holder ON withdrawal_requests (holder_id, created_at);
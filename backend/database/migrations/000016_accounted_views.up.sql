@@ -0,0 +1,9 @@
+-- =========================
+-- Accounted views watermark
+-- =========================
+-- views up to the watermark have been paid out, it only ever moves forward
+ALTER TABLE submissions
+    ADD COLUMN IF NOT EXISTS accounted_views int NOT NULL DEFAULT 0 CHECK (accounted_views >= 0);
+
+-- the views synced so far were already paid
+UPDATE submissions SET accounted_views = views;
@@ -0,0 +1,3 @@
+ALTER TABLE submissions
+    DROP COLUMN IF EXISTS video_status_changed_at,
+    ALTER COLUMN video_status DROP NOT NULL;
@@ -0,0 +1,11 @@
+-- =========================
+-- Video availability
+-- =========================
+-- available, unlisted, region_blocked, age_restricted, private, deleted
+-- submissions used to be created as 'active'
+UPDATE submissions SET video_status = 'available'
+WHERE video_status IS NULL OR video_status IN ('', 'active');
+
+ALTER TABLE submissions
+    ALTER COLUMN video_status SET NOT NULL,
+    ADD COLUMN IF NOT EXISTS video_status_changed_at TIMESTAMPTZ;
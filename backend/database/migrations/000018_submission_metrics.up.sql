@@ -0,0 +1,21 @@
+-- =========================
+-- Submission metrics history
+-- =========================
+-- one point per successful poll, older points are rolled up into hours and days
+CREATE TABLE IF NOT EXISTS submission_metrics (
+    id bigserial PRIMARY KEY,
+    submission_id varchar(36) NOT NULL REFERENCES submissions (id) ON DELETE CASCADE,
+    views int NOT NULL CHECK (views >= 0),
+    like_count int NOT NULL DEFAULT 0,
+    views_delta int NOT NULL DEFAULT 0, -- change since the previous point
+    earnings_delta numeric(12,2) NOT NULL DEFAULT 0,
+    source varchar(30) NOT NULL,
+    resolution varchar(10) NOT NULL DEFAULT 'raw' CHECK (resolution IN ('raw', 'hour', 'day')),
+    recorded_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_metrics_series
+ON submission_metrics (submission_id, recorded_at);
+
+CREATE INDEX IF NOT EXISTS idx_submission_metrics_rollup
+ON submission_metrics (resolution, recorded_at);
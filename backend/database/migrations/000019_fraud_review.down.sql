@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fraud_decisions;
+DROP TABLE IF EXISTS fraud_flags;
+ALTER TABLE submissions DROP COLUMN IF EXISTS payouts_held;
@@ -0,0 +1,37 @@
+-- =========================
+-- Fraud review queue
+-- =========================
+-- views of a held submission are not paid until its flag is cleared
+ALTER TABLE submissions
+    ADD COLUMN IF NOT EXISTS payouts_held boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS fraud_flags (
+    id varchar(36) PRIMARY KEY,
+    submission_id varchar(36) NOT NULL REFERENCES submissions (id) ON DELETE CASCADE,
+    rules text[] NOT NULL, -- detection rules that fired
+    evidence jsonb NOT NULL,
+    status varchar(10) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'cleared', 'confirmed')),
+    reviewed_by varchar(36), -- admin who closed the flag
+    note text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    reviewed_at timestamptz
+);
+
+-- a submission is under one review at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fraud_flags_open
+ON fraud_flags (submission_id) WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS idx_fraud_flags_status ON fraud_flags (status, created_at);
+
+-- every hold and review decision with the evidence it was taken on
+CREATE TABLE IF NOT EXISTS fraud_decisions (
+    id varchar(36) PRIMARY KEY,
+    flag_id varchar(36) NOT NULL REFERENCES fraud_flags (id) ON DELETE CASCADE,
+    decision varchar(10) NOT NULL CHECK (decision IN ('held', 'cleared', 'confirmed')),
+    decided_by varchar(36) NOT NULL, -- admin id, or 'system' for the detector
+    note text,
+    evidence jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_flag ON fraud_decisions (flag_id, created_at);
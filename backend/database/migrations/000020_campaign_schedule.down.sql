@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_campaigns_ends_at;
+DROP INDEX IF EXISTS idx_campaigns_starts_at;
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_schedule_check;
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS ends_at,
+    DROP COLUMN IF EXISTS starts_at;
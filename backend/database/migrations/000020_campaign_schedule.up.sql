@@ -0,0 +1,17 @@
+-- =========================
+-- Campaign schedule
+-- =========================
+-- a draft campaign is activated at starts_at and an active one ended at ends_at
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS starts_at timestamptz,
+    ADD COLUMN IF NOT EXISTS ends_at timestamptz;
+
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_schedule_check
+    CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_starts_at ON campaigns (status, starts_at)
+WHERE starts_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_ends_at ON campaigns (status, ends_at)
+WHERE ends_at IS NOT NULL;
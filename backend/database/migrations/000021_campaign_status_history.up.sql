@@ -0,0 +1,16 @@
+-- =========================
+-- Campaign status history
+-- =========================
+-- every status a campaign went through, who moved it there and why
+CREATE TABLE IF NOT EXISTS campaign_status_history (
+    id varchar(36) PRIMARY KEY,
+    campaign_id varchar(36) NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    from_status int REFERENCES status (id), -- NULL when the campaign was created
+    to_status int NOT NULL REFERENCES status (id),
+    changed_by varchar(36) NOT NULL, -- brand or admin id, or 'system' for the workers
+    reason text,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaign_status_history_campaign
+ON campaign_status_history (campaign_id, created_at);
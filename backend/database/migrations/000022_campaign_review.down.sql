@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_campaigns_pending;
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS approved_by,
+    DROP COLUMN IF EXISTS approved_at;
@@ -0,0 +1,15 @@
+-- =========================
+-- Campaign review
+-- =========================
+-- a campaign goes live only once an admin approved it, the decisions and
+-- their reasons are kept in campaign_status_history
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS approved_at timestamptz,
+    ADD COLUMN IF NOT EXISTS approved_by varchar(36);
+
+-- campaigns that were live before the review existed count as approved
+UPDATE campaigns SET approved_at = created_at, approved_by = 'system'
+WHERE status IN (1, 3);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_pending ON campaigns (seq)
+WHERE status = 2;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS campaign_eligibility;
+ALTER TABLE platform_links DROP COLUMN IF EXISTS subscribers;
+ALTER TABLE users DROP COLUMN IF EXISTS country;
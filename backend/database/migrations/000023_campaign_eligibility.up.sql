@@ -0,0 +1,25 @@
+-- =========================
+-- Campaign eligibility
+-- =========================
+-- creators state where they and their audience are
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS country varchar(2);
+
+-- audience size of every linked channel
+ALTER TABLE platform_links
+    ADD COLUMN IF NOT EXISTS subscribers bigint NOT NULL DEFAULT 0 CHECK (subscribers >= 0);
+
+-- a campaign without a row, or a rule left NULL, is open to every creator
+CREATE TABLE IF NOT EXISTS campaign_eligibility (
+    campaign_id varchar(36) PRIMARY KEY,
+    required_platform varchar(10),
+    min_subscribers bigint CHECK (min_subscribers >= 0),
+    countries varchar(2)[],
+    min_age int CHECK (min_age >= 0),
+    max_age int CHECK (max_age >= 0),
+    genders varchar(1)[],
+    min_submissions int CHECK (min_submissions >= 0),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_eligibility_campaign FOREIGN KEY (campaign_id) REFERENCES campaigns (id) ON DELETE CASCADE,
+    CONSTRAINT chk_eligibility_age CHECK (min_age IS NULL OR max_age IS NULL OR min_age <= max_age)
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_campaigns_live_budget;
+DROP INDEX IF EXISTS idx_campaigns_live_cpm;
+DROP INDEX IF EXISTS idx_campaigns_search;
+
+DROP TRIGGER IF EXISTS trg_campaigns_search_vector ON campaigns;
+DROP FUNCTION IF EXISTS campaigns_search_vector();
+
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS search_vector;
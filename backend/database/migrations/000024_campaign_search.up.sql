@@ -0,0 +1,35 @@
+-- =========================
+-- Campaign search
+-- =========================
+-- full text of a campaign: title and brand name rank above the requirements
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+-- brands cannot rename themselves, so the name is only read when the
+-- campaign itself changes
+CREATE OR REPLACE FUNCTION campaigns_search_vector() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', COALESCE(NEW.title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT name FROM brands WHERE id = NEW.brand_id), ''
+        )), 'A') ||
+        setweight(to_tsvector('english', COALESCE(NEW.requirements, '')), 'B');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_campaigns_search_vector
+BEFORE INSERT OR UPDATE OF title, requirements, brand_id ON campaigns
+FOR EACH ROW EXECUTE PROCEDURE campaigns_search_vector();
+
+-- fill in the existing campaigns
+UPDATE campaigns SET title = title;
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_search ON campaigns USING GIN (search_vector);
+
+-- keyset pagination of the live campaigns for every sort order
+CREATE INDEX IF NOT EXISTS idx_campaigns_live_cpm ON campaigns (cpm DESC, seq DESC)
+WHERE status = 1;
+CREATE INDEX IF NOT EXISTS idx_campaigns_live_budget ON campaigns (budget DESC, seq DESC)
+WHERE status = 1;
@@ -0,0 +1,7 @@
+ALTER TABLE submissions
+    DROP COLUMN IF EXISTS paid_views;
+
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS max_creators,
+    DROP COLUMN IF EXISTS max_paid_views,
+    DROP COLUMN IF EXISTS max_creator_earnings;
@@ -0,0 +1,17 @@
+-- =========================
+-- Campaign payout caps
+-- =========================
+-- optional limits of a campaign, NULL leaves it unlimited
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS max_creator_earnings numeric(12,2) CHECK (max_creator_earnings > 0),
+    ADD COLUMN IF NOT EXISTS max_paid_views int CHECK (max_paid_views > 0),
+    ADD COLUMN IF NOT EXISTS max_creators int CHECK (max_creators > 0);
+
+-- views that earned money, views past a cap are still counted in views
+ALTER TABLE submissions
+    ADD COLUMN IF NOT EXISTS paid_views int NOT NULL DEFAULT 0 CHECK (paid_views >= 0);
+
+-- the views paid so far follow from the earnings
+UPDATE submissions s SET paid_views = ROUND(s.earnings * 1000 / c.cpm)
+FROM campaigns c
+WHERE c.id = s.campaign_id AND s.earnings > 0;
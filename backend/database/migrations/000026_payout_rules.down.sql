@@ -0,0 +1,5 @@
+ALTER TABLE journal_entries
+    DROP COLUMN IF EXISTS rule;
+
+DROP TABLE IF EXISTS payout_rule_awards;
+DROP TABLE IF EXISTS campaign_payout_rules;
@@ -0,0 +1,35 @@
+-- =========================
+-- Campaign payout rules
+-- =========================
+-- rules paid on top of or instead of the campaign CPM:
+--   tier      pays amount as the CPM of the views past threshold
+--   milestone pays amount once when a submission reaches threshold views
+--   flat      pays amount once for every submission (threshold is 0)
+CREATE TABLE IF NOT EXISTS campaign_payout_rules (
+    campaign_id varchar(36) NOT NULL,
+    kind varchar(10) NOT NULL CHECK (kind IN ('tier', 'milestone', 'flat')),
+    threshold int NOT NULL DEFAULT 0 CHECK (threshold >= 0),
+    amount numeric(12,2) NOT NULL CHECK (amount > 0),
+    label varchar(64),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (campaign_id, kind, threshold),
+    CONSTRAINT fk_payout_rule_campaign FOREIGN KEY (campaign_id) REFERENCES campaigns (id) ON DELETE CASCADE,
+    CONSTRAINT chk_payout_rule_threshold CHECK ((kind = 'flat') = (threshold = 0))
+);
+
+-- one-off rules already paid to a submission, kept when the rules change so
+-- a bonus is never paid twice
+CREATE TABLE IF NOT EXISTS payout_rule_awards (
+    submission_id varchar(36) NOT NULL,
+    kind varchar(10) NOT NULL,
+    threshold int NOT NULL,
+    tx_id varchar(36) NOT NULL,
+    amount numeric(12,2) NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (submission_id, kind, threshold),
+    CONSTRAINT fk_award_submission FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+);
+
+-- payout entries name the rule that produced them
+ALTER TABLE journal_entries
+    ADD COLUMN IF NOT EXISTS rule varchar(64);
@@ -0,0 +1,17 @@
+-- the statuses before the review cannot hold a revision or a rejection,
+-- those submissions are resolved before rolling back
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM submissions WHERE status IN (4, 5)) THEN
+        RAISE EXCEPTION 'submissions under revision or rejected, resolve them before rolling back';
+    END IF;
+END;
+$$;
+
+ALTER TABLE submissions
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS reviewed_by,
+    DROP COLUMN IF EXISTS review_reason,
+    ALTER COLUMN status DROP DEFAULT;
+
+DELETE FROM status WHERE id IN (4, 5);
@@ -0,0 +1,15 @@
+-- =========================
+-- Submission review
+-- =========================
+-- the status of a submission is its review: 2 pending, 1 approved,
+-- 4 revision requested, 5 rejected. Only approved submissions are synced,
+-- drafts and expired submissions of the old flow keep their status
+INSERT INTO status (id, name)
+VALUES (4, 'revision'), (5, 'rejected')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE submissions
+    ALTER COLUMN status SET DEFAULT 2,
+    ADD COLUMN IF NOT EXISTS review_reason text,
+    ADD COLUMN IF NOT EXISTS reviewed_by varchar(36),
+    ADD COLUMN IF NOT EXISTS reviewed_at timestamptz;
@@ -3,12 +3,56 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Alter-Sitanshu/campaignHub/internals"
+	"github.com/redis/go-redis/v9"
 )
 
-// QueueBatchUpdate adds an update to the batch queue
+var (
+	ErrMalformedUpdate    = errors.New("malformed batch update")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrInvalidStreamID    = errors.New("invalid stream entry id")
+)
+
+// stream entry ids are <milliseconds>-<sequence>
+var streamIDPattern = regexp.MustCompile(`^[0-9]{1,20}-[0-9]{1,20}$`)
+
+// ValidStreamID reports whether id is a complete stream entry id
+func ValidStreamID(id string) bool {
+	return streamIDPattern.MatchString(id)
+}
+
+// models
+
+// QueuedUpdate is a batch update read from the stream. It stays pending in the
+// consumer group until it is acknowledged.
+type QueuedUpdate struct {
+	ID         string // stream entry id
+	Deliveries int64  // times the entry was handed to a worker
+	Update     *internals.BatchUpdate
+}
+
+// DeadLetter is an update that could not be applied within the retry limit
+type DeadLetter struct {
+	ID         string                 `json:"id"`
+	OriginalID string                 `json:"original_id"`
+	Deliveries int64                  `json:"deliveries"`
+	Reason     string                 `json:"reason"`
+	FailedAt   string                 `json:"failed_at"`
+	Update     *internals.BatchUpdate `json:"update,omitempty"`
+	Raw        string                 `json:"raw,omitempty"` // set when the payload is malformed
+}
+
+// ==================================
+// Batch Update Queue
+// ==================================
+
+// QueueBatchUpdate appends an update to the batch stream
 func (s *Service) QueueBatchUpdate(ctx context.Context, update *internals.BatchUpdate) error {
 	// Set timestamp if not set
 	if update.Timestamp == "" {
@@ -21,46 +65,242 @@ func (s *Service) QueueBatchUpdate(ctx context.Context, update *internals.BatchU
 		return err
 	}
 
-	// Add to Redis list (FIFO queue)
-	return s.client.LPush(ctx, batchQueueKey, data).Err()
+	// Streams keep the insertion order (oldest first)
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: batchStreamKey,
+		Values: map[string]any{"data": data},
+	}).Err()
+}
+
+// creates the consumer group on first use
+func (s *Service) ensureBatchGroup(ctx context.Context) error {
+	err := s.client.XGroupCreateMkStream(ctx, batchStreamKey, batchGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
 }
 
-// GetPendingBatchUpdates retrieves pending updates
-func (s *Service) GetPendingBatchUpdates(ctx context.Context, limit int64) ([]*internals.BatchUpdate, error) {
-	// Get items from queue (FIFO - oldest first)
-	results, err := s.client.LRange(ctx, batchQueueKey, 0, limit-1).Result()
+// ReadBatchUpdates hands out up to limit updates to the consumer, oldest first.
+// Entries that another worker received but did not acknowledge within minIdle
+// are redelivered before new ones, and entries already delivered maxDeliveries
+// times are moved to the dead-letter stream instead.
+func (s *Service) ReadBatchUpdates(
+	ctx context.Context, consumer string, limit int64,
+	minIdle time.Duration, maxDeliveries int64,
+) ([]*QueuedUpdate, error) {
+	if err := s.ensureBatchGroup(ctx); err != nil {
+		return nil, err
+	}
+
+	// Redeliver the stale pending entries
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: batchStreamKey,
+		Group:  batchGroup,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  limit,
+	}).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(results) == 0 {
-		return []*internals.BatchUpdate{}, nil
+	deliveries := make(map[string]int64, len(pending))
+	ids := make([]string, 0, len(pending))
+	for _, entry := range pending {
+		deliveries[entry.ID] = entry.RetryCount
+		ids = append(ids, entry.ID)
 	}
 
-	// Remove retrieved items from queue
-	if err := s.client.LTrim(ctx, batchQueueKey, int64(len(results)), -1).Err(); err != nil {
-		return nil, err
+	output := make([]*QueuedUpdate, 0, limit)
+	if len(ids) > 0 {
+		claimed, err := s.client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   batchStreamKey,
+			Group:    batchGroup,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range claimed {
+			count := deliveries[msg.ID]
+			if count >= maxDeliveries {
+				if err := s.deadLetter(ctx, msg, count, "retry limit reached"); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			// XCLAIM counts as one more delivery
+			if queued := s.decodeQueued(ctx, msg, count+1); queued != nil {
+				output = append(output, queued)
+			}
+		}
 	}
 
-	// Deserialize
-	updates := make([]*internals.BatchUpdate, 0, len(results))
-	for _, data := range results {
-		var update internals.BatchUpdate
-		if err := json.Unmarshal([]byte(data), &update); err != nil {
-			continue // Skip malformed updates
+	// Fill the rest of the batch with new entries
+	if remaining := limit - int64(len(output)); remaining > 0 {
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    batchGroup,
+			Consumer: consumer,
+			Streams:  []string{batchStreamKey, ">"},
+			Count:    remaining,
+			Block:    -1, // do not wait for new entries
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				if queued := s.decodeQueued(ctx, msg, 1); queued != nil {
+					output = append(output, queued)
+				}
+			}
 		}
-		updates = append(updates, &update)
 	}
 
-	return updates, nil
+	return output, nil
 }
 
-// GetBatchQueueLength returns number of pending updates
+// decodes a stream entry, malformed entries go straight to the dead letters
+func (s *Service) decodeQueued(ctx context.Context, msg redis.XMessage, deliveries int64) *QueuedUpdate {
+	data, _ := msg.Values["data"].(string)
+	var update internals.BatchUpdate
+	if err := json.Unmarshal([]byte(data), &update); err != nil {
+		s.deadLetter(ctx, msg, deliveries, "malformed update")
+		return nil
+	}
+	return &QueuedUpdate{
+		ID:         msg.ID,
+		Deliveries: deliveries,
+		Update:     &update,
+	}
+}
+
+// AckBatchUpdates marks the updates as applied and drops them from the stream.
+// Call it only after the changes are committed.
+func (s *Service) AckBatchUpdates(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	pipe := s.client.TxPipeline()
+	pipe.XAck(ctx, batchStreamKey, batchGroup, ids...)
+	pipe.XDel(ctx, batchStreamKey, ids...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeadLetterBatchUpdate gives up on an update without waiting for the retry limit
+func (s *Service) DeadLetterBatchUpdate(ctx context.Context, queued *QueuedUpdate, reason string) error {
+	data, err := json.Marshal(queued.Update)
+	if err != nil {
+		return err
+	}
+	msg := redis.XMessage{ID: queued.ID, Values: map[string]any{"data": string(data)}}
+	return s.deadLetter(ctx, msg, queued.Deliveries, reason)
+}
+
+// moves the entry to the dead-letter stream and acknowledges the original
+func (s *Service) deadLetter(ctx context.Context, msg redis.XMessage, deliveries int64, reason string) error {
+	pipe := s.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: batchDeadLetterKey,
+		Values: map[string]any{
+			"data":        msg.Values["data"],
+			"original_id": msg.ID,
+			"deliveries":  deliveries,
+			"reason":      reason,
+			"failed_at":   time.Now().Format(time.RFC3339),
+		},
+	})
+	pipe.XAck(ctx, batchStreamKey, batchGroup, msg.ID)
+	pipe.XDel(ctx, batchStreamKey, msg.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetDeadBatchUpdates lists the dead letters after the cursor (entry id), oldest first
+func (s *Service) GetDeadBatchUpdates(ctx context.Context, cursor string, limit int64) ([]DeadLetter, error) {
+	start := "-"
+	if cursor != "" {
+		if !ValidStreamID(cursor) {
+			return nil, ErrInvalidStreamID
+		}
+		start = "(" + cursor // exclusive
+	}
+	msgs, err := s.client.XRangeN(ctx, batchDeadLetterKey, start, "+", limit).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]DeadLetter, 0, len(msgs))
+	for _, msg := range msgs {
+		output = append(output, decodeDeadLetter(msg))
+	}
+	return output, nil
+}
+
+func decodeDeadLetter(msg redis.XMessage) DeadLetter {
+	letter := DeadLetter{ID: msg.ID}
+	letter.OriginalID, _ = msg.Values["original_id"].(string)
+	letter.Reason, _ = msg.Values["reason"].(string)
+	letter.FailedAt, _ = msg.Values["failed_at"].(string)
+	if count, ok := msg.Values["deliveries"].(string); ok {
+		letter.Deliveries, _ = strconv.ParseInt(count, 10, 64)
+	}
+
+	data, _ := msg.Values["data"].(string)
+	var update internals.BatchUpdate
+	if err := json.Unmarshal([]byte(data), &update); err != nil {
+		letter.Raw = data
+	} else {
+		letter.Update = &update
+	}
+	return letter
+}
+
+// ReplayDeadBatchUpdate puts a dead letter back on the batch stream with a
+// fresh retry budget
+func (s *Service) ReplayDeadBatchUpdate(ctx context.Context, id string) error {
+	if !ValidStreamID(id) {
+		return ErrInvalidStreamID
+	}
+	msgs, err := s.client.XRangeN(ctx, batchDeadLetterKey, id, id, 1).Result()
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return ErrDeadLetterNotFound
+	}
+	letter := decodeDeadLetter(msgs[0])
+	if letter.Update == nil {
+		// replaying would only dead letter it again
+		return ErrMalformedUpdate
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: batchStreamKey,
+		Values: map[string]any{"data": msgs[0].Values["data"]},
+	})
+	pipe.XDel(ctx, batchDeadLetterKey, id)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetBatchQueueLength returns number of updates waiting or not yet acknowledged
 func (s *Service) GetBatchQueueLength(ctx context.Context) (int64, error) {
-	return s.client.LLen(ctx, batchQueueKey).Result()
+	return s.client.XLen(ctx, batchStreamKey).Result()
+}
+
+// GetDeadLetterLength returns number of dead letters
+func (s *Service) GetDeadLetterLength(ctx context.Context) (int64, error) {
+	return s.client.XLen(ctx, batchDeadLetterKey).Result()
 }
 
 // ClearBatchQueue removes all pending updates (emergency use)
 func (s *Service) ClearBatchQueue(ctx context.Context) error {
-	return s.client.Del(ctx, batchQueueKey).Err()
+	return s.client.Del(ctx, batchStreamKey).Err()
 }
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBatchQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	// the queue is shared, start from an empty one
+	MockCache.ClearBatchQueue(ctx)
+	MockCache.Delete(ctx, batchDeadLetterKey)
+	defer func() {
+		MockCache.ClearBatchQueue(ctx)
+		MockCache.Delete(ctx, batchDeadLetterKey)
+		cancel()
+	}()
+
+	subs := []string{uuid.NewString(), uuid.NewString()}
+	for _, id := range subs {
+		if err := MockCache.QueueBatchUpdate(ctx, &internals.BatchUpdate{SubmissionID: id, NewViews: 100}); err != nil {
+			t.Fail()
+			return
+		}
+	}
+	var pendingID string
+
+	t.Run("updates are read oldest first", func(t *testing.T) {
+		queued, err := MockCache.ReadBatchUpdates(ctx, "worker-a", 10, time.Hour, 2)
+		if err != nil || len(queued) != 2 {
+			t.Fail()
+			return
+		}
+		if queued[0].Update.SubmissionID != subs[0] || queued[0].Deliveries != 1 {
+			t.Fail()
+		}
+		// delivered but not acknowledged entries are not handed out again
+		again, err := MockCache.ReadBatchUpdates(ctx, "worker-b", 10, time.Hour, 2)
+		if err != nil || len(again) != 0 {
+			t.Fail()
+		}
+		if err := MockCache.AckBatchUpdates(ctx, queued[0].ID); err != nil {
+			t.Fail()
+		}
+		if length, _ := MockCache.GetBatchQueueLength(ctx); length != 1 {
+			t.Fail()
+		}
+		pendingID = queued[1].ID
+	})
+	t.Run("stale entries are claimed by another worker", func(t *testing.T) {
+		queued, err := MockCache.ReadBatchUpdates(ctx, "worker-b", 10, 0, 3)
+		if err != nil || len(queued) != 1 {
+			t.Fail()
+			return
+		}
+		if queued[0].ID != pendingID || queued[0].Deliveries != 2 {
+			t.Fail()
+		}
+	})
+	t.Run("entries out of retries are dead lettered", func(t *testing.T) {
+		queued, err := MockCache.ReadBatchUpdates(ctx, "worker-a", 10, 0, 2)
+		if err != nil || len(queued) != 0 {
+			t.Fail()
+		}
+		letters, err := MockCache.GetDeadBatchUpdates(ctx, "", 10)
+		if err != nil || len(letters) != 1 {
+			t.Fail()
+			return
+		}
+		if letters[0].OriginalID != pendingID || letters[0].Update == nil ||
+			letters[0].Update.SubmissionID != subs[1] || letters[0].Deliveries != 2 {
+			t.Fail()
+		}
+		if length, _ := MockCache.GetBatchQueueLength(ctx); length != 0 {
+			t.Fail()
+		}
+	})
+	t.Run("dead letters are replayed", func(t *testing.T) {
+		letters, _ := MockCache.GetDeadBatchUpdates(ctx, "", 10)
+		if len(letters) != 1 {
+			t.Fail()
+			return
+		}
+		if err := MockCache.ReplayDeadBatchUpdate(ctx, letters[0].ID); err != nil {
+			t.Fail()
+		}
+		if length, _ := MockCache.GetDeadLetterLength(ctx); length != 0 {
+			t.Fail()
+		}
+		queued, err := MockCache.ReadBatchUpdates(ctx, "worker-a", 10, time.Hour, 2)
+		if err != nil || len(queued) != 1 || queued[0].Deliveries != 1 {
+			t.Fail()
+			return
+		}
+		MockCache.AckBatchUpdates(ctx, queued[0].ID)
+		if err := MockCache.ReplayDeadBatchUpdate(ctx, letters[0].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+			t.Fail()
+		}
+	})
+	t.Run("malformed entries are dead lettered", func(t *testing.T) {
+		err := MockCache.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: batchStreamKey,
+			Values: map[string]any{"data": "not json"},
+		}).Err()
+		if err != nil {
+			t.Fail()
+			return
+		}
+		queued, err := MockCache.ReadBatchUpdates(ctx, "worker-a", 10, time.Hour, 2)
+		if err != nil || len(queued) != 0 {
+			t.Fail()
+		}
+		letters, _ := MockCache.GetDeadBatchUpdates(ctx, "", 10)
+		if len(letters) != 1 || letters[0].Raw != "not json" {
+			t.Fail()
+			return
+		}
+		if err := MockCache.ReplayDeadBatchUpdate(ctx, letters[0].ID); !errors.Is(err, ErrMalformedUpdate) {
+			t.Fail()
+		}
+	})
+	t.Run("invalid ids are refused", func(t *testing.T) {
+		for _, id := range []string{"", "-", "+", "123", "1-2-3", "abc-1"} {
+			if err := MockCache.ReplayDeadBatchUpdate(ctx, id); !errors.Is(err, ErrInvalidStreamID) {
+				t.Fail()
+			}
+		}
+		if _, err := MockCache.GetDeadBatchUpdates(ctx, "(0", 10); !errors.Is(err, ErrInvalidStreamID) {
+			t.Fail()
+		}
+	})
+}
@@ -17,6 +17,10 @@ const (
 	TTLActiveCamps   = 5 * time.Minute
 	TTLVideoMetadata = 10 * time.Minute
 	TTLBatchQueue    = 30 * time.Minute
+	TTLIdempotency   = 24 * time.Hour
+	TTLIdemInFlight  = 1 * time.Minute // lock held while the first request runs
+	TTLQueuedViews   = 7 * 24 * time.Hour
+	TTLQuota         = 48 * time.Hour // outlives the quota day it counts
 )
 
 type Service struct {
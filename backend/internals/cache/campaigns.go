@@ -97,6 +97,14 @@ func (s *Service) RemoveActiveCampaign(ctx context.Context, campaignID string) e
 	return s.SRem(ctx, key, campaignID)
 }
 
+// drops an ended campaign from the active set along with its cached copy
+func (s *Service) RemoveEndedCampaign(ctx context.Context, campaignID string) error {
+	if err := s.RemoveActiveCampaign(ctx, campaignID); err != nil {
+		return err
+	}
+	return s.Delete(ctx, CampaignKey(campaignID), CampaignBudgetKey(campaignID))
+}
+
 // ==================================
 // Company Campaigns List
 // ==================================
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// models
+type IdempotentResponse struct {
+	RequestHash string `json:"request_hash"` // hash of the method, path and body
+	Done        bool   `json:"done"`         // false while the first request is running
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// ==================================
+// Idempotency Keys
+// ==================================
+
+// Reserves the key for the first request. Returns false with the stored record
+// when the key was already used.
+func (s *Service) ReserveIdempotencyKey(ctx context.Context, ownerID, key, hash string,
+) (bool, *IdempotentResponse, error) {
+	cacheKey := IdempotencyKey(ownerID, key)
+	data, err := json.Marshal(IdempotentResponse{RequestHash: hash})
+	if err != nil {
+		return false, nil, err
+	}
+	ok, err := s.client.SetNX(ctx, cacheKey, data, TTLIdemInFlight).Result()
+	if err != nil || ok {
+		return ok, nil, err
+	}
+
+	var existing IdempotentResponse
+	if err := s.GetJSON(ctx, cacheKey, &existing); err != nil {
+		if err == redis.Nil {
+			// expired in between, let the caller retry
+			return false, &IdempotentResponse{RequestHash: hash}, nil
+		}
+		return false, nil, err
+	}
+	return false, &existing, nil
+}
+
+// Extends the reservation of a request that is still running so a slow
+// request does not lose its key to a repeat
+func (s *Service) ExtendIdempotencyKey(ctx context.Context, ownerID, key string) error {
+	return s.client.Expire(ctx, IdempotencyKey(ownerID, key), TTLIdemInFlight).Err()
+}
+
+// Stores the final response to replay it for the repeated requests
+func (s *Service) SaveIdempotentResponse(ctx context.Context, ownerID, key string, resp *IdempotentResponse) error {
+	resp.Done = true
+	return s.SetJSON(ctx, IdempotencyKey(ownerID, key), resp, TTLIdempotency)
+}
+
+// Frees the key so that the request can be retried (used when the first attempt failed)
+func (s *Service) ReleaseIdempotencyKey(ctx context.Context, ownerID, key string) error {
+	return s.Delete(ctx, IdempotencyKey(ownerID, key))
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	owner, other, key := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	defer func() {
+		MockCache.ReleaseIdempotencyKey(ctx, owner, key)
+		MockCache.ReleaseIdempotencyKey(ctx, other, key)
+		cancel()
+	}()
+
+	t.Run("first request reserves the key", func(t *testing.T) {
+		reserved, _, err := MockCache.ReserveIdempotencyKey(ctx, owner, key, "hash")
+		if err != nil || !reserved {
+			t.Fail()
+		}
+	})
+	t.Run("repeat while in flight", func(t *testing.T) {
+		reserved, existing, err := MockCache.ReserveIdempotencyKey(ctx, owner, key, "hash")
+		if err != nil || reserved || existing.Done || existing.RequestHash != "hash" {
+			t.Fail()
+		}
+		if err := MockCache.ExtendIdempotencyKey(ctx, owner, key); err != nil {
+			t.Fail()
+		}
+		ttl, _ := MockCache.client.TTL(ctx, IdempotencyKey(owner, key)).Result()
+		if ttl <= 0 || ttl > TTLIdemInFlight {
+			t.Fail()
+		}
+	})
+	t.Run("finished request is replayed", func(t *testing.T) {
+		err := MockCache.SaveIdempotentResponse(ctx, owner, key, &IdempotentResponse{
+			RequestHash: "hash",
+			Status:      201,
+			ContentType: "application/json",
+			Body:        []byte(`{"ok":true}`),
+		})
+		if err != nil {
+			t.Fail()
+			return
+		}
+		reserved, existing, err := MockCache.ReserveIdempotencyKey(ctx, owner, key, "other")
+		if err != nil || reserved || !existing.Done || existing.Status != 201 || existing.RequestHash != "hash" {
+			t.Fail()
+		}
+	})
+	t.Run("released key can be reserved again", func(t *testing.T) {
+		if err := MockCache.ReleaseIdempotencyKey(ctx, owner, key); err != nil {
+			t.Fail()
+		}
+		reserved, _, err := MockCache.ReserveIdempotencyKey(ctx, owner, key, "hash")
+		if err != nil || !reserved {
+			t.Fail()
+		}
+	})
+	t.Run("keys are scoped to the owner", func(t *testing.T) {
+		reserved, _, err := MockCache.ReserveIdempotencyKey(ctx, other, key, "hash")
+		if err != nil || !reserved {
+			t.Fail()
+		}
+	})
+}
@@ -14,9 +14,15 @@ const (
 	keyUserBalance         = "balance:%s"
 	keyUserProfile         = "user:%s"
 	keySubmissionStatus    = "status:%s"
+	keyQueuedViews         = "views:queued:%s"
 	keyPendingApplications = "applications:pending:%s"
 	keyVideoMetaData       = "video:metadata:%s"
-	batchQueueKey          = "queue:batch:updates"
+	batchStreamKey         = "stream:batch:updates"
+	batchDeadLetterKey     = "stream:batch:dead"
+	batchGroup             = "batch-workers" // consumer group of the batch workers
+	keyIdempotency         = "idempotency:%s:%s"
+	keyQuota               = "quota:%s:%s" // platform, quota day
+	keyDeferredSyncs       = "deferred:sync:%s"
 )
 
 // Key builders
@@ -60,6 +66,10 @@ func SubmissionStatusKey(submissionID string) string {
 	return fmt.Sprintf(keySubmissionStatus, submissionID)
 }
 
+func QueuedViewsKey(submissionID string) string {
+	return fmt.Sprintf(keyQueuedViews, submissionID)
+}
+
 func PendingApplicationsKey(companyID string) string {
 	return fmt.Sprintf(keyPendingApplications, companyID)
 }
@@ -67,3 +77,15 @@ func PendingApplicationsKey(companyID string) string {
 func ActiveCampaignsKey() string {
 	return keyActiveCampaigns
 }
+
+func IdempotencyKey(ownerID, key string) string {
+	return fmt.Sprintf(keyIdempotency, ownerID, key)
+}
+
+func QuotaKey(platform, day string) string {
+	return fmt.Sprintf(keyQuota, platform, day)
+}
+
+func DeferredSyncsKey(platform string) string {
+	return fmt.Sprintf(keyDeferredSyncs, platform)
+}
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/Alter-Sitanshu/campaignHub/env"
+	"github.com/joho/godotenv"
+)
+
+var MockCache *Service
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DOCKER_ENV") != "true" {
+		if err := godotenv.Load("../../../.env"); err != nil {
+			log.Println("No .env file found — relying on environment variables")
+		} else {
+			log.Println(".env file loaded successfully")
+		}
+	}
+	client, err := NewClient(env.GetString("REDIS_URL", ""))
+	if err != nil {
+		log.Fatalf("failed to ping redis: %v", err)
+	}
+	MockCache = NewService(client)
+	os.Exit(m.Run())
+}
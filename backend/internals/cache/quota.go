@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==================================
+// Platform API Quota
+// ==================================
+
+// charges the units only if the total of the quota day stays within the
+// budget, in one step so concurrent pollers cannot overspend
+var reserveQuotaScript = redis.NewScript(`
+local spent = 0
+for _, units in ipairs(redis.call('HVALS', KEYS[1])) do
+	spent = spent + tonumber(units)
+end
+if spent + tonumber(ARGV[2]) > tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// ReserveQuota charges the units of an API method to a quota day. Returns
+// false without charging anything when they do not fit the budget.
+func (s *Service) ReserveQuota(ctx context.Context, platform, day, method string, units, budget int64) (bool, error) {
+	ok, err := reserveQuotaScript.Run(ctx, s.client, []string{QuotaKey(platform, day)},
+		method, units, budget, int64(TTLQuota.Seconds()),
+	).Int()
+	return ok == 1, err
+}
+
+// GetQuotaUsage returns the units spent per API method during a quota day
+func (s *Service) GetQuotaUsage(ctx context.Context, platform, day string) (map[string]int64, error) {
+	fields, err := s.client.HGetAll(ctx, QuotaKey(platform, day)).Result()
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]int64, len(fields))
+	for method, units := range fields {
+		usage[method], _ = strconv.ParseInt(units, 10, 64)
+	}
+	return usage, nil
+}
+
+// DeferSync remembers the sync frequency a submission had before the low
+// quota slowed it down. The first frequency is kept when it is deferred again,
+// false tells the submission was deferred already.
+func (s *Service) DeferSync(ctx context.Context, platform, submissionID string, frequency int) (bool, error) {
+	return s.client.HSetNX(ctx, DeferredSyncsKey(platform), submissionID, frequency).Result()
+}
+
+// TakeDeferredSyncs returns the frequencies to restore once the quota
+// recovered and forgets them
+func (s *Service) TakeDeferredSyncs(ctx context.Context, platform string) (map[string]int, error) {
+	key := DeferredSyncsKey(platform)
+	pipe := s.client.TxPipeline()
+	fields := pipe.HGetAll(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	deferred := make(map[string]int, len(fields.Val()))
+	for submissionID, frequency := range fields.Val() {
+		deferred[submissionID], _ = strconv.Atoi(frequency)
+	}
+	return deferred, nil
+}
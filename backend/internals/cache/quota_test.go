@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestQuota(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	platform, day := uuid.NewString(), "2026-03-10"
+	defer func() {
+		MockCache.Delete(ctx, QuotaKey(platform, day), DeferredSyncsKey(platform))
+		cancel()
+	}()
+
+	t.Run("concurrent reservations stay within the budget", func(t *testing.T) {
+		var granted atomic.Int64
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := MockCache.ReserveQuota(ctx, platform, day, "videos.list", 1, 10)
+				if err != nil {
+					t.Fail()
+				}
+				if ok {
+					granted.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		usage, err := MockCache.GetQuotaUsage(ctx, platform, day)
+		if err != nil || granted.Load() != 10 || usage["videos.list"] != 10 {
+			t.Fail()
+		}
+	})
+	t.Run("deferred syncs keep the first frequency", func(t *testing.T) {
+		subID := uuid.NewString()
+		if first, err := MockCache.DeferSync(ctx, platform, subID, 30); err != nil || !first {
+			t.Fail()
+		}
+		if again, _ := MockCache.DeferSync(ctx, platform, subID, 60); again {
+			t.Fail()
+		}
+		deferred, err := MockCache.TakeDeferredSyncs(ctx, platform)
+		if err != nil || len(deferred) != 1 || deferred[subID] != 30 {
+			t.Fail()
+		}
+		if deferred, _ := MockCache.TakeDeferredSyncs(ctx, platform); len(deferred) != 0 {
+			t.Fail()
+		}
+	})
+}
@@ -3,6 +3,8 @@ package cache
 import (
 	"context"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 // ==================================
@@ -29,6 +31,47 @@ func (s *Service) InvalidateSubmissionEarnings(ctx context.Context, submissionID
 	return s.Delete(ctx, key)
 }
 
+// ==================================
+// Queued Views Watermark
+// ==================================
+
+// moves the watermark forward and returns where it was, or -1 if it would
+// move by less than the minimum
+var claimViewsScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	current = ARGV[2]
+end
+if tonumber(ARGV[1]) - tonumber(current) < tonumber(ARGV[4]) then
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[3])
+return tonumber(current)
+`)
+
+// ClaimQueuedViews reserves the views up to newViews for queueing when at least
+// minDelta of them are unclaimed. It returns the start of the claimed range, or
+// false when there is not enough left (e.g. another poll queued them already).
+// accounted is the db watermark, used when nothing is cached.
+func (s *Service) ClaimQueuedViews(ctx context.Context, submissionID string, newViews, accounted, minDelta int) (int, bool, error) {
+	key := QueuedViewsKey(submissionID)
+	from, err := claimViewsScript.Run(ctx, s.client, []string{key},
+		newViews, accounted, int(TTLQueuedViews.Seconds()), minDelta,
+	).Int()
+	if err != nil {
+		return 0, false, err
+	}
+	if from < 0 {
+		return 0, false, nil
+	}
+	return from, true, nil
+}
+
+// ResetQueuedViews drops the cached watermark so the next claim starts from the db
+func (s *Service) ResetQueuedViews(ctx context.Context, submissionID string) error {
+	return s.Delete(ctx, QueuedViewsKey(submissionID))
+}
+
 // ==================================
 // Submission Status Operations
 // ==================================
@@ -17,6 +17,7 @@ type UserResponse struct {
 	Amount         float64    `json:"amount" binding:"required,min=0"`
 	Currency       string     `json:"currency"`
 	Age            int        `json:"age" binding:"required"`
+	Country        string     `json:"country"`
 	ProfilePicture string     `json:"picture"`
 	PlatformLinks  []db.Links `json:"links" binding:"required"`
 }
@@ -40,6 +41,11 @@ func (s *Service) UpdateUserBalance(ctx context.Context, userID string, delta fl
 	return s.IncrByFloat(ctx, key, delta)
 }
 
+func (s *Service) InvalidateUserBalance(ctx context.Context, userID string) error {
+	key := UserBalanceKey(userID)
+	return s.Delete(ctx, key)
+}
+
 // ==================================
 // User Profile
 // ==================================
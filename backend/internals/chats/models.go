@@ -168,6 +168,15 @@ func (h *Hub) SubmitMessage(req *MessageRequest) {
 	h.processMessage <- req
 }
 
+// Notify pushes a server side event to a single client (dropped if offline)
+func (h *Hub) Notify(userID string, payload map[string]any) {
+	h.broadcast <- &BroadcastMessage{
+		Type:    "direct",
+		UserID:  userID,
+		Payload: payload,
+	}
+}
+
 func (h *Hub) FlushClientMsgBuffers(clientID string) {
 	clientConversationIds := h.Store.getConversationIdtoFlush(context.TODO(), clientID)
 	if clientConversationIds == nil {
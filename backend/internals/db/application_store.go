@@ -187,6 +187,10 @@ func (s *ApplicationStore) CreateApplication(
 		INSERT INTO applications (id, campaign_id, creator_id)
 		VALUES ($1, $2, $3)
 	`
+	// the creator has to meet the campaign's eligibility rules
+	if err := checkEligibility(ctx, s.db, appl.CampaignId, appl.CreatorId); err != nil {
+		return err
+	}
 	_, err := s.db.ExecContext(ctx, query,
 		appl.Id, appl.CampaignId, appl.CreatorId,
 	)
@@ -213,7 +217,28 @@ func (s *ApplicationStore) SetApplicationStatus(
 		SET status = $1
 		WHERE id = $2
 	`
-	res, err := s.db.ExecContext(ctx, query, status, appl_id)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("server error: %v", err.Error())
+		return ErrServer
+	}
+	defer tx.Rollback()
+	// a campaign accepts no more creators than its cap
+	if status == ApplicationApprove {
+		err = acceptWithinCap(ctx, tx, appl_id)
+		switch err {
+		case nil:
+		case sql.ErrNoRows:
+			log.Printf("invalid application id received\n")
+			return sql.ErrNoRows
+		case ErrCreatorLimit:
+			return err
+		default:
+			log.Printf("server error: %v", err.Error())
+			return ErrServer
+		}
+	}
+	res, err := tx.ExecContext(ctx, query, status, appl_id)
 
 	if err != nil {
 		log.Printf("server error: %v", err.Error())
@@ -226,7 +251,7 @@ func (s *ApplicationStore) SetApplicationStatus(
 	}
 
 	// successfully updated the application status
-	return nil
+	return tx.Commit()
 }
 
 func (s *ApplicationStore) DeleteApplication(
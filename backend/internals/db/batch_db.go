@@ -5,8 +5,11 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
+	"time"
 
 	"github.com/Alter-Sitanshu/campaignHub/internals"
+	"github.com/google/uuid"
 )
 
 type BatchRepository struct {
@@ -17,7 +20,9 @@ func NewBatchRepository(db *sql.DB) *BatchRepository {
 	return &BatchRepository{db: db}
 }
 
-// updates multiple submissions in a single transaction
+// updates the synced metadata of multiple submissions in a single transaction.
+// Views only move forward so a replayed update changes nothing, the earnings
+// are booked together with the payouts.
 func (r *BatchRepository) BatchUpdateSubmissions(ctx context.Context, updates []*internals.BatchUpdate) error {
 	// nothing to update
 	if len(updates) == 0 {
@@ -35,13 +40,11 @@ func (r *BatchRepository) BatchUpdateSubmissions(ctx context.Context, updates []
 	stmt, err := tx.PrepareContext(ctx, `
         UPDATE submissions 
         SET 
-            views = views + $1,
-            earnings = earnings + $2,
-            like_count = $3,
-            video_title = COALESCE(NULLIF($4, ''), video_title),
+            views = GREATEST(views, $1),
+            like_count = $2,
+            video_title = COALESCE(NULLIF($3, ''), video_title),
             last_synced_at = NOW()
-        WHERE id = $5
-            AND views + $1 >= 0
+        WHERE id = $4
     `)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
@@ -52,8 +55,7 @@ func (r *BatchRepository) BatchUpdateSubmissions(ctx context.Context, updates []
 	successCount := 0
 	for _, update := range updates {
 		_, err := stmt.ExecContext(ctx,
-			update.ViewsDelta,
-			update.EarningsDelta,
+			update.NewViews,
 			update.LikeCount,
 			update.VideoTitle,
 			update.SubmissionID,
@@ -76,65 +78,390 @@ func (r *BatchRepository) BatchUpdateSubmissions(ctx context.Context, updates []
 	return nil
 }
 
-// updates multiple creator balances
-func (r *BatchRepository) BatchUpdateCreatorBalances(ctx context.Context, updates map[string]float64) error {
-	if len(updates) == 0 {
-		return nil
+// CampaignPayout aggregates the CPM earnings of one creator in one campaign
+// for a single batch run
+type CampaignPayout struct {
+	TxId        string               // set once the payout is settled
+	CampaignID  string               // campaign being charged
+	CreatorID   string               // creator being paid
+	BrandID     string               // brand funding the campaign, set while settling
+	Submissions map[string]float64   // submission id -> earnings share
+	Views       map[string]ViewRange // submission id -> views the share pays for
+	Paid        map[string]int       // submission id -> views paid once claimed, set while settling
+	Awards      []RuleAward          // one-off payout rules paid with the CPM, set while settling
+	Capped      float64              // earnings cut off by the campaign caps or the budget
+	Remaining   float64              // budget left once the payout settled
+	Funded      float64              // budget spent so far plus the remaining budget
+	Exhausted   bool                 // the payout used up the budget and ended the campaign
+	Failed      bool                 // the payout rolled back, its updates are delivered again
+	Held        []string             // submissions under fraud review, left out of the payout
+	QueuedAt    time.Time            // latest time one of its updates was queued, zero if unknown
+	Ended       bool                 // paid after the campaign ended for views accounted while it was active
+}
+
+// ViewRange is the span of views (From, To] covered by an earnings share
+type ViewRange struct {
+	From int
+	To   int
+}
+
+// total amount of the payout rounded share by share so that it always
+// matches the amounts linked to the submissions
+func (p *CampaignPayout) Amount() float64 {
+	amount := p.shares()
+	for _, award := range p.Awards {
+		amount += award.Amount
 	}
+	return roundCents(amount)
+}
 
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+// shares is the CPM part of the payout
+func (p *CampaignPayout) shares() float64 {
+	var cents int64
+	for _, share := range p.Submissions {
+		cents += int64(math.Round(share * 100))
 	}
-	defer tx.Rollback()
+	return float64(cents) / 100
+}
 
-	stmt, err := tx.PrepareContext(ctx, `
-        UPDATE accounts 
-        SET amount = amount + $1 
-        WHERE holder_id = $2
-    `)
-	if err != nil {
-		return err
+// capShares scales the shares down so the payout fits the budget. Shares
+// are rounded down to the cent so their total never goes over it.
+func (p *CampaignPayout) capShares(budget float64) {
+	amount := p.shares()
+	budget = max(budget, 0)
+	for subID, share := range p.Submissions {
+		// the epsilon keeps float noise from costing a whole cent
+		p.Submissions[subID] = math.Floor(share*budget/amount*100+1e-6) / 100
 	}
-	defer stmt.Close()
+	p.Capped = roundCents(p.Capped + amount - p.shares())
+}
 
-	for creatorID, delta := range updates {
-		if _, err := stmt.ExecContext(ctx, delta, creatorID); err != nil {
-			log.Printf("Failed to update creator %s balance: %v", creatorID, err)
+// BatchPayouts settles every aggregated payout in its own transaction.
+// Shares with a view range first advance the accounted views watermark of
+// their submission and are cut down to the views beyond it, so a range of
+// views is paid only once however often it is queued. The campaign budget is reduced, the campaign hold (escrow) is debited and the
+// creator account credited through a payout transaction, and the transaction is linked
+// back to the submissions that produced the earnings. A payout is capped at
+// the remaining budget and the campaign is ended once nothing is left. Views
+// queued while the campaign was active are paid after it ended, the hold is
+// funded again from the brand wallet for them. Later views are claimed but
+// not paid.
+// Milestones reached are paid with the CPM, and every payout rule gets its
+// own ledger entry. Flat fees are paid when the submission is approved.
+// Shares of submissions held for a fraud review are left out and keep their
+// views above the watermark.
+// A failing payout is logged and skipped so it does not block the rest of the batch.
+func (r *BatchRepository) BatchPayouts(ctx context.Context, payouts []*CampaignPayout) error {
+	settled := 0
+	for _, payout := range payouts {
+		// capped views still move the watermark and can reach a milestone
+		if payout.Amount() <= 0 && len(payout.Views) == 0 {
+			continue
+		}
+		if err := r.settlePayout(ctx, payout); err != nil {
+			log.Printf("Failed payout for campaign %s creator %s: %v", payout.CampaignID, payout.CreatorID, err)
+			// nothing of the rolled back transaction reached the ledger
+			payout.Failed = true
+			payout.TxId, payout.Capped, payout.Awards, payout.Exhausted = "", 0, nil, false
+			payout.Held = nil
+			continue
 		}
+		settled++
 	}
 
-	return tx.Commit()
+	log.Printf("Batch settled %d/%d payouts", settled, len(payouts))
+	return nil
 }
 
-// BatchUpdateCampaignBudgets updates multiple campaign budgets
-func (r *BatchRepository) BatchUpdateCampaignBudgets(ctx context.Context, updates map[string]float64) error {
-	if len(updates) == 0 {
-		return nil
-	}
-
+func (r *BatchRepository) settlePayout(ctx context.Context, payout *CampaignPayout) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, `
+	// the campaign is locked first so concurrent payouts see the same budget
+	brandID, budget, status, err := lockCampaign(ctx, tx, payout.CampaignID)
+	if err != nil {
+		return fmt.Errorf("campaign: %w", err)
+	}
+	payout.BrandID = brandID
+	if err := claimViews(ctx, tx, payout); err != nil {
+		return err
+	}
+	if status != ActiveStatus {
+		endedAt, err := campaignEndedAt(ctx, tx, payout.CampaignID)
+		if err != nil {
+			return err
+		}
+		if payout.QueuedAt.IsZero() || endedAt.IsZero() || !payout.QueuedAt.Before(endedAt) {
+			// views queued after the end are not paid, they are only claimed
+			payout.Capped = payout.Amount()
+			clear(payout.Submissions)
+			return tx.Commit()
+		}
+		payout.Ended = true
+	}
+	cpm, caps, err := campaignCaps(ctx, tx, payout.CampaignID)
+	if err != nil {
+		return err
+	}
+	rules, err := payoutRules(ctx, tx, payout.CampaignID)
+	if err != nil {
+		return err
+	}
+	if err := applyCaps(ctx, tx, payout, caps, cpm, rules); err != nil {
+		return err
+	}
+	overBudget := payout.shares() > budget
+	if overBudget {
+		payout.capShares(budget)
+	}
+	if err := awardRules(ctx, tx, payout, caps, budget); err != nil {
+		return err
+	}
+	amount := payout.Amount()
+	if amount <= 0 && !overBudget {
+		// every view was paid already or the caps were reached
+		return tx.Commit()
+	}
+	budgetQuery := `
         UPDATE campaigns 
         SET budget = budget - $1 
         WHERE id = $2
             AND budget - $1 >= 0
-    `)
+    `
+	accountQuery := `
+		SELECT id FROM accounts
+		WHERE holder_id = $1 AND holder_type = $2 AND active = $3
+	`
+	spentQuery := `
+		SELECT COALESCE(SUM(amount), 0) FROM transactions
+		WHERE from_id = $1 AND type = $2 AND status = $3
+	`
+	logQuery := `
+		INSERT INTO transactions (id, from_id, to_id, amount, status, type)
+	    VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	linkQuery := `
+		INSERT INTO submission_payouts (submission_id, tx_id, amount)
+		VALUES ($1, $2, $3)
+	`
+	earningsQuery := `
+		UPDATE submissions
+		SET earnings = earnings + $1, paid_views = paid_views + $2
+		WHERE id = $3
+	`
+
+	var holdAcc, creatorAcc string
+	var spent float64
+	// the remaining budget sits in the campaign hold
+	if holdAcc, err = fundedHold(ctx, tx, payout.CampaignID, brandID, budget); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, spentQuery, holdAcc, EntryPayout, SuccessTxStatus).Scan(&spent); err != nil {
+		return fmt.Errorf("spent budget: %w", err)
+	}
+	payout.Funded = roundCents(spent + budget)
+	payout.Remaining = roundCents(budget - amount)
+	if amount <= 0 {
+		// the budget was already down to the last fraction of a cent
+		clear(payout.Submissions)
+		if payout.Ended {
+			return tx.Commit()
+		}
+		return exhaustCampaign(ctx, tx, payout)
+	}
+	if payout.Ended {
+		// the hold went back to the brand when the campaign ended
+		if err := syncHold(ctx, tx, payout.CampaignID, brandID, amount); err != nil {
+			return fmt.Errorf("fund ended campaign hold: %w", err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, budgetQuery, amount, payout.CampaignID)
 	if err != nil {
+		return fmt.Errorf("budget: %w", err)
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return fmt.Errorf("budget: %w", ErrInsufficientFund)
+	}
+	if err := tx.QueryRowContext(ctx, accountQuery, payout.CreatorID, "user", true).Scan(&creatorAcc); err != nil {
+		return fmt.Errorf("creator account: %w", err)
+	}
+
+	txID := uuid.New().String()
+	if _, err := tx.ExecContext(ctx, logQuery, txID, holdAcc, creatorAcc, amount, SuccessTxStatus, EntryPayout); err != nil {
+		return fmt.Errorf("log transaction: %w", err)
+	}
+	// one entry per payout rule so the statement shows what paid the creator
+	for rule, ruleAmount := range ruleEntries(payout, cpm, rules) {
+		entry := transfer(uuid.New().String(), txID, EntryPayout,
+			fmt.Sprintf("%s earnings for campaign %s", rule, payout.CampaignID),
+			holdAcc, creatorAcc, ruleAmount)
+		entry.Rule = rule
+		if err := postEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+	if err := recordAwards(ctx, tx, payout, txID); err != nil {
 		return err
 	}
-	defer stmt.Close()
+	earned := make(map[string]float64, len(payout.Submissions))
+	for subID, share := range payout.Submissions {
+		earned[subID] = roundCents(share)
+	}
+	for _, award := range payout.Awards {
+		earned[award.SubmissionID] = roundCents(earned[award.SubmissionID] + award.Amount)
+	}
+	for subID, subAmount := range earned {
+		if subAmount <= 0 {
+			// a capped share paid nothing
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, linkQuery, subID, txID, subAmount); err != nil {
+			return fmt.Errorf("link submission %s: %w", subID, err)
+		}
+		if _, err := tx.ExecContext(ctx, earningsQuery, subAmount, payout.Paid[subID], subID); err != nil {
+			return fmt.Errorf("earnings of submission %s: %w", subID, err)
+		}
+	}
+	if payout.Remaining < 0.01 && !payout.Ended {
+		if err := exhaustCampaign(ctx, tx, payout); err != nil {
+			return err
+		}
+		payout.TxId = txID
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	payout.TxId = txID
+	return nil
+}
 
-	for campaignID, amount := range updates {
-		if _, err := stmt.ExecContext(ctx, amount, campaignID); err != nil {
-			log.Printf("Failed to update campaign %s budget: %v", campaignID, err)
+// exhaustCampaign ends the campaign whose budget was used up by the payout
+// and commits the payout transaction
+func exhaustCampaign(ctx context.Context, tx *sql.Tx, payout *CampaignPayout) error {
+	err := transitionCampaign(ctx, tx, CampaignTransition{
+		CampaignID: payout.CampaignID,
+		To:         ExpiredStatus,
+		ActorID:    CampaignSystemActor,
+		Reason:     "budget exhausted",
+	})
+	if err != nil {
+		return fmt.Errorf("end campaign: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	payout.Exhausted = true
+	return nil
+}
+
+// campaignEndedAt returns when the campaign last stopped being active, zero
+// if it never was
+func campaignEndedAt(ctx context.Context, tx *sql.Tx, campaignID string) (time.Time, error) {
+	query := `
+		SELECT MAX(created_at) FROM campaign_status_history
+		WHERE campaign_id = $1 AND from_status = $2
+	`
+	var endedAt sql.NullTime
+	if err := tx.QueryRowContext(ctx, query, campaignID, ActiveStatus).Scan(&endedAt); err != nil {
+		return time.Time{}, fmt.Errorf("campaign end: %w", err)
+	}
+	return endedAt.Time, nil
+}
+
+// claimViews moves the accounted views watermark of every ranged share up to
+// the end of its range and scales the share down to the views that were not
+// accounted yet. Shares that are fully accounted are dropped, shares of held
+// submissions are set aside in payout.Held.
+func claimViews(ctx context.Context, tx *sql.Tx, payout *CampaignPayout) error {
+	lockQuery := `
+		SELECT accounted_views, payouts_held FROM submissions
+		WHERE id = $1
+		FOR UPDATE
+	`
+	claimQuery := `
+		UPDATE submissions
+		SET accounted_views = $1, views = GREATEST(views, $1)
+		WHERE id = $2
+	`
+	if payout.Paid == nil {
+		payout.Paid = make(map[string]int, len(payout.Views))
+	}
+	for subID, views := range payout.Views {
+		share, ok := payout.Submissions[subID]
+		if !ok {
+			continue
+		}
+		var accounted int
+		var held bool
+		if err := tx.QueryRowContext(ctx, lockQuery, subID).Scan(&accounted, &held); err != nil {
+			return fmt.Errorf("watermark of submission %s: %w", subID, err)
+		}
+		if held {
+			// flagged after the update was queued, paid once the flag is cleared
+			delete(payout.Submissions, subID)
+			delete(payout.Views, subID)
+			payout.Held = append(payout.Held, subID)
+			continue
+		}
+		if views.To <= accounted {
+			delete(payout.Submissions, subID)
+			continue
+		}
+		from := max(views.From, accounted)
+		if from > views.From && views.To > views.From {
+			share = share * float64(views.To-from) / float64(views.To-views.From)
+			payout.Submissions[subID] = share
+		}
+		payout.Paid[subID] = views.To - from
+		payout.Views[subID] = ViewRange{From: from, To: views.To}
+		if _, err := tx.ExecContext(ctx, claimQuery, views.To, subID); err != nil {
+			return fmt.Errorf("claim views of submission %s: %w", subID, err)
+		}
+	}
+	return nil
+}
+
+// lists the payout transactions that paid out a submission
+func (r *BatchRepository) GetSubmissionPayouts(ctx context.Context, submissionID string) ([]Transaction, error) {
+	query := `
+		SELECT t.id, t.from_id, t.to_id, sp.amount, t.currency, t.status, t.type, t.created_at
+		FROM submission_payouts sp
+		JOIN transactions t ON t.id = sp.tx_id
+		WHERE sp.submission_id = $1
+		ORDER BY t.created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, submissionID)
+	if err != nil {
+		log.Printf("error fetching payouts for %s: %v\n", submissionID, err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []Transaction
+	for rows.Next() {
+		var ts Transaction
+		err := rows.Scan(
+			&ts.Id,
+			&ts.FromId,
+			&ts.ToId,
+			&ts.Amount,
+			&ts.Currency,
+			&ts.Status,
+			&ts.Type,
+			&ts.CretaedAt,
+		)
+		if err != nil {
+			log.Printf("error scanning payout: %v\n", err.Error())
+			return nil, err
 		}
+		output = append(output, ts)
 	}
 
-	return tx.Commit()
+	return output, nil
 }
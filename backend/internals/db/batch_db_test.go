@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBatchPayouts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	// create mock creator
+	uid := uuid.New().String()
+	generateCreator(ctx, uid)
+
+	// create a mock brand with a campaign
+	bid := uuid.New().String()
+	generateBrand(bid)
+	campID := uuid.New().String()
+	query := `
+		INSERT INTO campaigns (id, brand_id, title, budget, cpm, requirements, platform, doc_link, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	MockCampaignStore.db.ExecContext(ctx, query,
+		campID, bid, "payout_campaign", 5000.0, 100.0, "", "youtube", "", DraftStatus,
+	)
+	subID := uuid.New().String()
+	query = `
+		INSERT INTO submissions (id, creator_id, campaign_id, url, status, video_platform, video_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	MockSubStore.db.ExecContext(ctx, query, subID, uid, campID, "mock_url", ActiveStatus, "youtube", "available")
+
+	// Create their accounts
+	user_acc := generateAccounts(ctx, uid, "user")
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	// escrow the budget
+	MockCampaignStore.ActivateCampaign(ctx, campID)
+	defer func() {
+		destroyAllTransactions()
+		destroyHold(ctx, campID)
+		destroyAccounts(ctx, user_acc.Id, brand_acc.Id)
+		destroySubmissions(ctx, []string{subID})
+		destroyCampaign(ctx, []string{campID})
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+
+	t.Run("aggregated payout per campaign and creator", func(t *testing.T) {
+		payout := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 250.0},
+		}
+		if err := MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout}); err != nil {
+			t.Fail()
+			return
+		}
+		if payout.TxId == "" {
+			log.Println("payout was not settled")
+			t.Fail()
+			return
+		}
+		updated_uacc, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		updated_bacc, _ := MockTsStore.GetAccount(ctx, brand_acc.Id)
+		if updated_uacc.Amount != user_acc.Amount+250.0 {
+			t.Fail()
+		}
+		// the brand only lost the escrowed budget
+		if updated_bacc.Amount != brand_acc.Amount-5000.0 {
+			t.Fail()
+		}
+		campaign, _ := MockCampaignStore.GetCampaign(ctx, campID)
+		if campaign.Budget != 5000.0-250.0 {
+			t.Fail()
+		}
+		// the payout is linked to the submission
+		txs, err := MockBatchStore.GetSubmissionPayouts(ctx, subID)
+		if err != nil || len(txs) != 1 || txs[0].Id != payout.TxId {
+			t.Fail()
+		}
+	})
+	t.Run("failed payout is reset", func(t *testing.T) {
+		// a creator without an account fails the payout after it was capped
+		payout := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uuid.New().String(),
+			Submissions: map[string]float64{subID: 10000.0},
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout})
+		if !payout.Failed || payout.TxId != "" || payout.Capped != 0 || payout.Exhausted {
+			t.Fail()
+		}
+		campaign, _ := MockCampaignStore.GetCampaign(ctx, campID)
+		if campaign.Budget != 5000.0-250.0 {
+			t.Fail()
+		}
+	})
+	t.Run("view range is paid only once", func(t *testing.T) {
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		payout := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 100.0},
+			Views:       map[string]ViewRange{subID: {From: 0, To: 1000}},
+		}
+		if err := MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout}); err != nil || payout.TxId == "" {
+			t.Fail()
+			return
+		}
+		// the same range queued again pays nothing
+		replay := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 100.0},
+			Views:       map[string]ViewRange{subID: {From: 0, To: 1000}},
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{replay})
+		if replay.TxId != "" {
+			log.Println("replayed range was paid again")
+			t.Fail()
+		}
+		// an overlapping range pays only the new half
+		overlap := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 100.0},
+			Views:       map[string]ViewRange{subID: {From: 500, To: 1500}},
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{overlap})
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		if after.Amount != before.Amount+150.0 {
+			log.Printf("expected %v got %v\n", before.Amount+150.0, after.Amount)
+			t.Fail()
+		}
+		var accounted int
+		MockSubStore.db.QueryRowContext(ctx, `SELECT accounted_views FROM submissions WHERE id = $1`, subID).Scan(&accounted)
+		if accounted != 1500 {
+			t.Fail()
+		}
+	})
+	t.Run("held submission is left out", func(t *testing.T) {
+		// flagged after the update was queued
+		MockSubStore.db.ExecContext(ctx, `UPDATE submissions SET payouts_held = true WHERE id = $1`, subID)
+		defer MockSubStore.db.ExecContext(ctx, `UPDATE submissions SET payouts_held = false WHERE id = $1`, subID)
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		payout := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 50.0},
+			Views:       map[string]ViewRange{subID: {From: 1500, To: 2000}},
+		}
+		if err := MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout}); err != nil {
+			t.Fatal(err)
+		}
+		if payout.TxId != "" || payout.Failed || len(payout.Held) != 1 || payout.Held[0] != subID {
+			t.Fail()
+		}
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		var accounted int
+		MockSubStore.db.QueryRowContext(ctx, `SELECT accounted_views FROM submissions WHERE id = $1`, subID).Scan(&accounted)
+		// the views wait above the watermark for the review
+		if after.Amount != before.Amount || accounted != 1500 {
+			t.Fail()
+		}
+	})
+	t.Run("payout is capped at the budget and ends the campaign", func(t *testing.T) {
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		// 4600 is left of the budget
+		payout := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 5000.0},
+			Views:       map[string]ViewRange{subID: {From: 1500, To: 51500}},
+		}
+		if err := MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout}); err != nil || payout.TxId == "" {
+			t.Fail()
+			return
+		}
+		if !payout.Exhausted || payout.Capped != 400.0 || payout.Remaining != 0 || payout.Funded != 5000.0 {
+			log.Printf("capped: %v, remaining: %v, funded: %v\n", payout.Capped, payout.Remaining, payout.Funded)
+			t.Fail()
+		}
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		if after.Amount != before.Amount+4600.0 {
+			log.Printf("expected %v got %v\n", before.Amount+4600.0, after.Amount)
+			t.Fail()
+		}
+		campaign, _ := MockCampaignStore.GetCampaign(ctx, campID)
+		if campaign.Status != ExpiredStatus || campaign.Budget != 0 {
+			t.Fail()
+		}
+		// later views of the ended campaign are claimed but not paid
+		late := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 10.0},
+			Views:       map[string]ViewRange{subID: {From: 51500, To: 51600}},
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{late})
+		if late.TxId != "" || late.Amount() != 0 || late.Capped != 10.0 {
+			t.Fail()
+		}
+	})
+}
+
+func TestEndedCampaignPayouts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	uid := uuid.New().String()
+	generateCreator(ctx, uid)
+	bid := uuid.New().String()
+	generateBrand(bid)
+	campID := uuid.New().String()
+	query := `
+		INSERT INTO campaigns (id, brand_id, title, budget, cpm, requirements, platform, doc_link, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	MockCampaignStore.db.ExecContext(ctx, query,
+		campID, bid, "ended_campaign", 5000.0, 100.0, "", "youtube", "", DraftStatus,
+	)
+	subID := uuid.New().String()
+	query = `
+		INSERT INTO submissions (id, creator_id, campaign_id, url, status, video_platform, video_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	MockSubStore.db.ExecContext(ctx, query, subID, uid, campID, "mock_url", ActiveStatus, "youtube", "available")
+	user_acc := generateAccounts(ctx, uid, "user")
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	MockCampaignStore.ActivateCampaign(ctx, campID)
+	// views accounted while active are still queued when the brand stops it
+	queued := time.Now().Add(-time.Minute)
+	MockCampaignStore.TransitionCampaign(ctx, CampaignTransition{
+		CampaignID: campID,
+		To:         ExpiredStatus,
+		ActorID:    bid,
+		Reason:     "stopped",
+	})
+	defer func() {
+		destroyAllTransactions()
+		destroyHold(ctx, campID)
+		destroyAccounts(ctx, user_acc.Id, brand_acc.Id)
+		destroySubmissions(ctx, []string{subID})
+		destroyCampaign(ctx, []string{campID})
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+
+	t.Run("views queued before the end are paid", func(t *testing.T) {
+		payout := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 250.0},
+			Views:       map[string]ViewRange{subID: {From: 0, To: 2500}},
+			QueuedAt:    queued,
+		}
+		if err := MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout}); err != nil {
+			t.Fatal(err)
+		}
+		if payout.TxId == "" || !payout.Ended || payout.Exhausted {
+			t.Fatal("payout was not settled")
+		}
+		updated_uacc, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		updated_bacc, _ := MockTsStore.GetAccount(ctx, brand_acc.Id)
+		if updated_uacc.Amount != user_acc.Amount+250.0 || updated_bacc.Amount != brand_acc.Amount-250.0 {
+			t.Fail()
+		}
+		// nothing stays in escrow
+		if hold := getHold(ctx, campID); hold != nil && hold.Amount != 0 {
+			t.Fail()
+		}
+		campaign, _ := MockCampaignStore.GetCampaign(ctx, campID)
+		if campaign.Status != ExpiredStatus || campaign.Budget != 5000.0-250.0 {
+			t.Fail()
+		}
+	})
+	t.Run("views queued after the end are only claimed", func(t *testing.T) {
+		late := &CampaignPayout{
+			CampaignID:  campID,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 10.0},
+			Views:       map[string]ViewRange{subID: {From: 2500, To: 2600}},
+			QueuedAt:    time.Now().Add(time.Minute),
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{late})
+		if late.TxId != "" || late.Capped != 10.0 {
+			t.Fail()
+		}
+		var accounted int
+		MockSubStore.db.QueryRowContext(ctx, `SELECT accounted_views FROM submissions WHERE id = $1`, subID).Scan(&accounted)
+		if accounted != 2600 {
+			t.Fail()
+		}
+	})
+}
@@ -342,31 +342,36 @@ func (b *BrandStore) UpdateBrand(ctx context.Context, brand_id string, payload B
 
 func (b *BrandStore) GetStats(ctx context.Context, brand_id string) (*BrandStat, error) {
 	var output BrandStat
+	// spend is the successful payouts out of the brand's campaign holds
 	query := `
 		SELECT
-		b.id AS brand_id,
-		b.name AS brand_name,
-		COUNT(DISTINCT camp.id) AS total_campaigns,
-		COUNT(DISTINCT a.id)    AS total_applications,
+			b.id AS brand_id,
+			b.name AS brand_name,
+			(SELECT COUNT(*) FROM campaigns camp WHERE camp.brand_id = b.id) AS total_campaigns,
+			(
+				SELECT COUNT(*) FROM applications a
+				JOIN campaigns camp ON camp.id = a.campaign_id
+				WHERE camp.brand_id = b.id
+			) AS total_applications,
 
-		COUNT(DISTINCT t.id)    AS total_transactions,
-		COALESCE(SUM(t.amount), 0) AS total_spend
+			COUNT(t.id) AS total_transactions,
+			COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'payout' AND t.status = $2), 0) AS total_spend
 
 		FROM brands b
 
-		LEFT JOIN campaigns camp
-			ON camp.brand_id = b.id
-
-		LEFT JOIN applications a
-			ON a.campaign_id = camp.id
+		LEFT JOIN accounts acc
+			ON (acc.holder_id = b.id AND acc.holder_type = 'brand')
+			OR (acc.holder_type = 'campaign' AND acc.holder_id IN (
+				SELECT camp.id FROM campaigns camp WHERE camp.brand_id = b.id
+			))
 
 		LEFT JOIN transactions t
-			ON t.from_id = b.id
-			
-		GROUP BY b.id
-		HAVING b.id = $1
+			ON t.from_id = acc.id
+
+		WHERE b.id = $1
+		GROUP BY b.id, b.name
 	`
-	err := b.db.QueryRowContext(ctx, query, brand_id).Scan(
+	err := b.db.QueryRowContext(ctx, query, brand_id, SuccessTxStatus).Scan(
 		&output.BrandId,
 		&output.Name,
 		&output.TotalCampaigns,
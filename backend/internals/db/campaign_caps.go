@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrCreatorLimit = errors.New("campaign accepted the maximum number of creators")
+
+// Limits a brand puts on a campaign, a nil limit is unlimited
+type CampaignCaps struct {
+	MaxCreatorEarnings *float64 `json:"max_creator_earnings,omitempty"` // paid to one creator over the campaign
+	MaxPaidViews       *int     `json:"max_paid_views,omitempty"`       // paid views of one submission
+	MaxCreators        *int     `json:"max_creators,omitempty"`         // accepted applications
+}
+
+// Validate the caps of a new campaign
+func (c *CampaignCaps) Validate() error {
+	if (c.MaxCreatorEarnings != nil && *c.MaxCreatorEarnings <= 0) ||
+		(c.MaxPaidViews != nil && *c.MaxPaidViews <= 0) ||
+		(c.MaxCreators != nil && *c.MaxCreators <= 0) {
+		return fmt.Errorf("%w: caps must be positive", ErrInvalidArgs)
+	}
+	return nil
+}
+
+// PayableViews returns how many of the new views still earn once the
+// submission was paid for paid views
+func (c *CampaignCaps) PayableViews(views, paid int) int {
+	if c.MaxPaidViews == nil {
+		return views
+	}
+	return max(0, min(views, *c.MaxPaidViews-paid))
+}
+
+// PayableEarnings returns how much of the earnings the creator still gets
+// once they earned earned in the campaign
+func (c *CampaignCaps) PayableEarnings(earnings, earned float64) float64 {
+	if c.MaxCreatorEarnings == nil {
+		return earnings
+	}
+	return max(0, min(earnings, roundCents(*c.MaxCreatorEarnings-earned)))
+}
+
+// campaignCaps reads the CPM and the payout caps of a campaign locked by
+// the transaction
+func campaignCaps(ctx context.Context, tx *sql.Tx, id string) (float64, *CampaignCaps, error) {
+	query := `
+		SELECT cpm, max_creator_earnings, max_paid_views, max_creators
+		FROM campaigns
+		WHERE id = $1
+	`
+	var cpm float64
+	var caps CampaignCaps
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&cpm,
+		&caps.MaxCreatorEarnings,
+		&caps.MaxPaidViews,
+		&caps.MaxCreators,
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("caps: %w", err)
+	}
+	return cpm, &caps, nil
+}
+
+// applyCaps cuts the shares of a payout down to the campaign caps. A share
+// pays at most the earnings of the views its submission still gets paid for,
+// so a share the polling worker capped already is left as it is. The whole
+// payout is then scaled to the earnings the creator has left
+func applyCaps(ctx context.Context, tx *sql.Tx, payout *CampaignPayout, caps *CampaignCaps,
+	cpm float64, rules []PayoutRule,
+) error {
+	if caps.MaxPaidViews != nil {
+		before := payout.Amount()
+		paidQuery := `SELECT paid_views FROM submissions WHERE id = $1`
+		for subID, views := range payout.Paid {
+			share, ok := payout.Submissions[subID]
+			if !ok || views <= 0 {
+				continue
+			}
+			var paid int
+			if err := tx.QueryRowContext(ctx, paidQuery, subID).Scan(&paid); err != nil {
+				return fmt.Errorf("paid views of submission %s: %w", subID, err)
+			}
+			payable := caps.PayableViews(views, paid)
+			if payable < views {
+				from := payout.Views[subID].From
+				earnings := ViewEarnings(cpm, rules, from, from+payable)
+				payout.Submissions[subID] = min(share, math.Floor(earnings*100+1e-6)/100)
+				payout.Paid[subID] = payable
+			}
+		}
+		payout.Capped = roundCents(payout.Capped + before - payout.Amount())
+	}
+	if caps.MaxCreatorEarnings != nil {
+		earnedQuery := `
+			SELECT COALESCE(SUM(earnings), 0) FROM submissions
+			WHERE campaign_id = $1 AND creator_id = $2
+		`
+		var earned float64
+		if err := tx.QueryRowContext(ctx, earnedQuery, payout.CampaignID, payout.CreatorID).Scan(&earned); err != nil {
+			return fmt.Errorf("creator earnings: %w", err)
+		}
+		if amount := payout.Amount(); amount > 0 {
+			if payable := caps.PayableEarnings(amount, earned); payable < amount {
+				payout.capShares(payable)
+			}
+		}
+	}
+	return nil
+}
+
+// acceptWithinCap fails when the campaign of the application already
+// accepted as many creators as it allows
+func acceptWithinCap(ctx context.Context, tx *sql.Tx, applID string) error {
+	lockQuery := `
+		SELECT c.id, c.max_creators
+		FROM campaigns c
+		JOIN applications a ON a.campaign_id = c.id
+		WHERE a.id = $1
+		FOR UPDATE OF c
+	`
+	countQuery := `
+		SELECT COUNT(*) FROM applications
+		WHERE campaign_id = $1 AND status = $2 AND id <> $3
+	`
+	var campaignID string
+	var maxCreators *int
+	// the campaign row serialises concurrent acceptances
+	if err := tx.QueryRowContext(ctx, lockQuery, applID).Scan(&campaignID, &maxCreators); err != nil {
+		return err
+	}
+	if maxCreators == nil {
+		return nil
+	}
+	var accepted int
+	if err := tx.QueryRowContext(ctx, countQuery, campaignID, ApplicationApprove, applID).Scan(&accepted); err != nil {
+		return err
+	}
+	if accepted >= *maxCreators {
+		return ErrCreatorLimit
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCampaignCaps(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	uid := generateCreator(ctx, uuid.New().String())
+	other := generateCreator(ctx, uuid.New().String())
+	bid := uuid.New().String()
+	generateBrand(bid)
+	maxEarnings, maxViews, maxCreators := 150.0, 1000, 1
+	campaign := Campaign{
+		Id:       uuid.New().String(),
+		BrandId:  bid,
+		Title:    "capped_campaign",
+		Budget:   5000,
+		CPM:      100,
+		Platform: "youtube",
+		Status:   DraftStatus,
+		CampaignCaps: CampaignCaps{
+			MaxCreatorEarnings: &maxEarnings,
+			MaxPaidViews:       &maxViews,
+			MaxCreators:        &maxCreators,
+		},
+	}
+	subIDs := []string{uuid.New().String(), uuid.New().String()}
+	var appls []string
+	user_acc := generateAccounts(ctx, uid, "user")
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	defer func() {
+		destroyApplications(ctx, appls)
+		destroyAllTransactions()
+		destroyHold(ctx, campaign.Id)
+		destroyAccounts(ctx, user_acc.Id, brand_acc.Id)
+		destroySubmissions(ctx, subIDs)
+		destroyCampaign(ctx, []string{campaign.Id})
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		destroyCreator(ctx, other)
+		cancel()
+	}()
+	if err := MockCampaignStore.LaunchCampaign(ctx, &campaign); err != nil {
+		log.Printf("error launching campaign: %v", err)
+		t.Fail()
+		return
+	}
+	MockCampaignStore.ActivateCampaign(ctx, campaign.Id)
+	query := `
+		INSERT INTO submissions (id, creator_id, campaign_id, url, status, video_platform, video_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	for _, id := range subIDs {
+		MockSubStore.db.ExecContext(ctx, query, id, uid, campaign.Id, "mock_url", ActiveStatus, "youtube", "available")
+	}
+
+	t.Run("caps are read back", func(t *testing.T) {
+		got, err := MockCampaignStore.GetCampaign(ctx, campaign.Id)
+		if err != nil || got.MaxPaidViews == nil || *got.MaxPaidViews != maxViews ||
+			got.MaxCreators == nil || *got.MaxCreators != maxCreators {
+			t.Fail()
+		}
+		zero := 0.0
+		bad := Campaign{Id: uuid.New().String(), BrandId: bid, Status: DraftStatus}
+		bad.MaxCreatorEarnings = &zero
+		if err := MockCampaignStore.LaunchCampaign(ctx, &bad); !errors.Is(err, ErrInvalidArgs) {
+			t.Fail()
+		}
+	})
+	t.Run("views over the cap do not earn", func(t *testing.T) {
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		payout := &CampaignPayout{
+			CampaignID:  campaign.Id,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subIDs[0]: 150.0},
+			Views:       map[string]ViewRange{subIDs[0]: {From: 0, To: 1500}},
+		}
+		if err := MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout}); err != nil || payout.TxId == "" {
+			t.Fail()
+			return
+		}
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		if after.Amount != before.Amount+100.0 || payout.Capped != 50.0 {
+			log.Printf("expected %v got %v, capped %v\n", before.Amount+100.0, after.Amount, payout.Capped)
+			t.Fail()
+		}
+		// the views are still claimed
+		var accounted, paid int
+		MockSubStore.db.QueryRowContext(ctx,
+			`SELECT accounted_views, paid_views FROM submissions WHERE id = $1`, subIDs[0],
+		).Scan(&accounted, &paid)
+		if accounted != 1500 || paid != 1000 {
+			t.Fail()
+		}
+	})
+	t.Run("creator earnings are capped", func(t *testing.T) {
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		payout := &CampaignPayout{
+			CampaignID:  campaign.Id,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subIDs[1]: 100.0},
+			Views:       map[string]ViewRange{subIDs[1]: {From: 0, To: 1000}},
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout})
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		if after.Amount != before.Amount+50.0 {
+			log.Printf("expected %v got %v\n", before.Amount+50.0, after.Amount)
+			t.Fail()
+		}
+		// nothing is left to earn
+		payout = &CampaignPayout{
+			CampaignID:  campaign.Id,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subIDs[1]: 10.0},
+			Views:       map[string]ViewRange{subIDs[1]: {From: 1000, To: 1100}},
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout})
+		if payout.TxId != "" {
+			t.Fail()
+		}
+	})
+	t.Run("accepted creators are capped", func(t *testing.T) {
+		appls = append(appls, SeedApplications(ctx, []string{campaign.Id}, uid)...)
+		appls = append(appls, SeedApplications(ctx, []string{campaign.Id}, other)...)
+		if len(appls) != 2 {
+			t.Fail()
+			return
+		}
+		if err := MockApplicationStore.SetApplicationStatus(ctx, appls[0], ApplicationApprove); err != nil {
+			t.Fail()
+		}
+		// accepting the same application again does not count it twice
+		if err := MockApplicationStore.SetApplicationStatus(ctx, appls[0], ApplicationApprove); err != nil {
+			t.Fail()
+		}
+		err := MockApplicationStore.SetApplicationStatus(ctx, appls[1], ApplicationApprove)
+		if !errors.Is(err, ErrCreatorLimit) {
+			log.Printf("expected creator limit, got %v", err)
+			t.Fail()
+		}
+		// rejecting is never limited
+		if err := MockApplicationStore.SetApplicationStatus(ctx, appls[1], ApplicationReject); err != nil {
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// sort orders of the campaign search
+const (
+	SortRelevance = "relevance" // best full-text match, needs a query
+	SortNewest    = "newest"
+	SortCPM       = "cpm"    // highest CPM first
+	SortBudget    = "budget" // most budget left first
+)
+
+// Filters of the campaign search, zero values are ignored
+type CampaignSearch struct {
+	Query     string   `form:"q"`
+	Platform  string   `form:"platform"`
+	MinCPM    *float64 `form:"min_cpm" binding:"omitempty,gte=0"`
+	MaxCPM    *float64 `form:"max_cpm" binding:"omitempty,gte=0"`
+	MinBudget *float64 `form:"min_budget" binding:"omitempty,gte=0"`
+	Sector    string   `form:"sector"`
+	Sort      string   `form:"sort" binding:"omitempty,oneof=relevance newest cpm budget"`
+}
+
+// number of matching campaigns per platform and per brand sector
+type SearchFacets struct {
+	Platforms map[string]int `json:"platforms"`
+	Sectors   map[string]int `json:"sectors"`
+}
+
+type CampaignSearchResult struct {
+	Campaigns []CampaignResp
+	Facets    SearchFacets
+	Cursor    string // opaque position after the last campaign
+	HasMore   bool
+}
+
+// sortKey returns the column the results are ordered by before seq, empty
+// when they are ordered by seq alone
+func (s *CampaignSearch) sortKey(query string) string {
+	switch s.Sort {
+	case SortCPM:
+		return "c.cpm"
+	case SortBudget:
+		return "c.budget"
+	case SortRelevance:
+		return fmt.Sprintf("ts_rank(c.search_vector, %s)", query)
+	}
+	return ""
+}
+
+// parseSearchCursor reads a "sort:key:seq" cursor, the cursor of another
+// sort order is rejected
+func parseSearchCursor(cursor, sort string) (string, int64, error) {
+	parts := strings.Split(cursor, ":")
+	if len(parts) != 3 || parts[0] != sort {
+		return "", 0, fmt.Errorf("%w: cursor", ErrInvalidArgs)
+	}
+	seq, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: cursor", ErrInvalidArgs)
+	}
+	if parts[1] != "" {
+		if _, err := strconv.ParseFloat(parts[1], 64); err != nil {
+			return "", 0, fmt.Errorf("%w: cursor", ErrInvalidArgs)
+		}
+	}
+	return parts[1], seq, nil
+}
+
+// Searches the live campaigns the creator is eligible for and has not
+// applied to yet. Results are paged with the cursor of the previous page
+func (c *CampaignStore) SearchCampaigns(
+	ctx context.Context, creatorID string, search CampaignSearch, limit int, cursor string,
+) (*CampaignSearchResult, error) {
+	search.Query = strings.TrimSpace(search.Query)
+	if search.Sort == "" {
+		search.Sort = SortNewest
+		if search.Query != "" {
+			search.Sort = SortRelevance
+		}
+	}
+	// nothing to rank without a query
+	if search.Sort == SortRelevance && search.Query == "" {
+		search.Sort = SortNewest
+	}
+
+	args := []any{creatorID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	var where strings.Builder
+	where.WriteString(`
+		FROM campaigns c
+		LEFT JOIN brands b ON c.brand_id = b.id
+		LEFT JOIN campaign_eligibility e ON e.campaign_id = c.id
+		LEFT JOIN users u ON u.id = $1
+		WHERE c.status = 1 AND NOT EXISTS (
+			SELECT 1 FROM applications a WHERE a.creator_id = $1 AND a.campaign_id = c.id
+		)
+	`)
+	where.WriteString(eligibleCreator)
+	var tsQuery string
+	if search.Query != "" {
+		tsQuery = fmt.Sprintf("websearch_to_tsquery('english', %s)", arg(search.Query))
+		where.WriteString(" AND c.search_vector @@ " + tsQuery)
+	}
+	if search.Platform != "" {
+		where.WriteString(" AND c.platform = " + arg(strings.ToLower(search.Platform)))
+	}
+	if search.MinCPM != nil {
+		where.WriteString(" AND c.cpm >= " + arg(*search.MinCPM))
+	}
+	if search.MaxCPM != nil {
+		where.WriteString(" AND c.cpm <= " + arg(*search.MaxCPM))
+	}
+	if search.MinBudget != nil {
+		where.WriteString(" AND c.budget >= " + arg(*search.MinBudget))
+	}
+	if search.Sector != "" {
+		where.WriteString(" AND b.sector = " + arg(search.Sector))
+	}
+	filters := where.String()
+
+	facets, err := c.searchFacets(ctx, filters, args)
+	if err != nil {
+		return nil, err
+	}
+
+	key := search.sortKey(tsQuery)
+	keyColumn := "NULL::float8"
+	order := "c.seq DESC"
+	if key != "" {
+		keyColumn = key
+		order = key + " DESC, c.seq DESC"
+	}
+	if cursor != "" {
+		value, seq, err := parseSearchCursor(cursor, search.Sort)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			where.WriteString(" AND c.seq < " + arg(seq))
+		} else {
+			where.WriteString(fmt.Sprintf(" AND (%s, c.seq) < (%s, %s)", key, arg(value), arg(seq)))
+		}
+	}
+	query := fmt.Sprintf(`
+		SELECT c.id, c.brand_id, b.name AS brand_name, c.title, c.budget, c.cpm,
+		c.requirements, c.platform, c.doc_link, c.status, c.accepting_applications,
+		c.starts_at, c.ends_at, c.created_at, c.seq, %s
+		%s
+		ORDER BY %s
+		LIMIT %s
+	`, keyColumn, where.String(), order, arg(limit+1))
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error searching campaigns: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &CampaignSearchResult{Facets: *facets}
+	var cursors []string
+	for rows.Next() {
+		var row CampaignResp
+		var seq int64
+		var value *float64
+		err = rows.Scan(
+			&row.Id,
+			&row.BrandId,
+			&row.Brand,
+			&row.Title,
+			&row.Budget,
+			&row.CPM,
+			&row.Req,
+			&row.Platform,
+			&row.DocLink,
+			&row.Status,
+			&row.AcceptingAppls,
+			&row.StartsAt,
+			&row.EndsAt,
+			&row.CreatedAt,
+			&seq,
+			&value,
+		)
+		if err != nil {
+			log.Printf("Error scanning campaign: %v\n", err.Error())
+			return nil, err
+		}
+		position := ""
+		if value != nil {
+			position = strconv.FormatFloat(*value, 'g', -1, 64)
+		}
+		result.Campaigns = append(result.Campaigns, row)
+		cursors = append(cursors, fmt.Sprintf("%s:%s:%d", search.Sort, position, seq))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result.HasMore = len(result.Campaigns) > limit
+	n := min(limit, len(result.Campaigns))
+	result.Campaigns = result.Campaigns[:n]
+	if n > 0 {
+		result.Cursor = cursors[n-1]
+	}
+	return result, nil
+}
+
+// searchFacets counts the campaigns matching the filters per platform and sector
+func (c *CampaignStore) searchFacets(ctx context.Context, filters string, args []any) (*SearchFacets, error) {
+	query := `
+		SELECT GROUPING(c.platform), COALESCE(c.platform, b.sector, ''), COUNT(*)
+	` + filters + `
+		GROUP BY GROUPING SETS ((c.platform), (b.sector))
+	`
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error counting search facets: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := &SearchFacets{
+		Platforms: make(map[string]int),
+		Sectors:   make(map[string]int),
+	}
+	for rows.Next() {
+		var bySector int
+		var name string
+		var count int
+		if err := rows.Scan(&bySector, &name, &count); err != nil {
+			log.Printf("Error scanning search facets: %v\n", err.Error())
+			return nil, err
+		}
+		if bySector == 1 {
+			facets.Sectors[name] = count
+		} else {
+			facets.Platforms[name] = count
+		}
+	}
+	return facets, rows.Err()
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSearchCampaigns(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	bid := uuid.New().String()
+	generateBrand(bid)
+	// keyword only these campaigns carry
+	keyword := "kw" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	var ids []string
+	defer func() {
+		destroyCampaign(ctx, ids)
+		destroyBrand(bid)
+		cancel()
+	}()
+	for i, camp := range []struct {
+		platform string
+		cpm      float64
+		budget   float64
+	}{
+		{"youtube", 50, 3000},
+		{"youtube", 150, 1000},
+		{"tiktok", 100, 2000},
+		{"tiktok", 100, 5000},
+	} {
+		campaign := Campaign{
+			Id:       uuid.New().String(),
+			BrandId:  bid,
+			Title:    "summer launch " + keyword,
+			Budget:   camp.budget,
+			CPM:      camp.cpm,
+			Req:      "mock_requirements",
+			Platform: camp.platform,
+			DocLink:  "mock_link",
+			Status:   DraftStatus,
+		}
+		if err := MockCampaignStore.LaunchCampaign(ctx, &campaign); err != nil {
+			log.Printf("error launching campaign %d: %v", i, err)
+			t.Fail()
+			return
+		}
+		ids = append(ids, campaign.Id)
+	}
+	MockCampaignStore.db.ExecContext(ctx, `UPDATE campaigns SET status = 1 WHERE brand_id = $1`, bid)
+
+	t.Run("keywords and facets", func(t *testing.T) {
+		got, err := MockCampaignStore.SearchCampaigns(ctx, "", CampaignSearch{Query: keyword}, 10, "")
+		if err != nil {
+			log.Printf("error searching campaigns: %v", err)
+			t.Fail()
+			return
+		}
+		if len(got.Campaigns) != 4 || got.HasMore || got.Facets.Platforms["youtube"] != 2 ||
+			got.Facets.Platforms["tiktok"] != 2 {
+			log.Printf("got: %d campaigns, facets: %v", len(got.Campaigns), got.Facets)
+			t.Fail()
+		}
+	})
+	t.Run("filters", func(t *testing.T) {
+		minCPM, minBudget := 90.0, 1500.0
+		got, err := MockCampaignStore.SearchCampaigns(ctx, "", CampaignSearch{
+			Query:     keyword,
+			Platform:  "TikTok",
+			MinCPM:    &minCPM,
+			MinBudget: &minBudget,
+		}, 10, "")
+		if err != nil || len(got.Campaigns) != 2 || got.Facets.Platforms["youtube"] != 0 {
+			t.Fail()
+		}
+	})
+	t.Run("sorted pages", func(t *testing.T) {
+		for sort, want := range map[string][]string{
+			SortCPM:    {ids[1], ids[3], ids[2], ids[0]},
+			SortBudget: {ids[3], ids[0], ids[2], ids[1]},
+			SortNewest: {ids[3], ids[2], ids[1], ids[0]},
+		} {
+			var order []string
+			cursor := ""
+			for page := 0; page < 4; page++ {
+				got, err := MockCampaignStore.SearchCampaigns(ctx, "", CampaignSearch{Query: keyword, Sort: sort}, 1, cursor)
+				if err != nil {
+					log.Printf("error paging %s: %v", sort, err)
+					t.Fail()
+					break
+				}
+				for _, v := range got.Campaigns {
+					order = append(order, v.Id)
+				}
+				if !got.HasMore {
+					break
+				}
+				cursor = got.Cursor
+			}
+			if strings.Join(order, ",") != strings.Join(want, ",") {
+				log.Printf("sort %s: got %v, want %v", sort, order, want)
+				t.Fail()
+			}
+		}
+	})
+	t.Run("cursor of another sort", func(t *testing.T) {
+		got, _ := MockCampaignStore.SearchCampaigns(ctx, "", CampaignSearch{Query: keyword, Sort: SortCPM}, 1, "")
+		_, err := MockCampaignStore.SearchCampaigns(ctx, "", CampaignSearch{Query: keyword, Sort: SortBudget}, 1, got.Cursor)
+		if !errors.Is(err, ErrInvalidArgs) {
+			t.Fail()
+		}
+	})
+}
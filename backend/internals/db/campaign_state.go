@@ -0,0 +1,340 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// the workers change campaign statuses as this actor
+const CampaignSystemActor = "system"
+
+// macros for campaign review decisions
+const (
+	CampaignApproved         = "approved"
+	CampaignRejected         = "rejected"
+	CampaignChangesRequested = "changes_requested"
+)
+
+var (
+	ErrInvalidTransition  = errors.New("campaign status change not allowed")
+	ErrIncompleteCampaign = errors.New("campaign details incomplete")
+	ErrReviewRequired     = errors.New("campaign needs an admin review")
+)
+
+// campaignEdges lists the statuses a campaign can move to from each status.
+// An expired campaign is final.
+var campaignEdges = map[int][]int{
+	DraftStatus:         {PendingReviewStatus, ActiveStatus, ExpiredStatus},
+	PendingReviewStatus: {DraftStatus, ActiveStatus, ExpiredStatus},
+	ActiveStatus:        {ExpiredStatus},
+}
+
+// CampaignTransition is a requested change of a campaign status
+type CampaignTransition struct {
+	CampaignID string
+	To         int
+	ActorID    string // brand or admin asking for the change, CampaignSystemActor for the workers
+	Admin      bool   // the actor reviews campaigns and may launch unapproved ones
+	Reason     string
+}
+
+// CampaignStatusChange is one row of the campaign status history
+type CampaignStatusChange struct {
+	Id         string `json:"id"`
+	CampaignId string `json:"campaign_id"`
+	From       *int   `json:"from_status"` // nil when the campaign was created
+	To         int    `json:"to_status"`
+	ChangedBy  string `json:"changed_by"`
+	Reason     string `json:"reason,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// reports whether a campaign may move between the two statuses
+func CanTransition(from, to int) bool {
+	return slices.Contains(campaignEdges[from], to)
+}
+
+// TransitionCampaign moves a campaign along one of the allowed edges once the
+// preconditions of the target status hold, applies the side effects on the
+// escrow and the conversations and records the change in the history
+func (c *CampaignStore) TransitionCampaign(ctx context.Context, t CampaignTransition) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error initialising transaction: %v\n", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := transitionCampaign(ctx, tx, t); err != nil {
+		log.Printf("Error moving campaign(%s) to status %d: %v\n", t.CampaignID, t.To, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// transitionCampaign runs a transition inside the caller's transaction
+func transitionCampaign(ctx context.Context, tx *sql.Tx, t CampaignTransition) error {
+	brandID, budget, from, err := lockCampaign(ctx, tx, t.CampaignID)
+	if err != nil {
+		return err
+	}
+	if !CanTransition(from, t.To) {
+		return fmt.Errorf("%w: %d -> %d", ErrInvalidTransition, from, t.To)
+	}
+
+	// preconditions
+	if t.To == PendingReviewStatus || t.To == ActiveStatus {
+		if err := campaignComplete(ctx, tx, t.CampaignID, budget); err != nil {
+			return err
+		}
+	}
+	if t.To == ActiveStatus && !t.Admin {
+		approved, err := campaignApproved(ctx, tx, t.CampaignID)
+		if err != nil {
+			return err
+		}
+		if from == PendingReviewStatus || !approved {
+			return ErrReviewRequired
+		}
+	}
+
+	// status change and side effects
+	switch t.To {
+	case ExpiredStatus:
+		// unspent budget goes back and the conversations close
+		if err := endCampaign(ctx, tx, t.CampaignID, brandID); err != nil {
+			return err
+		}
+	case ActiveStatus:
+		if err := setCampaignStatus(ctx, tx, t.CampaignID, t.To); err != nil {
+			return err
+		}
+		// an admin launching the campaign approves it
+		if t.Admin {
+			if err := approveCampaign(ctx, tx, t.CampaignID, t.ActorID); err != nil {
+				return err
+			}
+		}
+		// the budget is reserved from the brand wallet
+		if err := syncHold(ctx, tx, t.CampaignID, brandID, budget); err != nil {
+			return err
+		}
+	case PendingReviewStatus:
+		// a campaign sent to review is reviewed again from scratch
+		query := `
+			UPDATE campaigns
+			SET status = $1, approved_at = NULL, approved_by = NULL
+			WHERE id = $2
+		`
+		if _, err := tx.ExecContext(ctx, query, t.To, t.CampaignID); err != nil {
+			return err
+		}
+	default:
+		if err := setCampaignStatus(ctx, tx, t.CampaignID, t.To); err != nil {
+			return err
+		}
+	}
+	return recordCampaignStatus(ctx, tx, t.CampaignID, &from, t.To, t.ActorID, t.Reason)
+}
+
+// a campaign has to be fully described and funded before review or launch
+func campaignComplete(ctx context.Context, tx *sql.Tx, id string, budget float64) error {
+	var title, platform string
+	var cpm float64
+	query := `
+		SELECT title, platform, cpm FROM campaigns
+		WHERE id = $1
+	`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&title, &platform, &cpm); err != nil {
+		return err
+	}
+	if title == "" || platform == "" || cpm <= 0 || budget <= 0 {
+		return ErrIncompleteCampaign
+	}
+	return nil
+}
+
+func campaignApproved(ctx context.Context, tx *sql.Tx, id string) (bool, error) {
+	var approved bool
+	query := `
+		SELECT approved_at IS NOT NULL FROM campaigns
+		WHERE id = $1
+	`
+	err := tx.QueryRowContext(ctx, query, id).Scan(&approved)
+	return approved, err
+}
+
+// approveCampaign marks the campaign approved, an earlier approval is kept
+func approveCampaign(ctx context.Context, tx *sql.Tx, id, adminID string) error {
+	query := `
+		UPDATE campaigns
+		SET approved_at = COALESCE(approved_at, now()), approved_by = COALESCE(approved_by, $1)
+		WHERE id = $2
+	`
+	_, err := tx.ExecContext(ctx, query, adminID, id)
+	return err
+}
+
+func setCampaignStatus(ctx context.Context, tx *sql.Tx, id string, status int) error {
+	query := `
+		UPDATE campaigns
+		SET status = $1
+		WHERE id = $2
+	`
+	_, err := tx.ExecContext(ctx, query, status, id)
+	return err
+}
+
+func recordCampaignStatus(ctx context.Context, tx *sql.Tx, id string, from *int, to int, actorID, reason string) error {
+	query := `
+		INSERT INTO campaign_status_history (id, campaign_id, from_status, to_status, changed_by, reason)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`
+	if _, err := tx.ExecContext(ctx, query, uuid.New().String(), id, from, to, actorID, reason); err != nil {
+		return fmt.Errorf("status history: %w", err)
+	}
+	return nil
+}
+
+// ReviewCampaign records an admin decision on a campaign pending review and
+// returns the status it moved to. An approved campaign goes live, or waits as
+// an approved draft for the scheduler when it starts later. A rejected one
+// ends and one that needs changes goes back to its brand as a draft.
+func (c *CampaignStore) ReviewCampaign(ctx context.Context, id, adminID, decision, reason string) (int, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error initialising transaction: %v\n", err.Error())
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, _, status, err := lockCampaign(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+	if status != PendingReviewStatus {
+		return 0, ErrInvalidTransition
+	}
+
+	var to int
+	switch decision {
+	case CampaignApproved:
+		var startsLater bool
+		query := `
+			SELECT COALESCE(starts_at > now(), false) FROM campaigns
+			WHERE id = $1
+		`
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&startsLater); err != nil {
+			return 0, err
+		}
+		if err := approveCampaign(ctx, tx, id, adminID); err != nil {
+			return 0, err
+		}
+		to = ActiveStatus
+		if startsLater {
+			to = DraftStatus
+		}
+	case CampaignRejected:
+		to = ExpiredStatus
+	case CampaignChangesRequested:
+		to = DraftStatus
+	default:
+		return 0, ErrInvalidArgs
+	}
+	if reason == "" {
+		reason = decision
+	}
+	err = transitionCampaign(ctx, tx, CampaignTransition{
+		CampaignID: id,
+		To:         to,
+		ActorID:    adminID,
+		Admin:      true,
+		Reason:     reason,
+	})
+	if err != nil {
+		log.Printf("Error reviewing campaign(%s): %v\n", id, err)
+		return 0, err
+	}
+	return to, tx.Commit()
+}
+
+// lists the campaigns waiting for a review, the longest waiting first
+func (c *CampaignStore) GetPendingCampaigns(ctx context.Context, offset, limit int) ([]CampaignResp, error) {
+	query := `
+		SELECT c.id, c.brand_id, b.name AS brand, c.title, c.budget, c.cpm,
+		c.requirements, c.platform, c.doc_link, c.status, c.accepting_applications,
+		c.starts_at, c.ends_at, c.created_at
+		FROM campaigns c
+		LEFT JOIN brands b ON c.brand_id = b.id
+		WHERE c.status = $1
+		ORDER BY c.seq ASC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := c.db.QueryContext(ctx, query, PendingReviewStatus, limit, offset)
+	if err != nil {
+		log.Printf("Error fetching pending campaigns: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []CampaignResp
+	for rows.Next() {
+		var row CampaignResp
+		err = rows.Scan(
+			&row.Id,
+			&row.BrandId,
+			&row.Brand,
+			&row.Title,
+			&row.Budget,
+			&row.CPM,
+			&row.Req,
+			&row.Platform,
+			&row.DocLink,
+			&row.Status,
+			&row.AcceptingAppls,
+			&row.StartsAt,
+			&row.EndsAt,
+			&row.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("Error scanning campaign: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, row)
+	}
+	return output, rows.Err()
+}
+
+// lists the status changes of a campaign, oldest first
+func (c *CampaignStore) GetCampaignStatusHistory(ctx context.Context, id string) ([]CampaignStatusChange, error) {
+	query := `
+		SELECT id, campaign_id, from_status, to_status, changed_by, COALESCE(reason, ''), created_at
+		FROM campaign_status_history
+		WHERE campaign_id = $1
+		ORDER BY created_at ASC
+	`
+	rows, err := c.db.QueryContext(ctx, query, id)
+	if err != nil {
+		log.Printf("Error fetching campaign(%s) status history: %v\n", id, err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []CampaignStatusChange
+	for rows.Next() {
+		var row CampaignStatusChange
+		if err := rows.Scan(&row.Id, &row.CampaignId, &row.From, &row.To,
+			&row.ChangedBy, &row.Reason, &row.CreatedAt); err != nil {
+			log.Printf("Error scanning campaign status: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, row)
+	}
+	return output, rows.Err()
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCampaignTransitions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	bid := uuid.New().String()
+	generateBrand(bid)
+	temp_camp := Campaign{
+		Id:       uuid.New().String(),
+		BrandId:  bid,
+		Title:    "mock_title",
+		Budget:   1000.0,
+		CPM:      101.0,
+		Req:      "mock_requirements",
+		Platform: "youtube",
+		DocLink:  "mock_link",
+		Status:   DraftStatus,
+	}
+	incomplete := Campaign{
+		Id:      uuid.New().String(),
+		BrandId: bid,
+		Title:   "mock_title",
+		Budget:  1000.0,
+		Status:  DraftStatus,
+	}
+	MockCampaignStore.LaunchCampaign(ctx, &temp_camp)
+	MockCampaignStore.LaunchCampaign(ctx, &incomplete)
+	defer func() {
+		destroyCampaign(ctx, []string{temp_camp.Id, incomplete.Id})
+		destroyBrand(bid)
+		cancel()
+	}()
+	move := func(id string, to int, admin bool) error {
+		return MockCampaignStore.TransitionCampaign(ctx, CampaignTransition{
+			CampaignID: id,
+			To:         to,
+			ActorID:    bid,
+			Admin:      admin,
+		})
+	}
+
+	t.Run("campaigns cannot be created as expired", func(t *testing.T) {
+		expired := Campaign{Id: uuid.New().String(), BrandId: bid, Title: "mock_title", Status: ExpiredStatus}
+		if err := MockCampaignStore.LaunchCampaign(ctx, &expired); !errors.Is(err, ErrInvalidTransition) {
+			t.Fail()
+		}
+	})
+	t.Run("incomplete campaigns are not reviewed", func(t *testing.T) {
+		if err := move(incomplete.Id, PendingReviewStatus, false); !errors.Is(err, ErrIncompleteCampaign) {
+			t.Fail()
+		}
+	})
+	t.Run("leaving the review needs an admin", func(t *testing.T) {
+		if err := move(temp_camp.Id, PendingReviewStatus, false); err != nil {
+			t.Fail()
+			return
+		}
+		if err := move(temp_camp.Id, ActiveStatus, false); !errors.Is(err, ErrReviewRequired) {
+			t.Fail()
+		}
+	})
+	t.Run("ended campaigns stay ended", func(t *testing.T) {
+		if err := move(temp_camp.Id, ExpiredStatus, false); err != nil {
+			t.Fail()
+			return
+		}
+		if err := move(temp_camp.Id, ActiveStatus, true); !errors.Is(err, ErrInvalidTransition) {
+			t.Fail()
+		}
+	})
+	t.Run("every change is recorded", func(t *testing.T) {
+		history, err := MockCampaignStore.GetCampaignStatusHistory(ctx, temp_camp.Id)
+		if err != nil || len(history) != 3 {
+			log.Printf("got %d changes\n", len(history))
+			t.Fail()
+			return
+		}
+		if history[0].From != nil || history[0].To != DraftStatus ||
+			*history[1].From != DraftStatus || history[1].To != PendingReviewStatus ||
+			*history[2].From != PendingReviewStatus || history[2].To != ExpiredStatus ||
+			history[2].ChangedBy != bid {
+			t.Fail()
+		}
+	})
+}
+
+func TestCampaignReview(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	bid := uuid.New().String()
+	generateBrand(bid)
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	admin := uuid.New().String()
+	launch := func() string {
+		campaign := Campaign{
+			Id:       uuid.New().String(),
+			BrandId:  bid,
+			Title:    "mock_title",
+			Budget:   1000.0,
+			CPM:      101.0,
+			Req:      "mock_requirements",
+			Platform: "youtube",
+			DocLink:  "mock_link",
+			Status:   PendingReviewStatus,
+		}
+		MockCampaignStore.LaunchCampaign(ctx, &campaign)
+		return campaign.Id
+	}
+	approved, rejected := launch(), launch()
+	defer func() {
+		destroyCampaign(ctx, []string{approved, rejected})
+		destroyHold(ctx, approved)
+		destroyAccounts(ctx, brand_acc.Id)
+		destroyBrand(bid)
+		cancel()
+	}()
+
+	t.Run("pending campaigns are queued", func(t *testing.T) {
+		pending, err := MockCampaignStore.GetPendingCampaigns(ctx, 0, 1000)
+		if err != nil {
+			t.Fail()
+			return
+		}
+		found := 0
+		for _, campaign := range pending {
+			if campaign.Id == approved || campaign.Id == rejected {
+				found++
+			}
+		}
+		if found != 2 {
+			t.Fail()
+		}
+	})
+	t.Run("changes send the campaign back as a draft", func(t *testing.T) {
+		status, err := MockCampaignStore.ReviewCampaign(ctx, approved, admin, CampaignChangesRequested, "missing brief")
+		if err != nil || status != DraftStatus {
+			t.Fail()
+			return
+		}
+		// the brand cannot skip the review
+		err = MockCampaignStore.TransitionCampaign(ctx, CampaignTransition{
+			CampaignID: approved, To: ActiveStatus, ActorID: bid,
+		})
+		if !errors.Is(err, ErrReviewRequired) {
+			t.Fail()
+		}
+		// a draft is not under review
+		if _, err := MockCampaignStore.ReviewCampaign(ctx, approved, admin, CampaignApproved, ""); !errors.Is(err, ErrInvalidTransition) {
+			t.Fail()
+		}
+	})
+	t.Run("approval launches the campaign", func(t *testing.T) {
+		err := MockCampaignStore.TransitionCampaign(ctx, CampaignTransition{
+			CampaignID: approved, To: PendingReviewStatus, ActorID: bid,
+		})
+		if err != nil {
+			t.Fail()
+			return
+		}
+		status, err := MockCampaignStore.ReviewCampaign(ctx, approved, admin, CampaignApproved, "")
+		if err != nil || status != ActiveStatus {
+			log.Printf("status: %d, err: %v\n", status, err)
+			t.Fail()
+		}
+		if hold := getHold(ctx, approved); hold == nil || hold.Amount != 1000.0 {
+			t.Fail()
+		}
+	})
+	t.Run("rejection ends the campaign", func(t *testing.T) {
+		status, err := MockCampaignStore.ReviewCampaign(ctx, rejected, admin, CampaignRejected, "policy violation")
+		if err != nil || status != ExpiredStatus {
+			t.Fail()
+			return
+		}
+		history, _ := MockCampaignStore.GetCampaignStatusHistory(ctx, rejected)
+		if len(history) != 2 || history[1].Reason != "policy violation" || history[1].ChangedBy != admin {
+			t.Fail()
+		}
+	})
+}
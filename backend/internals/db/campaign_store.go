@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type CampaignStore struct {
@@ -21,10 +22,17 @@ type Campaign struct {
 	CPM     float64 `json:"cpm"`
 	Req     string  `json:"requirements"`
 	// added this to segregate the campaigns on the basis of platform
-	Platform  string `json:"platform"`
-	DocLink   string `json:"doc_link"`
-	Status    int    `json:"status"`
-	CreatedAt string `json:"created_at"`
+	Platform  string     `json:"platform"`
+	DocLink   string     `json:"doc_link"`
+	Status    int        `json:"status"`
+	StartsAt  *time.Time `json:"starts_at,omitempty"` // activated by the scheduler at this time
+	EndsAt    *time.Time `json:"ends_at,omitempty"`   // ended by the scheduler at this time
+	CreatedAt string     `json:"created_at"`
+	// creators allowed to see and apply to the campaign, everyone when nil
+	Eligibility *Eligibility `json:"eligibility,omitempty"`
+	// tiers, milestones and flat fee paid on top of the CPM
+	PayoutRules []PayoutRule `json:"payout_rules,omitempty"`
+	CampaignCaps
 }
 
 type CampaignResp struct {
@@ -36,28 +44,58 @@ type CampaignResp struct {
 	CPM     float64 `json:"cpm"`
 	Req     string  `json:"requirements"`
 	// added this to segregate the campaigns on the basis of platform
-	Platform       string `json:"platform"`
-	DocLink        string `json:"doc_link"`
-	Status         int    `json:"status"`
-	AcceptingAppls bool   `json:"accepting_applications"`
-	CreatedAt      string `json:"created_at"`
+	Platform       string     `json:"platform"`
+	DocLink        string     `json:"doc_link"`
+	Status         int        `json:"status"`
+	AcceptingAppls bool       `json:"accepting_applications"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	CreatedAt      string     `json:"created_at"`
+	CampaignCaps
 }
 
 // Update Campaign payload
 type UpdateCampaign struct {
 	// No option to update CPM to avoid frauds
-	Title   *string  `json:"title"`
-	Budget  *float64 `json:"budget"`
-	Req     *string  `json:"requirements"`
-	DocLink *string  `json:"doc_link"`
+	Title    *string    `json:"title"`
+	Budget   *float64   `json:"budget"`
+	Req      *string    `json:"requirements"`
+	DocLink  *string    `json:"doc_link"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	// a zero cap lifts the limit
+	MaxCreatorEarnings *float64 `json:"max_creator_earnings"`
+	MaxPaidViews       *int     `json:"max_paid_views"`
+	MaxCreators        *int     `json:"max_creators"`
 }
 
 // This function adds a new campaign record
 func (c *CampaignStore) LaunchCampaign(ctx context.Context, campaign *Campaign) error {
 	query := `
-		INSERT INTO campaigns (id, brand_id, title, budget, cpm, requirements, platform, doc_link, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO campaigns (id, brand_id, title, budget, cpm, requirements, platform, doc_link, status, starts_at, ends_at,
+		max_creator_earnings, max_paid_views, max_creators)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
+	// a campaign starts as a draft, in review or live, never ended
+	if campaign.Status != DraftStatus && campaign.Status != PendingReviewStatus && campaign.Status != ActiveStatus {
+		return ErrInvalidTransition
+	}
+	if campaign.Status != DraftStatus &&
+		(campaign.Title == "" || campaign.Platform == "" || campaign.CPM <= 0 || campaign.Budget <= 0) {
+		return ErrIncompleteCampaign
+	}
+	if campaign.Eligibility != nil {
+		campaign.Eligibility.normalise()
+		if err := campaign.Eligibility.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := campaign.CampaignCaps.Validate(); err != nil {
+		return err
+	}
+	if err := ValidatePayoutRules(campaign.PayoutRules); err != nil {
+		return err
+	}
 	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Error Beginning transaction: %v\n", err.Error())
@@ -74,6 +112,11 @@ func (c *CampaignStore) LaunchCampaign(ctx context.Context, campaign *Campaign)
 		campaign.Platform,
 		campaign.DocLink,
 		campaign.Status,
+		campaign.StartsAt,
+		campaign.EndsAt,
+		campaign.MaxCreatorEarnings,
+		campaign.MaxPaidViews,
+		campaign.MaxCreators,
 	)
 	if err != nil {
 		log.Printf("Error launching new campaign: %v\n", err.Error())
@@ -90,32 +133,64 @@ func (c *CampaignStore) LaunchCampaign(ctx context.Context, campaign *Campaign)
 		log.Printf("Error updating brand's campaign count: %v\n", err.Error())
 		return err
 	}
-	tx.Commit()
-	return nil
+	// an active campaign escrows its budget right away
+	if campaign.Status == ActiveStatus {
+		if err := syncHold(ctx, tx, campaign.Id, campaign.BrandId, campaign.Budget); err != nil {
+			log.Printf("Error escrowing campaign budget: %v\n", err.Error())
+			return err
+		}
+	}
+	if !campaign.Eligibility.Empty() {
+		if err := setEligibility(ctx, tx, campaign.Id, campaign.Eligibility); err != nil {
+			return err
+		}
+	}
+	if err := setPayoutRules(ctx, tx, campaign.Id, campaign.PayoutRules); err != nil {
+		return err
+	}
+	if err := recordCampaignStatus(ctx, tx, campaign.Id, nil, campaign.Status, campaign.BrandId, ""); err != nil {
+		log.Printf("Error recording campaign status: %v\n", err.Error())
+		return err
+	}
+	return tx.Commit()
 }
 
-// This function Ends a campaign
+// This function Ends a campaign on behalf of the system
 func (c *CampaignStore) EndCampaign(ctx context.Context, id string) error {
-	tx, err := c.db.BeginTx(ctx, nil)
-	if err != nil {
-		log.Printf("Error initialising transaction: %v\n", err.Error())
-		return err
-	}
-	defer tx.Rollback()
+	return c.TransitionCampaign(ctx, CampaignTransition{
+		CampaignID: id,
+		To:         ExpiredStatus,
+		ActorID:    CampaignSystemActor,
+	})
+}
 
+// endCampaign expires a campaign locked by the caller, returns its unspent
+// budget to the brand and closes its conversations
+func endCampaign(ctx context.Context, tx *sql.Tx, id, brandID string) error {
 	query := `
 		UPDATE campaigns
 		SET status = $1, accepting_applications = $2
 		WHERE id = $3
 	`
-	_, err = tx.ExecContext(ctx, query, ExpiredStatus, false, id)
-	if err != nil {
+	closeQuery := `
+		UPDATE conversations
+		SET status = 'closed'
+		WHERE campaign_id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, ExpiredStatus, false, id); err != nil {
 		log.Printf("Error occured while closing campaign(%s): %v\n", id, err.Error())
 		return err
 	}
-
-	// successfully ended the campaign
-	return tx.Commit()
+	// unspent budget goes back to the brand
+	if err := releaseHold(ctx, tx, id, brandID); err != nil {
+		log.Printf("Error releasing campaign(%s) escrow: %v\n", id, err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, closeQuery, id); err != nil {
+		log.Printf("Error closing campaign(%s) conversations: %v\n", id, err)
+		return err
+	}
+	return nil
 }
 
 // Marks the campaign not accepting applications anymore
@@ -142,28 +217,14 @@ func (c *CampaignStore) StopApplications(ctx context.Context, id string) error {
 	return tx.Commit()
 }
 
-// This function Ends a campaign
+// This function activates a campaign and escrows its budget on behalf of the system
 func (c *CampaignStore) ActivateCampaign(ctx context.Context, id string) error {
-	tx, err := c.db.BeginTx(ctx, nil)
-	if err != nil {
-		log.Printf("Error initialising transaction: %v\n", err.Error())
-		return err
-	}
-	defer tx.Rollback()
-
-	query := `
-		UPDATE campaigns
-		SET status = $1
-		WHERE id = $2
-	`
-	_, err = tx.ExecContext(ctx, query, ActiveStatus, id)
-	if err != nil {
-		log.Printf("Error occured while activating campaign(%s): %v\n", id, err.Error())
-		return err
-	}
-
-	// successfully activated the campaign
-	return tx.Commit()
+	return c.TransitionCampaign(ctx, CampaignTransition{
+		CampaignID: id,
+		To:         ActiveStatus,
+		ActorID:    CampaignSystemActor,
+		Admin:      true,
+	})
 }
 
 // This functions updates a specific campaign details
@@ -194,101 +255,75 @@ func (c *CampaignStore) UpdateCampaign(ctx context.Context, campaign_id string,
 		args = append(args, *payload.DocLink)
 		i++
 	}
+	if payload.StartsAt != nil {
+		expressions = append(expressions, fmt.Sprintf("starts_at = $%d", i))
+		args = append(args, *payload.StartsAt)
+		i++
+	}
+	if payload.EndsAt != nil {
+		expressions = append(expressions, fmt.Sprintf("ends_at = $%d", i))
+		args = append(args, *payload.EndsAt)
+		i++
+	}
+	if payload.MaxCreatorEarnings != nil {
+		expressions = append(expressions, fmt.Sprintf("max_creator_earnings = NULLIF($%d, 0)", i))
+		args = append(args, *payload.MaxCreatorEarnings)
+		i++
+	}
+	if payload.MaxPaidViews != nil {
+		expressions = append(expressions, fmt.Sprintf("max_paid_views = NULLIF($%d, 0)", i))
+		args = append(args, *payload.MaxPaidViews)
+		i++
+	}
+	if payload.MaxCreators != nil {
+		expressions = append(expressions, fmt.Sprintf("max_creators = NULLIF($%d, 0)", i))
+		args = append(args, *payload.MaxCreators)
+		i++
+	}
 	queryBuilder.WriteString(strings.Join(expressions, ", "))
 	queryBuilder.WriteString(fmt.Sprintf(" WHERE id = $%d", i))
 	args = append(args, campaign_id)
 	query := queryBuilder.String()
-	_, err := c.db.ExecContext(ctx, query, args...)
 
+	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Printf("Error updating campaign details: %v", err.Error())
+		log.Printf("Error initialising transaction: %v\n", err.Error())
 		return err
 	}
+	defer tx.Rollback()
 
-	// successfully updated details
-	return nil
-}
-
-func (c *CampaignStore) GetRecentCampaigns(ctx context.Context, limit int, cursorSeq, id string,
-) ([]CampaignResp, int64, bool, error) {
-	var output []CampaignResp
-	var nextCursor, prevCursor int64
-	// 1. Base Query
-	// Using a tuple comparison (row constructor) for speed and correctness: (created_at, seq) < ($2, $3)
-	// I added "status = 1" because "Active" campaigns should be on Feed
-	query := `
-        SELECT c.id, c.brand_id, b.name AS brand_name, c.title, c.budget, c.cpm, 
-        c.requirements, c.platform, c.doc_link, c.status, c.accepting_applications, c.created_at, c.seq
-        FROM campaigns c
-        LEFT JOIN brands b ON c.brand_id = b.id
-        WHERE c.status = 1 AND NOT EXISTS (
-            SELECT 1 FROM applications a WHERE a.creator_id = $1 AND a.campaign_id = c.id
-        )
-    `
-
-	var rows *sql.Rows
-	var err error
-
-	// 2. Dynamic WHERE Clause
-	// If this is the FIRST page (cursor is zero/empty), we don't filter by time.
-	if cursorSeq == "" {
-		query += ` ORDER BY c.seq DESC LIMIT $2`
-		rows, err = c.db.QueryContext(ctx, query, id, limit+1)
-		if err != nil {
-			log.Printf("Error fetching campaigns: %v\n", err.Error())
-			return nil, 0, false, err
-		}
-	} else {
-		// If we have a cursor, fetch items OLDER than that cursor
-		cursor, err := strconv.ParseInt(cursorSeq, 10, 64)
-		if err != nil {
-			return nil, 0, false, err
-		}
-		query += `
-			AND c.seq < $2 
-			ORDER BY  c.seq DESC 
-			LIMIT $3
+	brandID, _, status, err := lockCampaign(ctx, tx, campaign_id)
+	if err != nil {
+		log.Printf("Error locking campaign(%s): %v\n", campaign_id, err)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error updating campaign details: %v", err.Error())
+		return err
+	}
+	// the brief of a campaign that is not live yet has to be approved again
+	if status == DraftStatus && (payload.Title != nil || payload.Req != nil || payload.DocLink != nil) {
+		voidQuery := `
+			UPDATE campaigns
+			SET approved_at = NULL, approved_by = NULL
+			WHERE id = $1
 		`
-		rows, err = c.db.QueryContext(ctx, query, id, cursor, limit+1)
-		if err != nil {
-			log.Printf("Error fetching campaigns: %v\n", err.Error())
-			return nil, 0, false, err
+		if _, err := tx.ExecContext(ctx, voidQuery, campaign_id); err != nil {
+			log.Printf("Error voiding campaign(%s) approval: %v\n", campaign_id, err)
+			return err
 		}
 	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var row CampaignResp
-		prevCursor = nextCursor
-		err = rows.Scan(
-			&row.Id,
-			&row.BrandId,
-			&row.Brand,
-			&row.Title,
-			&row.Budget,
-			&row.CPM,
-			&row.Req,
-			&row.Platform,
-			&row.DocLink,
-			&row.Status,
-			&row.AcceptingAppls,
-			&row.CreatedAt,
-			&nextCursor,
-		)
-		if err != nil {
-			log.Printf("Error scanning campaign: %v\n", err.Error())
-			return nil, 0, false, err
+	// top-ups and reductions of an active campaign move money in/out of the hold
+	if payload.Budget != nil && status == ActiveStatus {
+		if err := syncHold(ctx, tx, campaign_id, brandID, *payload.Budget); err != nil {
+			log.Printf("Error adjusting campaign(%s) escrow: %v\n", campaign_id, err)
+			return err
 		}
-		output = append(output, row)
-	}
-	HasMore := len(output) > limit
-	n := min(limit, len(output))
-	if HasMore {
-		nextCursor = prevCursor
 	}
-	// return the fetched the feed
-	return output[:n], nextCursor, HasMore, nil
+
+	// successfully updated details
+	return tx.Commit()
 }
 
 func (c *CampaignStore) GetBrandCampaigns(ctx context.Context, brandid string, limit int, cursorSeq string,
@@ -302,7 +337,7 @@ func (c *CampaignStore) GetBrandCampaigns(ctx context.Context, brandid string, l
 	)
 	query := `
 		SELECT id, title, budget, cpm, requirements, platform, doc_link, 
-		status, accepting_applications, created_at, seq
+		status, accepting_applications, starts_at, ends_at, created_at, seq
 		FROM campaigns
 		WHERE brand_id = $1
 	`
@@ -347,6 +382,8 @@ func (c *CampaignStore) GetBrandCampaigns(ctx context.Context, brandid string, l
 			&row.DocLink,
 			&row.Status,
 			&row.AcceptingAppls,
+			&row.StartsAt,
+			&row.EndsAt,
 			&row.CreatedAt,
 			&nextCursor,
 		)
@@ -490,7 +527,9 @@ func (c *CampaignStore) GetMultipleCampaigns(ctx context.Context, campaignIDs []
 func (c *CampaignStore) GetCampaign(ctx context.Context, id string) (*CampaignResp, error) {
 	query := `
 		SELECT c.id, c.brand_id, b.name AS brand, c.title, c.budget, c.cpm, 
-		c.requirements, c.platform, c.doc_link, c.status, c.accepting_applications, c.created_at
+		c.requirements, c.platform, c.doc_link, c.status, c.accepting_applications,
+		c.starts_at, c.ends_at, c.created_at,
+		c.max_creator_earnings, c.max_paid_views, c.max_creators
 		FROM campaigns c
 		LEFT JOIN brands b ON c.brand_id = b.id
 		WHERE c.id = $1
@@ -508,7 +547,12 @@ func (c *CampaignStore) GetCampaign(ctx context.Context, id string) (*CampaignRe
 		&row.DocLink,
 		&row.Status,
 		&row.AcceptingAppls,
+		&row.StartsAt,
+		&row.EndsAt,
 		&row.CreatedAt,
+		&row.MaxCreatorEarnings,
+		&row.MaxPaidViews,
+		&row.MaxCreators,
 	)
 	if err != nil {
 		// Error while fetching
@@ -521,16 +565,97 @@ func (c *CampaignStore) GetCampaign(ctx context.Context, id string) (*CampaignRe
 }
 
 func (c *CampaignStore) DeleteCampaign(ctx context.Context, id string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error initialising transaction: %v\n", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	brandID, _, _, err := lockCampaign(ctx, tx, id)
+	if err != nil {
+		log.Printf("Error locking campaign(%s): %v\n", id, err)
+		return err
+	}
+	// views still waiting for a payout would lose their escrow, the views of
+	// a submission confirmed as fraud are never paid
+	unsettledQuery := `
+		SELECT EXISTS (
+			SELECT 1 FROM submissions s
+			WHERE s.campaign_id = $1 AND s.status = $2
+			AND (s.views > s.accounted_views OR s.payouts_held)
+			AND NOT EXISTS (
+				SELECT 1 FROM fraud_flags f
+				WHERE f.submission_id = s.id AND f.status = $3
+			)
+		)
+	`
+	var unsettled bool
+	if err := tx.QueryRowContext(ctx, unsettledQuery, id, SubmissionApproved, FraudConfirmed).Scan(&unsettled); err != nil {
+		log.Printf("Error checking campaign(%s) submissions: %v\n", id, err)
+		return err
+	}
+	if unsettled {
+		return ErrUnsettledCampaign
+	}
+	// hand the escrow back before the campaign goes away
+	if err := releaseHold(ctx, tx, id, brandID); err != nil {
+		log.Printf("Error releasing campaign(%s) escrow: %v\n", id, err)
+		return err
+	}
 	query := `
 		DELETE FROM campaigns
 		WHERE id = $1
 	`
-	_, err := c.db.ExecContext(ctx, query, id)
+	_, err = tx.ExecContext(ctx, query, id)
 	if err != nil {
 		log.Printf("Error deleting campaign: %s\n", err.Error())
 		return err
 	}
 
 	// successfully deleted campaign
-	return nil
+	return tx.Commit()
+}
+
+// GetCampaignsToStart lists the approved draft campaigns whose start time
+// has come and whose end time, if any, is still ahead
+func (c *CampaignStore) GetCampaignsToStart(ctx context.Context, now time.Time) ([]string, error) {
+	query := `
+		SELECT id FROM campaigns
+		WHERE status = $1 AND starts_at <= $2
+			AND (ends_at IS NULL OR ends_at > $2)
+			AND approved_at IS NOT NULL
+		ORDER BY starts_at ASC
+	`
+	return c.scheduledCampaigns(ctx, query, DraftStatus, now)
+}
+
+// GetCampaignsToEnd lists the active campaigns whose end time has come
+func (c *CampaignStore) GetCampaignsToEnd(ctx context.Context, now time.Time) ([]string, error) {
+	query := `
+		SELECT id FROM campaigns
+		WHERE status = $1 AND ends_at <= $2
+		ORDER BY ends_at ASC
+	`
+	return c.scheduledCampaigns(ctx, query, ActiveStatus, now)
+}
+
+func (c *CampaignStore) scheduledCampaigns(ctx context.Context, query string, status int, now time.Time) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, query, status, now)
+	if err != nil {
+		log.Printf("Error fetching scheduled campaigns: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Error scanning campaign: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, id)
+	}
+	return output, rows.Err()
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 func generateBrand(bid string) {
@@ -342,3 +344,70 @@ func TestGetUserCampaigns(t *testing.T) {
 	})
 
 }
+
+func TestCampaignSchedule(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	bid := uuid.New().String()
+	generateBrand(bid)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	schedule := func(status int, startsAt, endsAt *time.Time) string {
+		campaign := &Campaign{
+			Id:       uuid.New().String(),
+			BrandId:  bid,
+			Title:    "scheduled_campaign",
+			Budget:   1000,
+			CPM:      10,
+			Platform: "youtube",
+			Status:   status,
+			StartsAt: startsAt,
+			EndsAt:   endsAt,
+		}
+		MockCampaignStore.LaunchCampaign(ctx, campaign)
+		return campaign.Id
+	}
+	due := schedule(DraftStatus, &past, &future)
+	unapproved := schedule(DraftStatus, &past, &future)
+	later := schedule(DraftStatus, &future, nil)
+	missed := schedule(DraftStatus, &past, &past)
+	// running without an escrow, only the status matters here
+	over := schedule(DraftStatus, nil, &past)
+	MockCampaignStore.db.ExecContext(ctx, `UPDATE campaigns SET status = $1 WHERE id = $2`, ActiveStatus, over)
+	// only reviewed campaigns are started
+	MockCampaignStore.db.ExecContext(ctx, `UPDATE campaigns SET approved_at = now() WHERE id = ANY($1)`,
+		pq.Array([]string{due, later, missed}))
+	defer func() {
+		destroyCampaign(ctx, []string{due, unapproved, later, missed, over})
+		destroyBrand(bid)
+		cancel()
+	}()
+
+	t.Run("campaigns to start", func(t *testing.T) {
+		ids, err := MockCampaignStore.GetCampaignsToStart(ctx, time.Now())
+		if err != nil {
+			t.Fail()
+			return
+		}
+		if !slices.Contains(ids, due) || slices.Contains(ids, unapproved) ||
+			slices.Contains(ids, later) || slices.Contains(ids, missed) {
+			log.Printf("got: %v\n", ids)
+			t.Fail()
+		}
+	})
+	t.Run("campaigns to end", func(t *testing.T) {
+		ids, err := MockCampaignStore.GetCampaignsToEnd(ctx, time.Now())
+		if err != nil {
+			t.Fail()
+			return
+		}
+		if !slices.Contains(ids, over) || slices.Contains(ids, due) {
+			log.Printf("got: %v\n", ids)
+			t.Fail()
+		}
+		campaign, _ := MockCampaignStore.GetCampaign(ctx, over)
+		if campaign == nil || campaign.EndsAt == nil || !campaign.EndsAt.Equal(past.Truncate(time.Microsecond)) {
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var ErrIneligible = errors.New("creator not eligible for the campaign")
+
+// Eligibility rules of a campaign, a nil rule lets every creator through
+type Eligibility struct {
+	RequiredPlatform *string  `json:"required_platform,omitempty"` // creator must link this platform
+	MinSubscribers   *int64   `json:"min_subscribers,omitempty"`   // on the required platform, or any linked one
+	Countries        []string `json:"countries,omitempty"`         // ISO 3166 alpha-2 codes of the audience
+	MinAge           *int     `json:"min_age,omitempty"`
+	MaxAge           *int     `json:"max_age,omitempty"`
+	Genders          []string `json:"genders,omitempty"`
+	MinSubmissions   *int     `json:"min_submissions,omitempty"` // submissions made to other campaigns
+}
+
+// what the rules are checked against
+type creatorProfile struct {
+	Age         int
+	Gender      string
+	Country     string
+	Subscribers int64 // biggest matching channel, -1 when none is linked
+	Submissions int
+}
+
+// feed filter, expects the campaign as c, its rules as e and the creator as u
+const eligibleCreator = `
+	AND (e.campaign_id IS NULL OR (
+		(e.min_age IS NULL OR u.age >= e.min_age)
+		AND (e.max_age IS NULL OR u.age <= e.max_age)
+		AND (e.genders IS NULL OR u.gender = ANY(e.genders))
+		AND (e.countries IS NULL OR u.country = ANY(e.countries))
+		AND ((e.required_platform IS NULL AND e.min_subscribers IS NULL) OR EXISTS (
+			SELECT 1 FROM platform_links pl
+			WHERE pl.userid = u.id
+			AND (e.required_platform IS NULL OR pl.platform = e.required_platform)
+			AND pl.subscribers >= COALESCE(e.min_subscribers, 0)
+		))
+		AND (e.min_submissions IS NULL OR (
+			SELECT COUNT(*) FROM submissions s
+			WHERE s.creator_id = u.id AND s.campaign_id <> c.id
+		) >= e.min_submissions)
+	))
+`
+
+// normalise drops empty lists and matches the casing stored on users and links
+func (e *Eligibility) normalise() {
+	for i := range e.Countries {
+		e.Countries[i] = strings.ToUpper(strings.TrimSpace(e.Countries[i]))
+	}
+	for i := range e.Genders {
+		e.Genders[i] = strings.ToUpper(strings.TrimSpace(e.Genders[i]))
+	}
+	if len(e.Countries) == 0 {
+		e.Countries = nil
+	}
+	if len(e.Genders) == 0 {
+		e.Genders = nil
+	}
+	if e.RequiredPlatform != nil {
+		platform := strings.ToLower(strings.TrimSpace(*e.RequiredPlatform))
+		e.RequiredPlatform = &platform
+		if platform == "" {
+			e.RequiredPlatform = nil
+		}
+	}
+}
+
+// Empty reports whether the campaign is open to every creator
+func (e *Eligibility) Empty() bool {
+	return e == nil || (e.RequiredPlatform == nil && e.MinSubscribers == nil &&
+		e.Countries == nil && e.MinAge == nil && e.MaxAge == nil &&
+		e.Genders == nil && e.MinSubmissions == nil)
+}
+
+// Validate the rules before storing them
+func (e *Eligibility) Validate() error {
+	if e.MinAge != nil && e.MaxAge != nil && *e.MinAge > *e.MaxAge {
+		return fmt.Errorf("%w: min_age above max_age", ErrInvalidArgs)
+	}
+	if (e.MinAge != nil && *e.MinAge < 0) || (e.MaxAge != nil && *e.MaxAge < 0) ||
+		(e.MinSubscribers != nil && *e.MinSubscribers < 0) ||
+		(e.MinSubmissions != nil && *e.MinSubmissions < 0) {
+		return fmt.Errorf("%w: negative limit", ErrInvalidArgs)
+	}
+	for _, country := range e.Countries {
+		if len(country) != 2 {
+			return fmt.Errorf("%w: country %q", ErrInvalidArgs, country)
+		}
+	}
+	for _, gender := range e.Genders {
+		if gender != "M" && gender != "F" && gender != "O" {
+			return fmt.Errorf("%w: gender %q", ErrInvalidArgs, gender)
+		}
+	}
+	return nil
+}
+
+// check returns the first rule the creator fails
+func (e *Eligibility) check(p creatorProfile) error {
+	if e.RequiredPlatform != nil && p.Subscribers < 0 {
+		return fmt.Errorf("%w: requires a linked %s channel", ErrIneligible, *e.RequiredPlatform)
+	}
+	if e.MinSubscribers != nil && p.Subscribers < *e.MinSubscribers {
+		return fmt.Errorf("%w: requires at least %d subscribers", ErrIneligible, *e.MinSubscribers)
+	}
+	if e.Countries != nil && !slices.Contains(e.Countries, p.Country) {
+		return fmt.Errorf("%w: open to audiences in %s only", ErrIneligible, strings.Join(e.Countries, ", "))
+	}
+	if e.MinAge != nil && p.Age < *e.MinAge {
+		return fmt.Errorf("%w: creators must be at least %d", ErrIneligible, *e.MinAge)
+	}
+	if e.MaxAge != nil && p.Age > *e.MaxAge {
+		return fmt.Errorf("%w: creators must be at most %d", ErrIneligible, *e.MaxAge)
+	}
+	if e.Genders != nil && !slices.Contains(e.Genders, p.Gender) {
+		return fmt.Errorf("%w: not open to this gender", ErrIneligible)
+	}
+	if e.MinSubmissions != nil && p.Submissions < *e.MinSubmissions {
+		return fmt.Errorf("%w: requires %d submissions to earlier campaigns", ErrIneligible, *e.MinSubmissions)
+	}
+	return nil
+}
+
+// Sets the eligibility rules of a campaign, empty rules open it to everyone
+func (c *CampaignStore) SetCampaignEligibility(ctx context.Context, id string, rules *Eligibility) error {
+	if rules != nil {
+		rules.normalise()
+		if err := rules.Validate(); err != nil {
+			return err
+		}
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error beginning transaction: %v\n", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+	if rules.Empty() {
+		_, err = tx.ExecContext(ctx, `DELETE FROM campaign_eligibility WHERE campaign_id = $1`, id)
+	} else {
+		err = setEligibility(ctx, tx, id, rules)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setEligibility(ctx context.Context, tx *sql.Tx, id string, rules *Eligibility) error {
+	query := `
+		INSERT INTO campaign_eligibility (campaign_id, required_platform, min_subscribers,
+		countries, min_age, max_age, genders, min_submissions)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (campaign_id) DO UPDATE SET
+		required_platform = EXCLUDED.required_platform,
+		min_subscribers = EXCLUDED.min_subscribers,
+		countries = EXCLUDED.countries,
+		min_age = EXCLUDED.min_age,
+		max_age = EXCLUDED.max_age,
+		genders = EXCLUDED.genders,
+		min_submissions = EXCLUDED.min_submissions,
+		updated_at = now()
+	`
+	_, err := tx.ExecContext(ctx, query, id, rules.RequiredPlatform, rules.MinSubscribers,
+		pq.Array(rules.Countries), rules.MinAge, rules.MaxAge, pq.Array(rules.Genders),
+		rules.MinSubmissions,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		log.Printf("error setting eligibility of campaign(%s): %v\n", id, err.Error())
+		return err
+	}
+	return nil
+}
+
+// Returns the eligibility rules of a campaign, empty when it has none
+func (c *CampaignStore) GetCampaignEligibility(ctx context.Context, id string) (*Eligibility, error) {
+	query := `
+		SELECT required_platform, min_subscribers, countries, min_age, max_age, genders, min_submissions
+		FROM campaign_eligibility
+		WHERE campaign_id = $1
+	`
+	var rules Eligibility
+	err := c.db.QueryRowContext(ctx, query, id).Scan(
+		&rules.RequiredPlatform,
+		&rules.MinSubscribers,
+		pq.Array(&rules.Countries),
+		&rules.MinAge,
+		&rules.MaxAge,
+		pq.Array(&rules.Genders),
+		&rules.MinSubmissions,
+	)
+	if err == sql.ErrNoRows {
+		return &Eligibility{}, nil
+	}
+	if err != nil {
+		log.Printf("error fetching eligibility of campaign(%s): %v\n", id, err.Error())
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// checkEligibility returns ErrIneligible with the reason when the creator
+// fails a rule of the campaign
+func checkEligibility(ctx context.Context, db *sql.DB, campaignID, creatorID string) error {
+	query := `
+		SELECT e.required_platform, e.min_subscribers, e.countries, e.min_age, e.max_age,
+		e.genders, e.min_submissions, u.age, u.gender, COALESCE(u.country, ''),
+		COALESCE((
+			SELECT MAX(pl.subscribers) FROM platform_links pl
+			WHERE pl.userid = u.id
+			AND (e.required_platform IS NULL OR pl.platform = e.required_platform)
+		), -1),
+		(
+			SELECT COUNT(*) FROM submissions s
+			WHERE s.creator_id = u.id AND s.campaign_id <> e.campaign_id
+		)
+		FROM campaign_eligibility e
+		JOIN users u ON u.id = $2
+		WHERE e.campaign_id = $1
+	`
+	var rules Eligibility
+	var profile creatorProfile
+	err := db.QueryRowContext(ctx, query, campaignID, creatorID).Scan(
+		&rules.RequiredPlatform,
+		&rules.MinSubscribers,
+		pq.Array(&rules.Countries),
+		&rules.MinAge,
+		&rules.MaxAge,
+		pq.Array(&rules.Genders),
+		&rules.MinSubmissions,
+		&profile.Age,
+		&profile.Gender,
+		&profile.Country,
+		&profile.Subscribers,
+		&profile.Submissions,
+	)
+	if err == sql.ErrNoRows {
+		// the campaign has no rules
+		return nil
+	}
+	if err != nil {
+		log.Printf("error checking eligibility of creator(%s): %v\n", creatorID, err.Error())
+		return err
+	}
+	return rules.check(profile)
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCampaignEligibility(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	bid := uuid.New().String()
+	generateBrand(bid)
+	uid := generateCreator(ctx, uuid.New().String())
+	ids := SeedCampaign(ctx, bid, ActiveStatus, 2)
+	targeted, open := ids[0], ids[1]
+	var appls []string
+	defer func() {
+		destroyApplications(ctx, appls)
+		MockLinkStore.DeleteLinks(ctx, uid, "youtube")
+		destroyCampaign(ctx, ids)
+		destroyCreator(ctx, uid)
+		destroyBrand(bid)
+		cancel()
+	}()
+
+	platform, subscribers, minAge := "YouTube", int64(1000), 18
+	err := MockCampaignStore.SetCampaignEligibility(ctx, targeted, &Eligibility{
+		RequiredPlatform: &platform,
+		MinSubscribers:   &subscribers,
+		Countries:        []string{"in"},
+		MinAge:           &minAge,
+	})
+	if err != nil {
+		log.Printf("error setting eligibility: %v", err)
+		t.Fail()
+		return
+	}
+	inFeed := func(id string) bool {
+		got, _, _, err := MockCampaignStore.GetRecentCampaigns(ctx, 100, "", uid)
+		if err != nil {
+			t.Fail()
+		}
+		for _, v := range got {
+			if v.Id == id {
+				return true
+			}
+		}
+		return false
+	}
+	apply := func(id string) error {
+		appl := CampaignApplication{Id: uuid.New().String(), CampaignId: id, CreatorId: uid}
+		err := MockApplicationStore.CreateApplication(ctx, appl)
+		if err == nil {
+			appls = append(appls, appl.Id)
+		}
+		return err
+	}
+
+	t.Run("rules are normalised", func(t *testing.T) {
+		rules, err := MockCampaignStore.GetCampaignEligibility(ctx, targeted)
+		if err != nil || *rules.RequiredPlatform != "youtube" || rules.Countries[0] != "IN" ||
+			rules.MaxAge != nil || rules.Genders != nil {
+			t.Fail()
+		}
+		maxAge := 10
+		bad := &Eligibility{MinAge: &minAge, MaxAge: &maxAge}
+		if err := MockCampaignStore.SetCampaignEligibility(ctx, targeted, bad); !errors.Is(err, ErrInvalidArgs) {
+			t.Fail()
+		}
+	})
+	t.Run("ineligible creators are kept out", func(t *testing.T) {
+		if inFeed(targeted) || !inFeed(open) {
+			t.Fail()
+		}
+		if err := apply(targeted); !errors.Is(err, ErrIneligible) {
+			log.Printf("got: %v, want: %v", err, ErrIneligible)
+			t.Fail()
+		}
+		// a small channel is still not enough
+		MockLinkStore.AddLinks(ctx, uid, []Links{{Platform: "youtube", Url: "mock_url", Subscribers: 10}})
+		if err := apply(targeted); !errors.Is(err, ErrIneligible) || inFeed(targeted) {
+			t.Fail()
+		}
+	})
+	t.Run("eligible creators see and apply", func(t *testing.T) {
+		MockLinkStore.DeleteLinks(ctx, uid, "youtube")
+		MockLinkStore.AddLinks(ctx, uid, []Links{{Platform: "youtube", Url: "mock_url", Subscribers: 5000}})
+		country := "in"
+		if err := MockUserStore.UpdateUser(ctx, uid, UpdatePayload{Country: &country}); err != nil {
+			t.Fail()
+		}
+		if !inFeed(targeted) {
+			t.Fail()
+		}
+		if err := apply(targeted); err != nil {
+			log.Printf("error applying: %v", err)
+			t.Fail()
+		}
+	})
+	t.Run("track record", func(t *testing.T) {
+		submissions := 1
+		MockCampaignStore.SetCampaignEligibility(ctx, open, &Eligibility{MinSubmissions: &submissions})
+		if inFeed(open) || !errors.Is(apply(open), ErrIneligible) {
+			t.Fail()
+		}
+		// clearing the rules opens the campaign again
+		if err := MockCampaignStore.SetCampaignEligibility(ctx, open, &Eligibility{}); err != nil || !inFeed(open) {
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// macros for escrow journal entries
+const (
+	EntryHold    = "campaign_hold"    // brand account -> campaign hold
+	EntryRelease = "campaign_release" // campaign hold -> brand account
+)
+
+var ErrUnsettledCampaign = errors.New("campaign has unsettled submissions")
+
+// holdAccount returns the escrow account of a campaign, opening it on first use
+func holdAccount(ctx context.Context, tx *sql.Tx, campaignID string) (string, error) {
+	openQuery := `
+		INSERT INTO accounts (id, holder_id, holder_type, amount)
+		VALUES ($1, $2, $3, 0)
+		ON CONFLICT (holder_id, holder_type) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, openQuery, uuid.New().String(), campaignID, "campaign"); err != nil {
+		return "", fmt.Errorf("open hold account: %w", err)
+	}
+
+	var accID string
+	query := `
+		SELECT id FROM accounts
+		WHERE holder_id = $1 AND holder_type = $2
+	`
+	if err := tx.QueryRowContext(ctx, query, campaignID, "campaign").Scan(&accID); err != nil {
+		return "", fmt.Errorf("hold account: %w", err)
+	}
+	return accID, nil
+}
+
+// brandAccount returns the active wallet of a brand
+func brandAccount(ctx context.Context, tx *sql.Tx, brandID string) (string, error) {
+	var accID string
+	query := `
+		SELECT id FROM accounts
+		WHERE holder_id = $1 AND holder_type = $2 AND active = $3
+	`
+	if err := tx.QueryRowContext(ctx, query, brandID, "brand", true).Scan(&accID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrAccountInactive
+		}
+		return "", fmt.Errorf("brand account: %w", err)
+	}
+	return accID, nil
+}
+
+// syncHold moves money between the brand wallet and the campaign hold until
+// the hold carries exactly the campaign budget.
+// The caller must hold the lock on the campaign row.
+func syncHold(ctx context.Context, tx *sql.Tx, campaignID, brandID string, budget float64) error {
+	holdID, err := holdAccount(ctx, tx, campaignID)
+	if err != nil {
+		return err
+	}
+	brandAcc, err := brandAccount(ctx, tx, brandID)
+	if err != nil {
+		return err
+	}
+
+	var held float64
+	if err := tx.QueryRowContext(ctx, `SELECT amount FROM accounts WHERE id = $1`, holdID).Scan(&held); err != nil {
+		return fmt.Errorf("hold balance: %w", err)
+	}
+
+	diff := roundCents(budget - held)
+	memo := fmt.Sprintf("escrow for campaign %s", campaignID)
+	switch {
+	case diff > 0:
+		return postEntry(ctx, tx, transfer(uuid.New().String(), "", EntryHold, memo, brandAcc, holdID, diff))
+	case diff < 0:
+		return postEntry(ctx, tx, transfer(uuid.New().String(), "", EntryRelease, memo, holdID, brandAcc, -diff))
+	}
+	return nil
+}
+
+// fundedHold returns the escrow account of an active campaign. Campaigns
+// launched before the escrow may have none yet, their hold is funded from
+// the brand wallet on first use.
+func fundedHold(ctx context.Context, tx *sql.Tx, campaignID, brandID string, budget float64) (string, error) {
+	var accID string
+	query := `
+		SELECT id FROM accounts
+		WHERE holder_id = $1 AND holder_type = $2 AND active = $3
+	`
+	err := tx.QueryRowContext(ctx, query, campaignID, "campaign", true).Scan(&accID)
+	if err == nil {
+		return accID, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("campaign hold: %w", err)
+	}
+	if err := syncHold(ctx, tx, campaignID, brandID, budget); err != nil {
+		return "", fmt.Errorf("fund campaign hold: %w", err)
+	}
+	return holdAccount(ctx, tx, campaignID)
+}
+
+// releaseHold returns whatever is left in the campaign hold to the brand wallet
+func releaseHold(ctx context.Context, tx *sql.Tx, campaignID, brandID string) error {
+	var held float64
+	query := `
+		SELECT amount FROM accounts
+		WHERE holder_id = $1 AND holder_type = $2
+	`
+	err := tx.QueryRowContext(ctx, query, campaignID, "campaign").Scan(&held)
+	if err == sql.ErrNoRows || (err == nil && held == 0) {
+		// nothing was ever escrowed
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("hold balance: %w", err)
+	}
+	return syncHold(ctx, tx, campaignID, brandID, 0)
+}
+
+// lockCampaign locks the campaign row for the escrow changes
+func lockCampaign(ctx context.Context, tx *sql.Tx, campaignID string) (brandID string, budget float64, status int, err error) {
+	query := `
+		SELECT brand_id, budget, status FROM campaigns
+		WHERE id = $1
+		FOR UPDATE
+	`
+	err = tx.QueryRowContext(ctx, query, campaignID).Scan(&brandID, &budget, &status)
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func getHold(ctx context.Context, campID string) *Account {
+	query := `
+		SELECT id, holder_id, holder_type, amount FROM accounts
+		WHERE holder_id = $1 AND holder_type = $2
+	`
+	var acc Account
+	err := MockTsStore.db.QueryRowContext(ctx, query, campID, "campaign").Scan(
+		&acc.Id, &acc.HolderId, &acc.Type, &acc.Amount,
+	)
+	if err != nil {
+		return nil
+	}
+	return &acc
+}
+
+func destroyHold(ctx context.Context, campID string) {
+	if hold := getHold(ctx, campID); hold != nil {
+		destroyAccounts(ctx, hold.Id)
+	}
+}
+
+func TestCampaignEscrow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	bid := uuid.New().String()
+	generateBrand(bid)
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	uid := generateCreator(ctx, uuid.New().String())
+	subID := uuid.New().String()
+
+	temp_camp := Campaign{
+		Id:       uuid.New().String(),
+		BrandId:  bid,
+		Title:    "mock_title",
+		Budget:   4000.0,
+		CPM:      101.0,
+		Req:      "mock_requirements",
+		Platform: "youtube",
+		DocLink:  "mock_link",
+		Status:   DraftStatus,
+	}
+	MockCampaignStore.LaunchCampaign(ctx, &temp_camp)
+	defer func() {
+		destroySubmissions(ctx, []string{subID})
+		MockCampaignStore.DeleteCampaign(ctx, temp_camp.Id)
+		destroyHold(ctx, temp_camp.Id)
+		destroyAccounts(ctx, brand_acc.Id)
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+
+	// checks the brand wallet and the hold against the expected escrow
+	expectEscrow := func(t *testing.T, held float64) {
+		bacc, _ := MockTsStore.GetAccount(ctx, brand_acc.Id)
+		hold := getHold(ctx, temp_camp.Id)
+		if hold == nil || hold.Amount != held {
+			log.Printf("hold mismatch: want %f\n", held)
+			t.Fail()
+			return
+		}
+		if bacc.Amount != brand_acc.Amount-held {
+			log.Printf("brand balance mismatch: got %f\n", bacc.Amount)
+			t.Fail()
+		}
+	}
+
+	t.Run("activation escrows the budget", func(t *testing.T) {
+		if err := MockCampaignStore.ActivateCampaign(ctx, temp_camp.Id); err != nil {
+			t.Fail()
+			return
+		}
+		expectEscrow(t, 4000.0)
+	})
+	t.Run("top up and reduction adjust the hold", func(t *testing.T) {
+		budget := 6000.0
+		if err := MockCampaignStore.UpdateCampaign(ctx, temp_camp.Id, UpdateCampaign{Budget: &budget}); err != nil {
+			t.Fail()
+			return
+		}
+		expectEscrow(t, 6000.0)
+		budget = 2000.0
+		if err := MockCampaignStore.UpdateCampaign(ctx, temp_camp.Id, UpdateCampaign{Budget: &budget}); err != nil {
+			t.Fail()
+			return
+		}
+		expectEscrow(t, 2000.0)
+	})
+	t.Run("top up beyond the brand balance is rejected", func(t *testing.T) {
+		budget := brand_acc.Amount + 1000.0
+		if err := MockCampaignStore.UpdateCampaign(ctx, temp_camp.Id, UpdateCampaign{Budget: &budget}); err == nil {
+			t.Fail()
+		}
+		expectEscrow(t, 2000.0)
+	})
+	t.Run("unsettled views block the deletion", func(t *testing.T) {
+		query := `
+			INSERT INTO submissions (id, creator_id, campaign_id, url, status, video_platform,
+			video_status, views, accounted_views)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`
+		_, err := MockSubStore.db.ExecContext(ctx, query, subID, uid, temp_camp.Id, "mock_url",
+			SubmissionApproved, "youtube", "available", 500, 100)
+		if err != nil {
+			log.Printf("error seeding submission: %v", err)
+			t.Fail()
+			return
+		}
+		if err := MockCampaignStore.DeleteCampaign(ctx, temp_camp.Id); !errors.Is(err, ErrUnsettledCampaign) {
+			t.Fail()
+		}
+		expectEscrow(t, 2000.0)
+		destroySubmissions(ctx, []string{subID})
+	})
+	t.Run("ending releases the remainder", func(t *testing.T) {
+		if err := MockCampaignStore.EndCampaign(ctx, temp_camp.Id); err != nil {
+			t.Fail()
+			return
+		}
+		expectEscrow(t, 0)
+	})
+}
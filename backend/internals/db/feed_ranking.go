@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// points a campaign earns in the feed ranking, the feed is ordered by the
+// total and then by the newest campaign
+const (
+	feedFollowBoost   = 40 // the creator follows the brand
+	feedPlatformBoost = 30 // the creator linked the campaign's platform
+	feedAcceptance    = 20 // scaled by the acceptance rate the creator can expect
+	feedBudgetHealth  = 10 // scaled by the budget left, full at feedHealthyViews
+	feedCPM           = 10 // scaled by the CPM, full at feedTopCPM
+)
+
+const (
+	feedHealthyViews = 100000
+	feedTopCPM       = 250.0
+)
+
+// feedScore adds up the points of a campaign. Every part is floored so a
+// small change of the budget or a rate rarely moves a campaign between pages
+var feedScore = fmt.Sprintf(`(
+	CASE WHEN f.brand_id IS NOT NULL THEN %d ELSE 0 END
+	+ CASE WHEN EXISTS (
+		SELECT 1 FROM platform_links pl WHERE pl.userid = $1 AND pl.platform = c.platform
+	) THEN %d ELSE 0 END
+	+ FLOOR(%d * COALESCE(cr.rate, br.rate, 0))
+	+ FLOOR(%d * LEAST(1, c.budget::float8 * 1000 / (c.cpm * %d)))
+	+ FLOOR(%d * LEAST(1, c.cpm / %f))
+)::int`,
+	feedFollowBoost, feedPlatformBoost, feedAcceptance,
+	feedBudgetHealth, feedHealthyViews, feedCPM, feedTopCPM,
+)
+
+// feedCursor is the position after the last campaign of a page. Snapshot is
+// the newest campaign of the first page, later campaigns wait for a new feed
+type feedCursor struct {
+	Score    int
+	Seq      int64
+	Snapshot int64
+}
+
+func (f feedCursor) String() string {
+	return fmt.Sprintf("%d:%d:%d", f.Score, f.Seq, f.Snapshot)
+}
+
+func parseFeedCursor(cursor string) (*feedCursor, error) {
+	parts := strings.Split(cursor, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: cursor", ErrInvalidArgs)
+	}
+	var f feedCursor
+	var err error
+	if f.Score, err = strconv.Atoi(parts[0]); err != nil {
+		return nil, fmt.Errorf("%w: cursor", ErrInvalidArgs)
+	}
+	if f.Seq, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: cursor", ErrInvalidArgs)
+	}
+	if f.Snapshot, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: cursor", ErrInvalidArgs)
+	}
+	return &f, nil
+}
+
+// Returns the feed of a creator: the live campaigns they are eligible for and
+// have not applied to, ranked for them. The returned cursor fetches the next page
+func (c *CampaignStore) GetRecentCampaigns(ctx context.Context, limit int, cursor, id string,
+) ([]CampaignResp, string, bool, error) {
+	// acceptance rates count the decided applications only
+	query := `
+		WITH brand_rates AS (
+			SELECT pc.brand_id, AVG(CASE WHEN a.status = 1 THEN 1.0 ELSE 0.0 END) AS rate
+			FROM applications a
+			JOIN campaigns pc ON pc.id = a.campaign_id
+			WHERE a.status IN (0, 1)
+			GROUP BY pc.brand_id
+		), creator_rates AS (
+			SELECT pc.brand_id, AVG(CASE WHEN a.status = 1 THEN 1.0 ELSE 0.0 END) AS rate
+			FROM applications a
+			JOIN campaigns pc ON pc.id = a.campaign_id
+			WHERE a.creator_id = $1 AND a.status IN (0, 1)
+			GROUP BY pc.brand_id
+		), ranked AS (
+			SELECT c.id, c.brand_id, b.name AS brand_name, c.title, c.budget, c.cpm,
+			c.requirements, c.platform, c.doc_link, c.status, c.accepting_applications, c.created_at,
+			c.seq, ` + feedScore + ` AS score, MAX(c.seq) OVER () AS snapshot
+			FROM campaigns c
+			LEFT JOIN brands b ON c.brand_id = b.id
+			LEFT JOIN campaign_eligibility e ON e.campaign_id = c.id
+			LEFT JOIN users u ON u.id = $1
+			LEFT JOIN following_list f ON f.user_id = $1 AND f.brand_id = c.brand_id
+			LEFT JOIN creator_rates cr ON cr.brand_id = c.brand_id
+			LEFT JOIN brand_rates br ON br.brand_id = c.brand_id
+			WHERE c.status = 1 AND NOT EXISTS (
+				SELECT 1 FROM applications a WHERE a.creator_id = $1 AND a.campaign_id = c.id
+			)
+			` + eligibleCreator + `
+			%s
+		)
+		SELECT id, brand_id, brand_name, title, budget, cpm, requirements, platform, doc_link,
+		status, accepting_applications, created_at, seq, score, snapshot
+		FROM ranked
+		%s
+		ORDER BY score DESC, seq DESC
+		LIMIT $2
+	`
+	args := []any{id, limit + 1}
+	if cursor == "" {
+		// the first page takes the snapshot
+		query = fmt.Sprintf(query, "", "")
+	} else {
+		last, err := parseFeedCursor(cursor)
+		if err != nil {
+			return nil, "", false, err
+		}
+		query = fmt.Sprintf(query, "AND c.seq <= $3", "WHERE (score, seq) < ($4, $5)")
+		args = append(args, last.Snapshot, last.Score, last.Seq)
+	}
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error fetching campaigns: %v\n", err.Error())
+		return nil, "", false, err
+	}
+	defer rows.Close()
+
+	var output []CampaignResp
+	var cursors []feedCursor
+	for rows.Next() {
+		var row CampaignResp
+		var position feedCursor
+		err = rows.Scan(
+			&row.Id,
+			&row.BrandId,
+			&row.Brand,
+			&row.Title,
+			&row.Budget,
+			&row.CPM,
+			&row.Req,
+			&row.Platform,
+			&row.DocLink,
+			&row.Status,
+			&row.AcceptingAppls,
+			&row.CreatedAt,
+			&position.Seq,
+			&position.Score,
+			&position.Snapshot,
+		)
+		if err != nil {
+			log.Printf("Error scanning campaign: %v\n", err.Error())
+			return nil, "", false, err
+		}
+		output = append(output, row)
+		cursors = append(cursors, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", false, err
+	}
+	HasMore := len(output) > limit
+	n := min(limit, len(output))
+	var next string
+	if n > 0 {
+		next = cursors[n-1].String()
+	}
+	// return the ranked feed
+	return output[:n], next, HasMore, nil
+}
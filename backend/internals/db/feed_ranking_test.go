@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFeedRanking(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	followed, other := uuid.New().String(), uuid.New().String()
+	generateBrand(followed)
+	generateBrand(other)
+	uid := generateCreator(ctx, uuid.New().String())
+	var ids []string
+	defer func() {
+		destroyCampaign(ctx, ids)
+		MockLinkStore.DeleteLinks(ctx, uid, "tiktok")
+		destroyCreator(ctx, uid)
+		destroyBrand(followed)
+		destroyBrand(other)
+		cancel()
+	}()
+	// oldest first: followed brand, linked platform, then two plain campaigns
+	ids = append(ids, SeedCampaign(ctx, followed, ActiveStatus, 1)...)
+	ids = append(ids, SeedCampaign(ctx, other, ActiveStatus, 1)...)
+	ids = append(ids, SeedCampaign(ctx, other, ActiveStatus, 2)...)
+	MockCampaignStore.db.ExecContext(ctx, `UPDATE campaigns SET platform = 'tiktok' WHERE id = $1`, ids[1])
+	MockCampaignStore.db.ExecContext(ctx,
+		`INSERT INTO following_list (user_id, brand_id) VALUES ($1, $2)`, uid, followed,
+	)
+	MockLinkStore.AddLinks(ctx, uid, []Links{{Platform: "tiktok", Url: "mock_url"}})
+
+	// walks the whole feed one campaign per page, keeping the seeded ones
+	walk := func(onPage func(page int)) []string {
+		var order []string
+		cursor := ""
+		for page := 0; page < 1000; page++ {
+			got, next, hasMore, err := MockCampaignStore.GetRecentCampaigns(ctx, 1, cursor, uid)
+			if err != nil {
+				log.Printf("error fetching the feed: %v", err)
+				t.Fail()
+				break
+			}
+			for _, v := range got {
+				if slices.Contains(ids, v.Id) {
+					order = append(order, v.Id)
+				}
+			}
+			if onPage != nil {
+				onPage(page)
+			}
+			if !hasMore {
+				break
+			}
+			cursor = next
+		}
+		return order
+	}
+
+	t.Run("boosted campaigns come first", func(t *testing.T) {
+		got := walk(nil)
+		want := []string{ids[0], ids[1], ids[3], ids[2]}
+		if !slices.Equal(got, want) {
+			log.Printf("got: %v, want: %v", got, want)
+			t.Fail()
+		}
+	})
+	t.Run("pages are stable", func(t *testing.T) {
+		var late []string
+		got := walk(func(page int) {
+			if page == 0 {
+				// launched while the creator is paging
+				late = SeedCampaign(ctx, followed, ActiveStatus, 1)
+				ids = append(ids, late...)
+			}
+		})
+		if len(got) != 4 || slices.Contains(got, late[0]) {
+			log.Printf("got: %v", got)
+			t.Fail()
+		}
+	})
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, _, _, err := MockCampaignStore.GetRecentCampaigns(ctx, 1, "abc", uid)
+		if !errors.Is(err, ErrInvalidArgs) {
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// macros for the fraud flag states
+const (
+	FraudOpen      = "open"
+	FraudCleared   = "cleared"
+	FraudConfirmed = "confirmed"
+)
+
+// decision recorded when the detector holds a submission
+const FraudHeld = "held"
+
+// the detector acts for this reviewer
+const FraudSystemReviewer = "system"
+
+type FraudStore struct {
+	db *sql.DB
+}
+
+type FraudFlag struct {
+	Id           string          `json:"id"`
+	SubmissionId string          `json:"submission_id"`
+	CreatorId    string          `json:"creator_id"`
+	CampaignId   string          `json:"campaign_id"`
+	Rules        []string        `json:"rules"`
+	Evidence     json.RawMessage `json:"evidence"`
+	Status       string          `json:"status"`
+	ReviewedBy   string          `json:"reviewed_by,omitempty"`
+	Note         string          `json:"note,omitempty"`
+	CreatedAt    string          `json:"created_at"`
+	ReviewedAt   string          `json:"reviewed_at,omitempty"`
+}
+
+type FraudDecision struct {
+	Id        string          `json:"id"`
+	FlagId    string          `json:"flag_id"`
+	Decision  string          `json:"decision"`
+	DecidedBy string          `json:"decided_by"`
+	Note      string          `json:"note,omitempty"`
+	Evidence  json.RawMessage `json:"evidence"`
+	CreatedAt string          `json:"created_at"`
+}
+
+const fraudFlagColumns = `
+	f.id, f.submission_id, s.creator_id, s.campaign_id, f.rules, f.evidence, f.status,
+	COALESCE(f.reviewed_by, ''), COALESCE(f.note, ''), f.created_at,
+	COALESCE(f.reviewed_at::text, '')
+`
+
+func scanFraudFlag(row interface{ Scan(...any) error }, f *FraudFlag) error {
+	return row.Scan(
+		&f.Id,
+		&f.SubmissionId,
+		&f.CreatorId,
+		&f.CampaignId,
+		pq.Array(&f.Rules),
+		&f.Evidence,
+		&f.Status,
+		&f.ReviewedBy,
+		&f.Note,
+		&f.CreatedAt,
+		&f.ReviewedAt,
+	)
+}
+
+func recordFraudDecision(ctx context.Context, tx *sql.Tx, flagID, decision, decidedBy, note string, evidence json.RawMessage) error {
+	query := `
+		INSERT INTO fraud_decisions (id, flag_id, decision, decided_by, note, evidence)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+	`
+	_, err := tx.ExecContext(ctx, query, uuid.New().String(), flagID, decision, decidedBy, note, evidence)
+	return err
+}
+
+// FlagSubmission opens a review of a submission and holds its payouts. It
+// reports false when the submission is already under review or was cleared
+// in the last day, so the same evidence does not hold it again.
+func (fs *FraudStore) FlagSubmission(ctx context.Context, flag *FraudFlag) (bool, error) {
+	tx, err := fs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if flag.Id == "" {
+		flag.Id = uuid.New().String()
+	}
+	query := `
+		INSERT INTO fraud_flags (id, submission_id, rules, evidence)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1 FROM fraud_flags
+			WHERE submission_id = $2 AND status = 'cleared'
+			AND reviewed_at > now() - interval '1 day'
+		)
+		ON CONFLICT (submission_id) WHERE status = 'open' DO NOTHING
+		RETURNING status, created_at
+	`
+	err = tx.QueryRowContext(ctx, query,
+		flag.Id, flag.SubmissionId, pq.Array(flag.Rules), flag.Evidence,
+	).Scan(&flag.Status, &flag.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("error flagging submission %s: %v\n", flag.SubmissionId, err)
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE submissions SET payouts_held = true WHERE id = $1`, flag.SubmissionId)
+	if err != nil {
+		log.Printf("error holding payouts of submission %s: %v\n", flag.SubmissionId, err)
+		return false, err
+	}
+	err = recordFraudDecision(ctx, tx, flag.Id, FraudHeld, FraudSystemReviewer, "", flag.Evidence)
+	if err != nil {
+		log.Printf("error recording the hold of flag %s: %v\n", flag.Id, err)
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (fs *FraudStore) GetFraudFlag(ctx context.Context, id string) (*FraudFlag, error) {
+	query := `
+		SELECT ` + fraudFlagColumns + `
+		FROM fraud_flags f JOIN submissions s ON s.id = f.submission_id
+		WHERE f.id = $1
+	`
+	var f FraudFlag
+	if err := scanFraudFlag(fs.db.QueryRowContext(ctx, query, id), &f); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		log.Printf("error fetching fraud flag %s: %v\n", id, err.Error())
+		return nil, err
+	}
+	return &f, nil
+}
+
+// lists the flags in a state (all states if empty), oldest first
+func (fs *FraudStore) GetFraudFlags(ctx context.Context, status string, offset, limit int) ([]FraudFlag, error) {
+	query := `
+		SELECT ` + fraudFlagColumns + `
+		FROM fraud_flags f JOIN submissions s ON s.id = f.submission_id
+		WHERE ($1 = '' OR f.status = $1)
+		ORDER BY f.created_at ASC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := fs.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		log.Printf("error fetching fraud flags: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []FraudFlag
+	for rows.Next() {
+		var f FraudFlag
+		if err := scanFraudFlag(rows, &f); err != nil {
+			log.Printf("error scanning fraud flag: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, f)
+	}
+	return output, nil
+}
+
+// lists the decisions taken on a flag, oldest first
+func (fs *FraudStore) GetFraudDecisions(ctx context.Context, flagID string) ([]FraudDecision, error) {
+	query := `
+		SELECT id, flag_id, decision, decided_by, COALESCE(note, ''), evidence, created_at
+		FROM fraud_decisions
+		WHERE flag_id = $1
+		ORDER BY created_at ASC
+	`
+	rows, err := fs.db.QueryContext(ctx, query, flagID)
+	if err != nil {
+		log.Printf("error fetching fraud decisions: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []FraudDecision
+	for rows.Next() {
+		var d FraudDecision
+		err := rows.Scan(&d.Id, &d.FlagId, &d.Decision, &d.DecidedBy, &d.Note, &d.Evidence, &d.CreatedAt)
+		if err != nil {
+			log.Printf("error scanning fraud decision: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, d)
+	}
+	return output, nil
+}
+
+// ReviewFraudFlag closes an open flag. A cleared submission earns again,
+// including the views made while it was held. A confirmed one keeps its
+// payouts held.
+func (fs *FraudStore) ReviewFraudFlag(ctx context.Context, id, adminID, decision, note string) (*FraudFlag, error) {
+	if decision != FraudCleared && decision != FraudConfirmed {
+		return nil, ErrInvalidArgs
+	}
+	tx, err := fs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + fraudFlagColumns + `
+		FROM fraud_flags f JOIN submissions s ON s.id = f.submission_id
+		WHERE f.id = $1
+		FOR UPDATE OF f
+	`
+	var f FraudFlag
+	if err := scanFraudFlag(tx.QueryRowContext(ctx, query, id), &f); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if f.Status != FraudOpen {
+		return nil, ErrInvalidStatus
+	}
+
+	query = `
+		UPDATE fraud_flags
+		SET status = $2, reviewed_by = $3, note = NULLIF($4, ''), reviewed_at = now()
+		WHERE id = $1
+		RETURNING reviewed_at::text
+	`
+	if err := tx.QueryRowContext(ctx, query, id, decision, adminID, note).Scan(&f.ReviewedAt); err != nil {
+		log.Printf("error reviewing fraud flag %s: %v\n", id, err.Error())
+		return nil, err
+	}
+	f.Status, f.ReviewedBy, f.Note = decision, adminID, note
+
+	if decision == FraudCleared {
+		_, err = tx.ExecContext(ctx, `UPDATE submissions SET payouts_held = false WHERE id = $1`, f.SubmissionId)
+		if err != nil {
+			log.Printf("error releasing payouts of submission %s: %v\n", f.SubmissionId, err)
+			return nil, err
+		}
+	}
+	if err := recordFraudDecision(ctx, tx, id, decision, adminID, note, f.Evidence); err != nil {
+		log.Printf("error recording the review of flag %s: %v\n", id, err)
+		return nil, err
+	}
+
+	return &f, tx.Commit()
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestFraudReview(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	creator := generateCreator(ctx, "0001")
+	bid := uuid.New().String()
+	generateBrand(bid)
+	camp := SeedCampaign(ctx, bid, ActiveStatus, 1)
+	sub := Submission{
+		Id:         uuid.New().String(),
+		CreatorId:  creator,
+		CampaignId: camp[0],
+		Url:        "example.com",
+		Status:     ActiveStatus,
+		Views:      1000,
+	}
+	MockSubStore.MakeSubmission(ctx, sub)
+	defer func() {
+		MockSubStore.DeleteSubmission(ctx, sub.Id)
+		destroyCampaign(ctx, camp)
+		destroyBrand(bid)
+		destroyCreator(ctx, creator)
+		cancel()
+	}()
+	held := func() bool {
+		var held bool
+		MockFraudStore.db.QueryRowContext(ctx,
+			`SELECT payouts_held FROM submissions WHERE id = $1`, sub.Id,
+		).Scan(&held)
+		return held
+	}
+	newFlag := func() *FraudFlag {
+		return &FraudFlag{
+			SubmissionId: sub.Id,
+			Rules:        []string{"velocity_spike"},
+			Evidence:     json.RawMessage(`{"findings": []}`),
+		}
+	}
+
+	flag := newFlag()
+	t.Run("flag holds the payouts", func(t *testing.T) {
+		flagged, err := MockFraudStore.FlagSubmission(ctx, flag)
+		if err != nil || !flagged || !held() {
+			t.Fail()
+		}
+		// one open review per submission
+		if flagged, err := MockFraudStore.FlagSubmission(ctx, newFlag()); err != nil || flagged {
+			t.Fail()
+		}
+	})
+	t.Run("listed in the queue", func(t *testing.T) {
+		flags, err := MockFraudStore.GetFraudFlags(ctx, FraudOpen, 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, f := range flags {
+			found = found || (f.Id == flag.Id && f.CreatorId == creator && len(f.Rules) == 1)
+		}
+		if !found {
+			t.Fail()
+		}
+	})
+	t.Run("clear releases the payouts", func(t *testing.T) {
+		reviewed, err := MockFraudStore.ReviewFraudFlag(ctx, flag.Id, "admin", FraudCleared, "organic")
+		if err != nil || reviewed.Status != FraudCleared || held() {
+			t.Fail()
+		}
+		if _, err := MockFraudStore.ReviewFraudFlag(ctx, flag.Id, "admin", FraudConfirmed, "late"); err != ErrInvalidStatus {
+			t.Fail()
+		}
+		decisions, err := MockFraudStore.GetFraudDecisions(ctx, flag.Id)
+		if err != nil || len(decisions) != 2 || decisions[0].Decision != FraudHeld || decisions[1].Decision != FraudCleared {
+			t.Fail()
+		}
+	})
+	t.Run("recently cleared is not flagged again", func(t *testing.T) {
+		if flagged, err := MockFraudStore.FlagSubmission(ctx, newFlag()); err != nil || flagged || held() {
+			t.Fail()
+		}
+	})
+	t.Run("confirmed submission is not polled", func(t *testing.T) {
+		MockFraudStore.db.ExecContext(ctx,
+			`UPDATE submissions SET last_synced_at = now() - interval '1 day' WHERE id = $1`, sub.Id,
+		)
+		polled := func() bool {
+			subs, err := MockSubStore.GetSubmissionsForSync(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range subs {
+				if s.Id == sub.Id {
+					return true
+				}
+			}
+			return false
+		}
+		if !polled() {
+			t.Fatal("submission is not due")
+		}
+		confirmed := newFlag()
+		confirmed.Id = uuid.New().String()
+		MockFraudStore.db.ExecContext(ctx, `
+			INSERT INTO fraud_flags (id, submission_id, rules, evidence, status)
+			VALUES ($1, $2, $3, $4, $5)`,
+			confirmed.Id, sub.Id, pq.Array(confirmed.Rules), []byte(confirmed.Evidence), FraudConfirmed,
+		)
+		if polled() {
+			t.Fail()
+		}
+	})
+	t.Run("unknown flag", func(t *testing.T) {
+		if _, err := MockFraudStore.ReviewFraudFlag(ctx, uuid.New().String(), "admin", FraudConfirmed, ""); err != ErrNotFound {
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// system accounts seeded by the ledger migration
+const (
+	// money entering/leaving the platform (deposits and withdrawals)
+	ExternalAccountID = "00000000-0000-0000-0000-000000000001"
+)
+
+// macros for posting directions
+const (
+	DebitPosting  = "debit"  // takes money out of an account
+	CreditPosting = "credit" // puts money into an account
+)
+
+// macros for journal entry types
+const (
+	EntryDeposit  = "deposit"
+	EntryWithdraw = "withdraw"
+	EntryPayout   = "payout"
+	EntryOpening  = "opening"
+)
+
+var (
+	ErrUnbalancedEntry  = errors.New("journal entry is not balanced")
+	ErrAccountInactive  = errors.New("account not found or inactive")
+	ErrInsufficientFund = errors.New("insufficient balance")
+	ErrDuplicateTx      = errors.New("transaction already processed")
+	ErrAccountHistory   = errors.New("account has ledger history")
+)
+
+// A single leg of a journal entry
+type Posting struct {
+	AccountID string  `json:"account_id"`
+	Direction string  `json:"direction"`
+	Amount    float64 `json:"amount"`
+}
+
+// JournalEntry groups postings which must balance (debits = credits)
+type JournalEntry struct {
+	Id        string    `json:"id"`
+	TxId      string    `json:"tx_id,omitempty"`
+	Type      string    `json:"type"`
+	Memo      string    `json:"memo,omitempty"`
+	Rule      string    `json:"rule,omitempty"` // payout rule that produced a payout
+	Postings  []Posting `json:"postings"`
+	CreatedAt string    `json:"created_at"`
+}
+
+// One line of an account statement
+type LedgerLine struct {
+	EntryId   string  `json:"entry_id"`
+	TxId      string  `json:"tx_id,omitempty"`
+	Type      string  `json:"type"`
+	Memo      string  `json:"memo,omitempty"`
+	Rule      string  `json:"rule,omitempty"`
+	Direction string  `json:"direction"`
+	Amount    float64 `json:"amount"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// Account whose stored amount disagrees with its postings
+type BalanceMismatch struct {
+	AccountId     string  `json:"account_id"`
+	StoredBalance float64 `json:"stored_balance"`
+	LedgerBalance float64 `json:"ledger_balance"`
+}
+
+// rounds the amount to the precision of the numeric(12,2) columns
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func (e *JournalEntry) validate() error {
+	if len(e.Postings) < 2 {
+		return ErrUnbalancedEntry
+	}
+	var net int64
+	for i := range e.Postings {
+		p := &e.Postings[i]
+		p.Amount = roundCents(p.Amount)
+		if p.Amount <= 0 {
+			return ErrInvalidArgs
+		}
+		cents := int64(math.Round(p.Amount * 100))
+		switch p.Direction {
+		case DebitPosting:
+			net += cents
+		case CreditPosting:
+			net -= cents
+		default:
+			return ErrInvalidArgs
+		}
+	}
+	if net != 0 {
+		return ErrUnbalancedEntry
+	}
+	return nil
+}
+
+// postEntry writes a journal entry and applies its postings to the account
+// balances inside the caller's transaction. The accounts are locked in id
+// order so concurrent entries touching the same accounts cannot deadlock.
+func postEntry(ctx context.Context, tx *sql.Tx, entry *JournalEntry) error {
+	if err := entry.validate(); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(entry.Postings))
+	for _, p := range entry.Postings {
+		ids = append(ids, p.AccountID)
+	}
+	sort.Strings(ids)
+	lockQuery := `
+		SELECT id FROM accounts
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`
+	rows, err := tx.QueryContext(ctx, lockQuery, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("lock accounts: %w", err)
+	}
+	rows.Close()
+
+	entryQuery := `
+		INSERT INTO journal_entries (id, tx_id, type, memo, rule)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''))
+	`
+	if _, err := tx.ExecContext(ctx, entryQuery, entry.Id, entry.TxId, entry.Type, entry.Memo, entry.Rule); err != nil {
+		return fmt.Errorf("journal entry: %w", err)
+	}
+
+	postingQuery := `
+		INSERT INTO ledger_postings (entry_id, account_id, direction, amount)
+		VALUES ($1, $2, $3, $4)
+	`
+	creditQuery := `UPDATE accounts SET amount = amount + $1 WHERE id = $2 AND active = $3`
+	debitQuery := `
+		UPDATE accounts SET amount = amount - $1
+		WHERE id = $2 AND active = $3
+		AND (amount - $1 >= 0 OR holder_type = 'system')
+	`
+	for _, p := range entry.Postings {
+		balanceQuery := creditQuery
+		if p.Direction == DebitPosting {
+			balanceQuery = debitQuery
+		}
+		res, err := tx.ExecContext(ctx, balanceQuery, p.Amount, p.AccountID, true)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", p.Direction, p.AccountID, err)
+		}
+		if count, _ := res.RowsAffected(); count == 0 {
+			if p.Direction == DebitPosting {
+				return fmt.Errorf("debit %s: %w", p.AccountID, ErrInsufficientFund)
+			}
+			return fmt.Errorf("credit %s: %w", p.AccountID, ErrAccountInactive)
+		}
+		if _, err := tx.ExecContext(ctx, postingQuery, entry.Id, p.AccountID, p.Direction, p.Amount); err != nil {
+			return fmt.Errorf("posting %s: %w", p.AccountID, err)
+		}
+	}
+
+	return nil
+}
+
+// transfer builds the two legged entry moving amount from one account to another
+func transfer(id, txID, type_, memo, from, to string, amount float64) *JournalEntry {
+	return &JournalEntry{
+		Id:   id,
+		TxId: txID,
+		Type: type_,
+		Memo: memo,
+		Postings: []Posting{
+			{AccountID: from, Direction: DebitPosting, Amount: amount},
+			{AccountID: to, Direction: CreditPosting, Amount: amount},
+		},
+	}
+}
+
+// PostEntry writes a standalone journal entry in its own transaction
+func (txs *TransactionStore) PostEntry(ctx context.Context, entry *JournalEntry) error {
+	tx, err := txs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := postEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetStatement lists the postings of an account, latest first
+func (txs *TransactionStore) GetStatement(ctx context.Context, accID string, offset, limit int) ([]LedgerLine, error) {
+	query := `
+		SELECT e.id, COALESCE(e.tx_id, ''), e.type, COALESCE(e.memo, ''),
+		COALESCE(e.rule, ''), p.direction, p.amount, p.created_at
+		FROM ledger_postings p
+		JOIN journal_entries e ON e.id = p.entry_id
+		WHERE p.account_id = $1
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := txs.db.QueryContext(ctx, query, accID, limit, offset)
+	if err != nil {
+		log.Printf("error fetching statement for %s: %v\n", accID, err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []LedgerLine
+	for rows.Next() {
+		var line LedgerLine
+		err := rows.Scan(
+			&line.EntryId,
+			&line.TxId,
+			&line.Type,
+			&line.Memo,
+			&line.Rule,
+			&line.Direction,
+			&line.Amount,
+			&line.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("error scanning ledger line: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, line)
+	}
+
+	return output, nil
+}
+
+// ReconcileAccounts compares every stored balance against the sum of its postings
+// and returns the accounts that drifted
+func (txs *TransactionStore) ReconcileAccounts(ctx context.Context) ([]BalanceMismatch, error) {
+	query := `
+		SELECT a.id, a.amount, COALESCE(l.balance, 0)
+		FROM accounts a
+		LEFT JOIN (
+			SELECT account_id,
+			SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END) AS balance
+			FROM ledger_postings
+			GROUP BY account_id
+		) l ON l.account_id = a.id
+		WHERE a.amount <> COALESCE(l.balance, 0)
+	`
+	rows, err := txs.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("error reconciling accounts: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []BalanceMismatch
+	for rows.Next() {
+		var m BalanceMismatch
+		if err := rows.Scan(&m.AccountId, &m.StoredBalance, &m.LedgerBalance); err != nil {
+			log.Printf("error scanning mismatch: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, m)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLedger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	const amount float64 = 1000.0
+	// create mock creator
+	uid := uuid.New().String()
+	generateCreator(ctx, uid)
+
+	// create a mock brand
+	bid := uuid.New().String()
+	generateBrand(bid)
+
+	// Create their accounts
+	user_acc := generateAccounts(ctx, uid, "user")
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	defer func() {
+		destroyAllTransactions()
+		destroyAccounts(ctx, user_acc.Id, brand_acc.Id)
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+
+	t.Run("payout posts a balanced entry", func(t *testing.T) {
+		invoice := Transaction{
+			Id:     uuid.New().String(),
+			FromId: brand_acc.Id,
+			ToId:   user_acc.Id,
+			Amount: amount,
+			Type:   "payout",
+		}
+		if err := MockTsStore.Payout(ctx, &invoice); err != nil {
+			log.Printf("error: %s\n", err.Error())
+			t.Fail()
+			return
+		}
+		lines, err := MockTsStore.GetStatement(ctx, brand_acc.Id, 0, 10)
+		if err != nil || len(lines) == 0 {
+			t.Fail()
+			return
+		}
+		// latest line is the payout debit
+		if lines[0].TxId != invoice.Id || lines[0].Direction != DebitPosting || lines[0].Amount != amount {
+			t.Fail()
+		}
+	})
+	t.Run("unbalanced entry is rejected", func(t *testing.T) {
+		entry := JournalEntry{
+			Id:   uuid.New().String(),
+			Type: EntryPayout,
+			Postings: []Posting{
+				{AccountID: brand_acc.Id, Direction: DebitPosting, Amount: amount},
+				{AccountID: user_acc.Id, Direction: CreditPosting, Amount: amount + 1},
+			},
+		}
+		if err := MockTsStore.PostEntry(ctx, &entry); err != ErrUnbalancedEntry {
+			t.Fail()
+		}
+	})
+	t.Run("overdraft is rejected", func(t *testing.T) {
+		acc, _ := MockTsStore.GetAccount(ctx, brand_acc.Id)
+		entry := transfer(uuid.New().String(), "", EntryPayout, "", brand_acc.Id, user_acc.Id, acc.Amount+amount)
+		if err := MockTsStore.PostEntry(ctx, entry); err == nil {
+			t.Fail()
+		}
+	})
+	t.Run("balances reconcile with the ledger", func(t *testing.T) {
+		mismatches, err := MockTsStore.ReconcileAccounts(ctx)
+		if err != nil {
+			t.Fail()
+			return
+		}
+		for _, m := range mismatches {
+			if m.AccountId == user_acc.Id || m.AccountId == brand_acc.Id {
+				log.Printf("mismatch on %s: %f != %f\n", m.AccountId, m.StoredBalance, m.LedgerBalance)
+				t.Fail()
+			}
+		}
+	})
+}
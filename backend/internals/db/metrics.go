@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// resolutions of the stored metric points
+const (
+	MetricsRaw  = "raw"
+	MetricsHour = "hour"
+	MetricsDay  = "day"
+)
+
+// raw points are rolled up into hours after a week, hours into days after 90 days
+const (
+	RawMetricsRetention  = 7 * 24 * time.Hour
+	HourMetricsRetention = 90 * 24 * time.Hour
+)
+
+type MetricsStore struct {
+	db *sql.DB
+}
+
+// MetricPoint is one sample of a submission's counters, or a bucket of them
+// when read back as a series
+type MetricPoint struct {
+	SubmissionId  string  `json:"submission_id,omitempty"`
+	Views         int     `json:"views"`
+	LikeCount     int     `json:"like_count"`
+	ViewsDelta    int     `json:"views_delta"`
+	EarningsDelta float64 `json:"earnings_delta"`
+	Source        string  `json:"source,omitempty"`
+	RecordedAt    string  `json:"recorded_at"`
+}
+
+// RecordMetrics stores the points of one poll in a single transaction. The
+// views delta is taken from the previous point of the submission, or from
+// its synced views for the first one.
+func (m *MetricsStore) RecordMetrics(ctx context.Context, points []MetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO submission_metrics
+		(submission_id, views, like_count, views_delta, earnings_delta, source)
+		SELECT s.id, $2, $3, $2 - COALESCE(
+			(
+				SELECT views FROM submission_metrics
+				WHERE submission_id = s.id
+				ORDER BY recorded_at DESC, id DESC
+				LIMIT 1
+			), s.views), $4, $5
+		FROM submissions s
+		WHERE s.id = $1
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, point := range points {
+		_, err := stmt.ExecContext(ctx,
+			point.SubmissionId, point.Views, point.LikeCount,
+			point.EarningsDelta, point.Source,
+		)
+		if err != nil {
+			log.Printf("error recording metrics of submission %s: %v\n", point.SubmissionId, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetMetricSeries returns the counters of a submission in [from, to) per
+// hour or day. Views and likes are the latest of each bucket, deltas add up.
+func (m *MetricsStore) GetMetricSeries(ctx context.Context, submissionID, bucket string, from, to time.Time) ([]MetricPoint, error) {
+	if bucket != MetricsHour && bucket != MetricsDay {
+		return nil, ErrInvalidArgs
+	}
+	query := `
+		SELECT date_trunc($2::text, recorded_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS bucket,
+			MAX(views), MAX(like_count), SUM(views_delta), SUM(earnings_delta)
+		FROM submission_metrics
+		WHERE submission_id = $1 AND recorded_at >= $3 AND recorded_at < $4
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`
+	rows, err := m.db.QueryContext(ctx, query, submissionID, bucket, from, to)
+	if err != nil {
+		log.Printf("error fetching metrics of submission %s: %v\n", submissionID, err)
+		return nil, ErrServer
+	}
+	defer rows.Close()
+
+	output := []MetricPoint{}
+	for rows.Next() {
+		var point MetricPoint
+		var recordedAt time.Time
+		err := rows.Scan(
+			&recordedAt,
+			&point.Views,
+			&point.LikeCount,
+			&point.ViewsDelta,
+			&point.EarningsDelta,
+		)
+		if err != nil {
+			log.Printf("error scanning metrics: %v\n", err)
+			return nil, err
+		}
+		point.RecordedAt = recordedAt.UTC().Format(time.RFC3339)
+		output = append(output, point)
+	}
+	return output, rows.Err()
+}
+
+// GetRecentMetrics returns the raw points of many submissions recorded since
+// a time, oldest first
+func (m *MetricsStore) GetRecentMetrics(ctx context.Context, submissionIDs []string, since time.Time) (map[string][]MetricPoint, error) {
+	query := `
+		SELECT submission_id, views, like_count, views_delta, earnings_delta, source, recorded_at
+		FROM submission_metrics
+		WHERE submission_id = ANY($1) AND resolution = 'raw' AND recorded_at >= $2
+		ORDER BY submission_id, recorded_at ASC, id ASC
+	`
+	rows, err := m.db.QueryContext(ctx, query, pq.Array(submissionIDs), since)
+	if err != nil {
+		log.Printf("error fetching recent metrics: %v\n", err)
+		return nil, ErrServer
+	}
+	defer rows.Close()
+
+	output := make(map[string][]MetricPoint)
+	for rows.Next() {
+		var point MetricPoint
+		var recordedAt time.Time
+		err := rows.Scan(
+			&point.SubmissionId,
+			&point.Views,
+			&point.LikeCount,
+			&point.ViewsDelta,
+			&point.EarningsDelta,
+			&point.Source,
+			&recordedAt,
+		)
+		if err != nil {
+			log.Printf("error scanning metrics: %v\n", err)
+			return nil, err
+		}
+		point.RecordedAt = recordedAt.UTC().Format(time.RFC3339)
+		output[point.SubmissionId] = append(output[point.SubmissionId], point)
+	}
+	return output, rows.Err()
+}
+
+// CompactMetrics rolls the raw points older than RawMetricsRetention into
+// hourly points and the hourly points older than HourMetricsRetention into
+// daily ones. The cutoffs are aligned so a bucket is never split across runs.
+func (m *MetricsStore) CompactMetrics(ctx context.Context, now time.Time) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		WITH rolled AS (
+			DELETE FROM submission_metrics
+			WHERE resolution = $1::text AND recorded_at < $3
+			RETURNING submission_id, views, like_count, views_delta, earnings_delta, recorded_at
+		)
+		INSERT INTO submission_metrics
+		(submission_id, views, like_count, views_delta, earnings_delta, source, resolution, recorded_at)
+		SELECT submission_id, MAX(views), MAX(like_count), SUM(views_delta), SUM(earnings_delta),
+			'rollup', $2::text, date_trunc($2::text, recorded_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+		FROM rolled
+		GROUP BY submission_id, date_trunc($2::text, recorded_at AT TIME ZONE 'UTC')
+	`
+	now = now.UTC()
+	steps := []struct {
+		from, to string
+		cutoff   time.Time
+	}{
+		{MetricsRaw, MetricsHour, now.Add(-RawMetricsRetention).Truncate(time.Hour)},
+		{MetricsHour, MetricsDay, now.Add(-HourMetricsRetention).Truncate(24 * time.Hour)},
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, query, step.from, step.to, step.cutoff); err != nil {
+			log.Printf("error rolling %s metrics into %s: %v\n", step.from, step.to, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSubmissionMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	creator := generateCreator(ctx, "0001")
+	bid := uuid.New().String()
+	generateBrand(bid)
+	camp := SeedCampaign(ctx, bid, ActiveStatus, 1)
+	sub := Submission{
+		Id:         uuid.New().String(),
+		CreatorId:  creator,
+		CampaignId: camp[0],
+		Url:        "example.com",
+		Status:     ActiveStatus,
+		Views:      1000,
+	}
+	MockSubStore.MakeSubmission(ctx, sub)
+	defer func() {
+		MockSubStore.DeleteSubmission(ctx, sub.Id)
+		destroyCampaign(ctx, camp)
+		destroyBrand(bid)
+		destroyCreator(ctx, creator)
+		cancel()
+	}()
+
+	t.Run("deltas follow the previous point", func(t *testing.T) {
+		points := []MetricPoint{
+			{SubmissionId: sub.Id, Views: 1200, LikeCount: 10, EarningsDelta: 0.4, Source: "polling_worker"},
+		}
+		if err := MockMetricsStore.RecordMetrics(ctx, points); err != nil {
+			t.Fatal(err)
+		}
+		points[0].Views, points[0].EarningsDelta = 1500, 0.6
+		if err := MockMetricsStore.RecordMetrics(ctx, points); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		series, err := MockMetricsStore.GetMetricSeries(ctx, sub.Id, MetricsDay, now.Add(-48*time.Hour), now.Add(time.Hour))
+		if err != nil || len(series) != 1 {
+			t.Fatal(err)
+		}
+		// 1000 -> 1200 -> 1500 in one bucket
+		if series[0].Views != 1500 || series[0].ViewsDelta != 500 || series[0].EarningsDelta != 1.0 {
+			log.Printf("got: %+v\n", series[0])
+			t.Fail()
+		}
+	})
+	t.Run("invalid bucket", func(t *testing.T) {
+		_, err := MockMetricsStore.GetMetricSeries(ctx, sub.Id, "minute", time.Now().Add(-time.Hour), time.Now())
+		if err != ErrInvalidArgs {
+			t.Fail()
+		}
+	})
+	t.Run("compaction keeps the totals", func(t *testing.T) {
+		// both points fall out of the raw retention
+		old := time.Now().Add(-RawMetricsRetention - 2*time.Hour)
+		MockMetricsStore.db.ExecContext(ctx,
+			`UPDATE submission_metrics SET recorded_at = $2 WHERE submission_id = $1`, sub.Id, old,
+		)
+		if err := MockMetricsStore.CompactMetrics(ctx, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		var rows, views, delta int
+		var resolution string
+		MockMetricsStore.db.QueryRowContext(ctx, `
+			SELECT COUNT(*), MAX(views), SUM(views_delta), MAX(resolution)
+			FROM submission_metrics WHERE submission_id = $1`, sub.Id,
+		).Scan(&rows, &views, &delta, &resolution)
+		if rows != 1 || views != 1500 || delta != 500 || resolution != MetricsHour {
+			log.Printf("rows: %d, views: %d, delta: %d, resolution: %s\n", rows, views, delta, resolution)
+			t.Fail()
+		}
+	})
+}
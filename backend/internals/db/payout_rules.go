@@ -0,0 +1,482 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// kinds of payout rules
+const (
+	RuleTier      = "tier"      // Amount is the CPM of the views past Threshold
+	RuleMilestone = "milestone" // Amount is paid once when a submission reaches Threshold views
+	RuleFlat      = "flat"      // Amount is paid once when a submission is approved
+)
+
+// label of the ledger entries paying the campaign CPM
+const BaseCPMRule = "cpm"
+
+const maxPayoutRules = 20
+
+// A payout rule of a campaign, the campaign CPM pays the views below the
+// first tier
+type PayoutRule struct {
+	Kind      string  `json:"kind"`
+	Threshold int     `json:"threshold,omitempty"` // views, none for a flat fee
+	Amount    float64 `json:"amount"`
+	Label     string  `json:"label,omitempty"`
+}
+
+// RuleLabel names the rule in the ledger
+func (r *PayoutRule) RuleLabel() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	if r.Kind == RuleFlat {
+		return RuleFlat
+	}
+	return fmt.Sprintf("%s:%d", r.Kind, r.Threshold)
+}
+
+// ValidatePayoutRules checks the rules of a campaign, a tier or milestone
+// threshold is used once and there is at most one flat fee
+func ValidatePayoutRules(rules []PayoutRule) error {
+	if len(rules) > maxPayoutRules {
+		return fmt.Errorf("%w: at most %d payout rules", ErrInvalidArgs, maxPayoutRules)
+	}
+	seen := make(map[string]bool, len(rules))
+	labels := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		switch r.Kind {
+		case RuleTier, RuleMilestone:
+			if r.Threshold <= 0 {
+				return fmt.Errorf("%w: %s needs a positive threshold", ErrInvalidArgs, r.Kind)
+			}
+		case RuleFlat:
+			if r.Threshold != 0 {
+				return fmt.Errorf("%w: a flat fee has no threshold", ErrInvalidArgs)
+			}
+		default:
+			return fmt.Errorf("%w: unknown payout rule %q", ErrInvalidArgs, r.Kind)
+		}
+		if r.Amount <= 0 || roundCents(r.Amount) != r.Amount {
+			return fmt.Errorf("%w: payout rule amount must be positive cents", ErrInvalidArgs)
+		}
+		if len(r.Label) > 64 || r.Label == BaseCPMRule {
+			return fmt.Errorf("%w: invalid payout rule label", ErrInvalidArgs)
+		}
+		key := fmt.Sprintf("%s:%d", r.Kind, r.Threshold)
+		if seen[key] || labels[r.RuleLabel()] {
+			return fmt.Errorf("%w: duplicate payout rule %s", ErrInvalidArgs, r.RuleLabel())
+		}
+		seen[key], labels[r.RuleLabel()] = true, true
+	}
+	return nil
+}
+
+// TierEarnings splits the CPM earnings of the views (from, to] by the rule
+// paying them, the campaign CPM pays up to the first tier
+func TierEarnings(cpm float64, rules []PayoutRule, from, to int) map[string]float64 {
+	out := make(map[string]float64)
+	if to <= from {
+		return out
+	}
+	var tiers []PayoutRule
+	for _, r := range rules {
+		if r.Kind == RuleTier {
+			tiers = append(tiers, r)
+		}
+	}
+	slices.SortFunc(tiers, func(a, b PayoutRule) int { return a.Threshold - b.Threshold })
+
+	label, rate, start := BaseCPMRule, cpm, 0
+	pay := func(end int) {
+		lo, hi := max(from, start), min(to, end)
+		if hi > lo {
+			out[label] += float64(hi-lo) * rate / 1000.0
+		}
+	}
+	for _, tier := range tiers {
+		pay(tier.Threshold)
+		label, rate, start = tier.RuleLabel(), tier.Amount, tier.Threshold
+	}
+	pay(math.MaxInt)
+	return out
+}
+
+// ViewEarnings is the total CPM earnings of the views (from, to]
+func ViewEarnings(cpm float64, rules []PayoutRule, from, to int) float64 {
+	var total float64
+	for _, amount := range TierEarnings(cpm, rules, from, to) {
+		total += amount
+	}
+	return total
+}
+
+// Replaces the payout rules of a campaign, no rules pay the plain CPM
+func (c *CampaignStore) SetPayoutRules(ctx context.Context, id string, rules []PayoutRule) error {
+	if err := ValidatePayoutRules(rules); err != nil {
+		return err
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error beginning transaction: %v\n", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	// the campaign row orders rule changes against running payouts
+	if _, _, _, err := lockCampaign(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM campaign_payout_rules WHERE campaign_id = $1`, id); err != nil {
+		log.Printf("error clearing payout rules of campaign(%s): %v\n", id, err.Error())
+		return err
+	}
+	if err := setPayoutRules(ctx, tx, id, rules); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setPayoutRules(ctx context.Context, tx *sql.Tx, id string, rules []PayoutRule) error {
+	query := `
+		INSERT INTO campaign_payout_rules (campaign_id, kind, threshold, amount, label)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+	`
+	for _, r := range rules {
+		_, err := tx.ExecContext(ctx, query, id, r.Kind, r.Threshold, r.Amount, r.Label)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return ErrNotFound
+			}
+			log.Printf("error setting payout rules of campaign(%s): %v\n", id, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+const payoutRulesQuery = `
+	SELECT kind, threshold, amount, COALESCE(label, '')
+	FROM campaign_payout_rules
+	WHERE campaign_id = $1
+	ORDER BY kind, threshold
+`
+
+func scanPayoutRules(rows *sql.Rows) ([]PayoutRule, error) {
+	defer rows.Close()
+	rules := []PayoutRule{}
+	for rows.Next() {
+		var r PayoutRule
+		if err := rows.Scan(&r.Kind, &r.Threshold, &r.Amount, &r.Label); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// Returns the payout rules of a campaign
+func (c *CampaignStore) GetPayoutRules(ctx context.Context, id string) ([]PayoutRule, error) {
+	rows, err := c.db.QueryContext(ctx, payoutRulesQuery, id)
+	if err != nil {
+		log.Printf("error fetching payout rules of campaign(%s): %v\n", id, err.Error())
+		return nil, err
+	}
+	rules, err := scanPayoutRules(rows)
+	if err != nil {
+		log.Printf("error scanning payout rules of campaign(%s): %v\n", id, err.Error())
+		return nil, err
+	}
+	return rules, nil
+}
+
+// payoutRules reads the payout rules of a campaign locked by the transaction
+func payoutRules(ctx context.Context, tx *sql.Tx, id string) ([]PayoutRule, error) {
+	rows, err := tx.QueryContext(ctx, payoutRulesQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("payout rules: %w", err)
+	}
+	rules, err := scanPayoutRules(rows)
+	if err != nil {
+		return nil, fmt.Errorf("payout rules: %w", err)
+	}
+	return rules, nil
+}
+
+// A one-off rule paid to a submission with a payout
+type RuleAward struct {
+	SubmissionID string
+	Kind         string
+	Threshold    int
+	Label        string
+	Amount       float64
+}
+
+// dueAwards lists the milestones reached and not paid yet to the submissions
+// of the payout, lowest threshold first. The flat fee is paid on approval
+func dueAwards(ctx context.Context, tx *sql.Tx, payout *CampaignPayout) ([]RuleAward, error) {
+	query := `
+		SELECT r.kind, r.threshold, r.amount, COALESCE(r.label, '')
+		FROM campaign_payout_rules r
+		JOIN submissions s ON s.id = $2
+		WHERE r.campaign_id = $1
+		AND r.kind = 'milestone' AND s.accounted_views >= r.threshold
+		AND NOT EXISTS (
+			SELECT 1 FROM payout_rule_awards a
+			WHERE a.submission_id = s.id AND a.kind = r.kind AND a.threshold = r.threshold
+		)
+		ORDER BY r.threshold
+	`
+	var awards []RuleAward
+	for subID := range payout.Submissions {
+		rows, err := tx.QueryContext(ctx, query, payout.CampaignID, subID)
+		if err != nil {
+			return nil, fmt.Errorf("awards of submission %s: %w", subID, err)
+		}
+		rules, err := scanPayoutRules(rows)
+		if err != nil {
+			return nil, fmt.Errorf("awards of submission %s: %w", subID, err)
+		}
+		for _, r := range rules {
+			awards = append(awards, RuleAward{
+				SubmissionID: subID,
+				Kind:         r.Kind,
+				Threshold:    r.Threshold,
+				Label:        r.RuleLabel(),
+				Amount:       r.Amount,
+			})
+		}
+	}
+	slices.SortFunc(awards, func(a, b RuleAward) int { return a.Threshold - b.Threshold })
+	return awards, nil
+}
+
+// awardRules adds the one-off rules due to the payout as long as they fit
+// whole in the budget and the creator earnings cap left after the CPM
+// shares. An award that does not fit is retried with the next payout
+func awardRules(ctx context.Context, tx *sql.Tx, payout *CampaignPayout, caps *CampaignCaps, budget float64) error {
+	awards, err := dueAwards(ctx, tx, payout)
+	if err != nil || len(awards) == 0 {
+		return err
+	}
+	left := budget - payout.shares()
+	if caps.MaxCreatorEarnings != nil {
+		earnedQuery := `
+			SELECT COALESCE(SUM(earnings), 0) FROM submissions
+			WHERE campaign_id = $1 AND creator_id = $2
+		`
+		var earned float64
+		if err := tx.QueryRowContext(ctx, earnedQuery, payout.CampaignID, payout.CreatorID).Scan(&earned); err != nil {
+			return fmt.Errorf("creator earnings: %w", err)
+		}
+		left = min(left, *caps.MaxCreatorEarnings-earned-payout.shares())
+	}
+	for _, award := range awards {
+		if award.Amount > roundCents(left) {
+			continue
+		}
+		left -= award.Amount
+		payout.Awards = append(payout.Awards, award)
+	}
+	return nil
+}
+
+// ruleEntries splits the payout by the rule that produced it. The CPM
+// share of a submission is spread over its tiers in proportion to the
+// views it paid, one-off awards keep their own label
+func ruleEntries(payout *CampaignPayout, cpm float64, rules []PayoutRule) map[string]float64 {
+	byRule := make(map[string]float64)
+	for subID, share := range payout.Submissions {
+		share = roundCents(share)
+		if share <= 0 {
+			continue
+		}
+		views, ranged := payout.Views[subID]
+		tiers := map[string]float64{BaseCPMRule: 1}
+		if paid := payout.Paid[subID]; ranged && paid > 0 {
+			tiers = TierEarnings(cpm, rules, views.From, views.From+paid)
+		}
+		var total float64
+		for _, amount := range tiers {
+			total += amount
+		}
+		if total <= 0 {
+			byRule[BaseCPMRule] += share
+			continue
+		}
+		for label, amount := range tiers {
+			byRule[label] += share * amount / total
+		}
+	}
+	// rounded rule by rule, the cents lost go to the largest rule
+	var cents int64
+	largest := ""
+	for label, amount := range byRule {
+		byRule[label] = roundCents(amount)
+		cents += int64(math.Round(byRule[label] * 100))
+		if largest == "" || byRule[label] > byRule[largest] {
+			largest = label
+		}
+	}
+	if largest != "" {
+		want := int64(math.Round(payout.shares() * 100))
+		byRule[largest] = roundCents(byRule[largest] + float64(want-cents)/100)
+	}
+	for _, award := range payout.Awards {
+		byRule[award.Label] += award.Amount
+	}
+	for label, amount := range byRule {
+		if amount <= 0 {
+			delete(byRule, label)
+		}
+	}
+	return byRule
+}
+
+// recordAwards marks the one-off rules of the payout as paid
+func recordAwards(ctx context.Context, tx *sql.Tx, payout *CampaignPayout, txID string) error {
+	query := `
+		INSERT INTO payout_rule_awards (submission_id, kind, threshold, tx_id, amount)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	for _, award := range payout.Awards {
+		_, err := tx.ExecContext(ctx, query, award.SubmissionID, award.Kind, award.Threshold, txID, award.Amount)
+		if err != nil {
+			return fmt.Errorf("award %s of submission %s: %w", award.Label, award.SubmissionID, err)
+		}
+	}
+	return nil
+}
+
+// payFlatFee pays the flat fee of the campaign to a submission the brand just
+// approved, from the campaign hold and in its own ledger entry. The campaign
+// is locked by the caller. A creator past the earnings cap gets no fee and a
+// budget too small for it fails the approval. It reports whether the fee used
+// up the budget and ended the campaign.
+func payFlatFee(ctx context.Context, tx *sql.Tx, submissionID, creatorID, campaignID, brandID string, budget float64) (bool, error) {
+	ruleQuery := `
+		SELECT r.amount, COALESCE(r.label, '')
+		FROM campaign_payout_rules r
+		WHERE r.campaign_id = $1 AND r.kind = $2
+		AND NOT EXISTS (
+			SELECT 1 FROM payout_rule_awards a
+			WHERE a.submission_id = $3 AND a.kind = r.kind AND a.threshold = r.threshold
+		)
+	`
+	fee := PayoutRule{Kind: RuleFlat}
+	err := tx.QueryRowContext(ctx, ruleQuery, campaignID, RuleFlat, submissionID).Scan(&fee.Amount, &fee.Label)
+	if err == sql.ErrNoRows {
+		// no flat fee or paid already
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("flat fee: %w", err)
+	}
+	_, caps, err := campaignCaps(ctx, tx, campaignID)
+	if err != nil {
+		return false, err
+	}
+	if caps.MaxCreatorEarnings != nil {
+		earnedQuery := `
+			SELECT COALESCE(SUM(earnings), 0) FROM submissions
+			WHERE campaign_id = $1 AND creator_id = $2
+		`
+		var earned float64
+		if err := tx.QueryRowContext(ctx, earnedQuery, campaignID, creatorID).Scan(&earned); err != nil {
+			return false, fmt.Errorf("creator earnings: %w", err)
+		}
+		if fee.Amount > roundCents(*caps.MaxCreatorEarnings-earned) {
+			log.Printf("flat fee of submission %s skipped: creator earnings cap reached\n", submissionID)
+			return false, nil
+		}
+	}
+	if fee.Amount > roundCents(budget) {
+		return false, fmt.Errorf("flat fee: %w", ErrInsufficientFund)
+	}
+
+	budgetQuery := `
+		UPDATE campaigns
+		SET budget = budget - $1
+		WHERE id = $2
+			AND budget - $1 >= 0
+	`
+	accountQuery := `
+		SELECT id FROM accounts
+		WHERE holder_id = $1 AND holder_type = $2 AND active = $3
+	`
+	logQuery := `
+		INSERT INTO transactions (id, from_id, to_id, amount, status, type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	linkQuery := `
+		INSERT INTO submission_payouts (submission_id, tx_id, amount)
+		VALUES ($1, $2, $3)
+	`
+	earningsQuery := `UPDATE submissions SET earnings = earnings + $1 WHERE id = $2`
+
+	holdAcc, err := fundedHold(ctx, tx, campaignID, brandID, budget)
+	if err != nil {
+		return false, err
+	}
+	var creatorAcc string
+	if err := tx.QueryRowContext(ctx, accountQuery, creatorID, "user", true).Scan(&creatorAcc); err != nil {
+		return false, fmt.Errorf("creator account: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, budgetQuery, fee.Amount, campaignID)
+	if err != nil {
+		return false, fmt.Errorf("budget: %w", err)
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return false, fmt.Errorf("budget: %w", ErrInsufficientFund)
+	}
+
+	txID := uuid.New().String()
+	if _, err := tx.ExecContext(ctx, logQuery, txID, holdAcc, creatorAcc, fee.Amount, SuccessTxStatus, EntryPayout); err != nil {
+		return false, fmt.Errorf("log transaction: %w", err)
+	}
+	entry := transfer(uuid.New().String(), txID, EntryPayout,
+		fmt.Sprintf("%s earnings for campaign %s", fee.RuleLabel(), campaignID),
+		holdAcc, creatorAcc, fee.Amount)
+	entry.Rule = fee.RuleLabel()
+	if err := postEntry(ctx, tx, entry); err != nil {
+		return false, err
+	}
+	award := &CampaignPayout{Awards: []RuleAward{{
+		SubmissionID: submissionID,
+		Kind:         RuleFlat,
+		Label:        fee.RuleLabel(),
+		Amount:       fee.Amount,
+	}}}
+	if err := recordAwards(ctx, tx, award, txID); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, linkQuery, submissionID, txID, fee.Amount); err != nil {
+		return false, fmt.Errorf("link submission %s: %w", submissionID, err)
+	}
+	if _, err := tx.ExecContext(ctx, earningsQuery, fee.Amount, submissionID); err != nil {
+		return false, fmt.Errorf("earnings of submission %s: %w", submissionID, err)
+	}
+	if roundCents(budget-fee.Amount) < 0.01 {
+		err := transitionCampaign(ctx, tx, CampaignTransition{
+			CampaignID: campaignID,
+			To:         ExpiredStatus,
+			ActorID:    CampaignSystemActor,
+			Reason:     "budget exhausted",
+		})
+		if err != nil {
+			return false, fmt.Errorf("end campaign: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
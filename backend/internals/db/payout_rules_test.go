@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPayoutRules(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	uid := generateCreator(ctx, uuid.New().String())
+	bid := uuid.New().String()
+	generateBrand(bid)
+	campaign := Campaign{
+		Id:       uuid.New().String(),
+		BrandId:  bid,
+		Title:    "rules_campaign",
+		Budget:   5000,
+		CPM:      100,
+		Platform: "youtube",
+		Status:   DraftStatus,
+		PayoutRules: []PayoutRule{
+			{Kind: RuleTier, Threshold: 1000, Amount: 200},
+			{Kind: RuleMilestone, Threshold: 1500, Amount: 30, Label: "launch bonus"},
+			{Kind: RuleFlat, Amount: 20},
+		},
+	}
+	subID, lastID := uuid.New().String(), uuid.New().String()
+	user_acc := generateAccounts(ctx, uid, "user")
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	defer func() {
+		destroyAllTransactions()
+		destroyHold(ctx, campaign.Id)
+		destroyAccounts(ctx, user_acc.Id, brand_acc.Id)
+		destroySubmissions(ctx, []string{subID, lastID})
+		destroyCampaign(ctx, []string{campaign.Id})
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+	if err := MockCampaignStore.LaunchCampaign(ctx, &campaign); err != nil {
+		log.Printf("error launching campaign: %v", err)
+		t.Fail()
+		return
+	}
+	MockCampaignStore.ActivateCampaign(ctx, campaign.Id)
+	query := `
+		INSERT INTO submissions (id, creator_id, campaign_id, url, status, video_platform, video_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	MockSubStore.db.ExecContext(ctx, query, subID, uid, campaign.Id, "mock_url", SubmissionPending, "youtube", "available")
+
+	t.Run("tiers split the views", func(t *testing.T) {
+		got := TierEarnings(100, campaign.PayoutRules, 500, 1500)
+		if len(got) != 2 || got[BaseCPMRule] != 50 || got["tier:1000"] != 100 {
+			log.Printf("got: %v", got)
+			t.Fail()
+		}
+		if ViewEarnings(100, nil, 0, 1000) != 100 {
+			t.Fail()
+		}
+	})
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rules := range [][]PayoutRule{
+			{{Kind: RuleTier, Threshold: 1000, Amount: 200}, {Kind: RuleTier, Threshold: 1000, Amount: 300}},
+			{{Kind: RuleMilestone, Amount: 30}},
+			{{Kind: RuleFlat, Amount: 20}, {Kind: RuleFlat, Amount: 10}},
+			{{Kind: "bonus", Amount: 20}},
+		} {
+			if err := MockCampaignStore.SetPayoutRules(ctx, campaign.Id, rules); !errors.Is(err, ErrInvalidArgs) {
+				t.Fail()
+			}
+		}
+		got, err := MockCampaignStore.GetPayoutRules(ctx, campaign.Id)
+		if err != nil || len(got) != 3 {
+			t.Fail()
+		}
+	})
+	t.Run("flat fee is paid on approval", func(t *testing.T) {
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		exhausted, err := MockSubStore.ReviewSubmission(ctx, subID, bid, SubmissionApproved, "")
+		if err != nil || exhausted {
+			log.Printf("error approving submission: %v", err)
+			t.Fail()
+			return
+		}
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		if after.Amount != before.Amount+20.0 {
+			log.Printf("expected %v got %v\n", before.Amount+20.0, after.Amount)
+			t.Fail()
+		}
+		lines, err := MockTsStore.GetStatement(ctx, user_acc.Id, 0, 10)
+		if err != nil || len(lines) == 0 || lines[0].Rule != RuleFlat || lines[0].Amount != 20.0 {
+			t.Fail()
+		}
+		var awarded int
+		MockSubStore.db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM payout_rule_awards WHERE submission_id = $1 AND kind = $2`, subID, RuleFlat,
+		).Scan(&awarded)
+		if awarded != 1 {
+			t.Fail()
+		}
+	})
+	t.Run("entries are labelled by rule", func(t *testing.T) {
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		payout := &CampaignPayout{
+			CampaignID:  campaign.Id,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: ViewEarnings(100, campaign.PayoutRules, 0, 2000)},
+			Views:       map[string]ViewRange{subID: {From: 0, To: 2000}},
+		}
+		if err := MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout}); err != nil || payout.TxId == "" {
+			t.Fail()
+			return
+		}
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		// the flat fee was paid on approval
+		if after.Amount != before.Amount+330.0 {
+			log.Printf("expected %v got %v\n", before.Amount+330.0, after.Amount)
+			t.Fail()
+		}
+		lines, err := MockTsStore.GetStatement(ctx, user_acc.Id, 0, 10)
+		if err != nil {
+			t.Fail()
+			return
+		}
+		want := map[string]float64{BaseCPMRule: 100, "tier:1000": 200, "launch bonus": 30}
+		got := make(map[string]float64)
+		for _, line := range lines {
+			if line.TxId == payout.TxId {
+				got[line.Rule] += line.Amount
+			}
+		}
+		if len(got) != len(want) {
+			log.Printf("got: %v", got)
+			t.Fail()
+		}
+		for rule, amount := range want {
+			if got[rule] != amount {
+				log.Printf("rule %s: got %v, want %v", rule, got[rule], amount)
+				t.Fail()
+			}
+		}
+	})
+	t.Run("one-off rules are paid once", func(t *testing.T) {
+		before, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		payout := &CampaignPayout{
+			CampaignID:  campaign.Id,
+			CreatorID:   uid,
+			Submissions: map[string]float64{subID: 100.0},
+			Views:       map[string]ViewRange{subID: {From: 2000, To: 2500}},
+		}
+		MockBatchStore.BatchPayouts(ctx, []*CampaignPayout{payout})
+		after, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		if after.Amount != before.Amount+100.0 || len(payout.Awards) != 0 {
+			log.Printf("expected %v got %v\n", before.Amount+100.0, after.Amount)
+			t.Fail()
+		}
+	})
+	t.Run("flat fee using up the budget ends the campaign", func(t *testing.T) {
+		MockSubStore.db.ExecContext(ctx, query, lastID, uid, campaign.Id, "mock_url", SubmissionPending, "youtube", "available")
+		MockCampaignStore.db.ExecContext(ctx, `UPDATE campaigns SET budget = 20 WHERE id = $1`, campaign.Id)
+		exhausted, err := MockSubStore.ReviewSubmission(ctx, lastID, bid, SubmissionApproved, "")
+		if err != nil || !exhausted {
+			log.Printf("exhausted: %v, err: %v", exhausted, err)
+			t.Fail()
+		}
+		ended, _ := MockCampaignStore.GetCampaign(ctx, campaign.Id)
+		if ended.Status != ExpiredStatus || ended.Budget != 0 {
+			t.Fail()
+		}
+	})
+}
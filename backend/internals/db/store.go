@@ -29,9 +29,10 @@ const (
 
 // macros for campaign status
 const (
-	ActiveStatus  int = 1
-	DraftStatus   int = 0
-	ExpiredStatus int = 3
+	ActiveStatus        int = 1
+	DraftStatus         int = 0
+	PendingReviewStatus int = 2
+	ExpiredStatus       int = 3
 )
 
 // macros for application status
@@ -41,6 +42,14 @@ const (
 	RejectedStatus int = 0
 )
 
+// macros for submission review status, only approved submissions earn
+const (
+	SubmissionRejected int = 5
+	SubmissionApproved int = 1
+	SubmissionPending  int = 2
+	SubmissionRevision int = 4 // the brand asked the creator for changes
+)
+
 // macros for db errors
 var (
 	ErrServer           = errors.New("internal server error")
@@ -52,6 +61,7 @@ var (
 	ErrInvalidId        = errors.New("invalid id")
 	ErrInvalidArgs      = errors.New("invalid args")
 	ErrInvalidStatus    = errors.New("invalid application status")
+	ErrNotPendingReview = errors.New("submission is not pending review")
 	ErrPasswordTooShort = fmt.Errorf("password should be minimum of length  %d", MinPassLen)
 )
 
@@ -130,12 +140,23 @@ type Store struct {
 		ActivateCampaign(context.Context, string) error
 		StopApplications(context.Context, string) error
 		UpdateCampaign(context.Context, string, UpdateCampaign) error
-		GetRecentCampaigns(ctx context.Context, limit int, cursorSeq, id string) ([]CampaignResp, int64, bool, error)
+		GetRecentCampaigns(ctx context.Context, limit int, cursor, id string) ([]CampaignResp, string, bool, error)
 		GetBrandCampaigns(ctx context.Context, brandID string, limit int, cursorSeq string) ([]CampaignResp, int64, bool, error)
 		GetUserCampaigns(ctx context.Context, brandID string, limit int, cursorSeq string) ([]CampaignResp, int64, bool, error)
 		GetCampaign(context.Context, string) (*CampaignResp, error)
 		DeleteCampaign(context.Context, string) error
 		GetMultipleCampaigns(ctx context.Context, campaignIDs []string) ([]CampaignResp, error)
+		GetCampaignsToStart(ctx context.Context, now time.Time) ([]string, error)
+		GetCampaignsToEnd(ctx context.Context, now time.Time) ([]string, error)
+		TransitionCampaign(ctx context.Context, t CampaignTransition) error
+		GetCampaignStatusHistory(ctx context.Context, id string) ([]CampaignStatusChange, error)
+		ReviewCampaign(ctx context.Context, id, adminID, decision, reason string) (int, error)
+		GetPendingCampaigns(ctx context.Context, offset, limit int) ([]CampaignResp, error)
+		SetCampaignEligibility(ctx context.Context, id string, rules *Eligibility) error
+		GetCampaignEligibility(ctx context.Context, id string) (*Eligibility, error)
+		SetPayoutRules(ctx context.Context, id string, rules []PayoutRule) error
+		GetPayoutRules(ctx context.Context, id string) ([]PayoutRule, error)
+		SearchCampaigns(ctx context.Context, creatorID string, search CampaignSearch, limit int, cursor string) (*CampaignSearchResult, error)
 	}
 	TicketInterface interface {
 		OpenTicket(context.Context, *Ticket) error
@@ -151,9 +172,12 @@ type Store struct {
 		FindSubmissionsByFilters(context.Context, Filter, int, int) ([]Submission, bool, error)
 		FindMySubmissions(ctx context.Context, time_ string, subids []string, limit, offset int) ([]Submission, error)
 		UpdateSubmission(context.Context, UpdateSubmission) error
+		ReviewSubmission(ctx context.Context, id, reviewerID string, status int, reason string) (bool, error)
+		GetPendingSubmissions(ctx context.Context, campaignID string, offset, limit int) ([]Submission, error)
 		ChangeViews(ctx context.Context, delta int, id string) error
 		GetSubmissionsForSync(ctx context.Context) ([]PollingSubmission, error)
 		UpdateSyncFrequency(ctx context.Context, id string, freq int) error
+		SetVideoStatus(ctx context.Context, id, status string, freq, skipTo int) error
 	}
 	LinkInterface interface {
 		AddLinks(context.Context, string, []Links) error
@@ -170,6 +194,18 @@ type Store struct {
 		GetAccount(context.Context, string) (*Account, error)
 		GetAccountID(context.Context, string) (string, error)
 		GetAllAccounts(context.Context, int, int) ([]Account, error)
+		GetStatement(context.Context, string, int, int) ([]LedgerLine, error)
+		ReconcileAccounts(context.Context) ([]BalanceMismatch, error)
+	}
+	WithdrawalInterface interface {
+		RequestWithdrawal(ctx context.Context, w *Withdrawal) error
+		GetWithdrawal(ctx context.Context, id string) (*Withdrawal, error)
+		GetWithdrawals(ctx context.Context, status string, offset, limit int) ([]Withdrawal, error)
+		GetHolderWithdrawals(ctx context.Context, holderID string, offset, limit int) ([]Withdrawal, error)
+		ApproveWithdrawal(ctx context.Context, id, adminID string) (*Withdrawal, error)
+		RejectWithdrawal(ctx context.Context, id, adminID, reason string) (*Withdrawal, error)
+		PayWithdrawal(ctx context.Context, id string) (*Withdrawal, error)
+		FailWithdrawal(ctx context.Context, id, reason string) (*Withdrawal, error)
 	}
 	ApplicationInterface interface {
 		GetApplicationByID(ctx context.Context, appl_id string) (ApplicationResponse, error)
@@ -180,9 +216,22 @@ type Store struct {
 		SetApplicationStatus(ctx context.Context, appl_id string, status int) error
 		DeleteApplication(ctx context.Context, appl_id string) error
 	}
+	FraudInterface interface {
+		FlagSubmission(ctx context.Context, flag *FraudFlag) (bool, error)
+		GetFraudFlag(ctx context.Context, id string) (*FraudFlag, error)
+		GetFraudFlags(ctx context.Context, status string, offset, limit int) ([]FraudFlag, error)
+		GetFraudDecisions(ctx context.Context, flagID string) ([]FraudDecision, error)
+		ReviewFraudFlag(ctx context.Context, id, adminID, decision, note string) (*FraudFlag, error)
+	}
+	MetricsInterface interface {
+		RecordMetrics(ctx context.Context, points []MetricPoint) error
+		GetMetricSeries(ctx context.Context, submissionID, bucket string, from, to time.Time) ([]MetricPoint, error)
+		GetRecentMetrics(ctx context.Context, submissionIDs []string, since time.Time) (map[string][]MetricPoint, error)
+		CompactMetrics(ctx context.Context, now time.Time) error
+	}
 	BatchInterface interface {
-		BatchUpdateCampaignBudgets(ctx context.Context, updates map[string]float64) error
-		BatchUpdateCreatorBalances(ctx context.Context, updates map[string]float64) error
+		BatchPayouts(ctx context.Context, payouts []*CampaignPayout) error
+		GetSubmissionPayouts(ctx context.Context, submissionID string) ([]Transaction, error)
 		BatchUpdateSubmissions(ctx context.Context, updates []*internals.BatchUpdate) error
 	}
 }
@@ -210,9 +259,18 @@ func NewStore(db *sql.DB) *Store {
 		TransactionInterface: &TransactionStore{
 			db: db,
 		},
+		WithdrawalInterface: &WithdrawalStore{
+			db: db,
+		},
 		ApplicationInterface: &ApplicationStore{
 			db: db,
 		},
+		FraudInterface: &FraudStore{
+			db: db,
+		},
+		MetricsInterface: &MetricsStore{
+			db: db,
+		},
 		BatchInterface: &BatchRepository{
 			db: db,
 		},
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSubmissionReview(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+
+	uid := generateCreator(ctx, uuid.New().String())
+	bid := uuid.New().String()
+	generateBrand(bid)
+	camp := SeedCampaign(ctx, bid, ActiveStatus, 1)
+	var ids []string
+	defer func() {
+		destroySubmissions(ctx, ids)
+		destroyCampaign(ctx, camp)
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+	// submitted a while ago so they are due for a sync once approved
+	query := `
+		INSERT INTO submissions (id, creator_id, campaign_id, url, status, video_platform,
+		video_status, last_synced_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now() - interval '1 hour')
+	`
+	for range 3 {
+		id := uuid.New().String()
+		_, err := MockSubStore.db.ExecContext(ctx, query, id, uid, camp[0], "mock_url",
+			SubmissionPending, "youtube", "available")
+		if err != nil {
+			log.Printf("error seeding submission: %v", err)
+			t.Fail()
+			return
+		}
+		ids = append(ids, id)
+	}
+	synced := func(id string) bool {
+		subs, err := MockSubStore.GetSubmissionsForSync(ctx)
+		if err != nil {
+			t.Fail()
+		}
+		return slices.ContainsFunc(subs, func(s PollingSubmission) bool { return s.Id == id })
+	}
+
+	t.Run("pending submissions are not synced", func(t *testing.T) {
+		if synced(ids[0]) {
+			t.Fail()
+		}
+		pending, err := MockSubStore.GetPendingSubmissions(ctx, camp[0], 0, 10)
+		if err != nil || len(pending) != 3 {
+			t.Fail()
+		}
+	})
+	t.Run("approved submissions are synced", func(t *testing.T) {
+		if _, err := MockSubStore.ReviewSubmission(ctx, ids[0], bid, SubmissionApproved, ""); err != nil {
+			log.Printf("error approving submission: %v", err)
+			t.Fail()
+		}
+		if !synced(ids[0]) {
+			t.Fail()
+		}
+		// a decision is final
+		_, err := MockSubStore.ReviewSubmission(ctx, ids[0], bid, SubmissionRejected, "too late")
+		if !errors.Is(err, ErrNotPendingReview) {
+			t.Fail()
+		}
+	})
+	t.Run("rejection needs a reason", func(t *testing.T) {
+		_, err := MockSubStore.ReviewSubmission(ctx, ids[1], bid, SubmissionRejected, "")
+		if !errors.Is(err, ErrInvalidArgs) {
+			t.Fail()
+		}
+		if _, err := MockSubStore.ReviewSubmission(ctx, ids[1], bid, SubmissionRejected, "off brief"); err != nil {
+			t.Fail()
+		}
+		sub, _ := MockSubStore.FindSubmissionById(ctx, ids[1])
+		if sub.Status != SubmissionRejected || sub.ReviewReason != "off brief" || synced(ids[1]) {
+			t.Fail()
+		}
+	})
+	t.Run("revision goes back to the review", func(t *testing.T) {
+		_, err := MockSubStore.ReviewSubmission(ctx, ids[2], bid, SubmissionRevision, "show the product")
+		if err != nil {
+			t.Fail()
+		}
+		url := "new_url"
+		video := &SubmissionVideo{
+			Platform:    "instagram",
+			VideoID:     "C1abcDEF",
+			Title:       "new video",
+			Views:       500,
+			LikeCount:   12,
+			VideoStatus: "unlisted",
+		}
+		err = MockSubStore.UpdateSubmission(ctx, UpdateSubmission{Id: ids[2], Url: &url, Video: video})
+		if err != nil {
+			t.Fail()
+		}
+		var status, accounted int
+		MockSubStore.db.QueryRowContext(ctx,
+			`SELECT status, accounted_views FROM submissions WHERE id = $1`, ids[2],
+		).Scan(&status, &accounted)
+		if status != SubmissionPending || accounted != video.Views {
+			t.Fail()
+		}
+		// the metadata of the old video is gone
+		sub, _ := MockSubStore.FindSubmissionById(ctx, ids[2])
+		if sub.VideoPlatform != video.Platform || sub.VideoID != video.VideoID ||
+			sub.VideoTitle != video.Title || sub.LikeCount != video.LikeCount ||
+			sub.VideoStatus != video.VideoStatus {
+			t.Fail()
+		}
+	})
+	t.Run("unknown submission", func(t *testing.T) {
+		_, err := MockSubStore.ReviewSubmission(ctx, uuid.New().String(), bid, SubmissionApproved, "")
+		if !errors.Is(err, ErrNotFound) {
+			t.Fail()
+		}
+	})
+}
@@ -30,6 +30,8 @@ type Submission struct {
 	VideoStatus   string  `json:"video_status"`
 	Earnings      float64 `json:"earnings"`
 	LastSyncedAt  string  `json:"last_synced_at"`
+	// why the brand rejected the submission or asked for a revision
+	ReviewReason string `json:"review_reason,omitempty"`
 	// -------- x ----------
 	SyncFrequency int    `json:"sync_frequency,omitempty"`
 	CreatedAt     string `json:"created_at"`
@@ -38,22 +40,39 @@ type Submission struct {
 // custom struct for the polling worker
 // reduces the overhead by 50% changing from Submission -> PollingSubmission
 type PollingSubmission struct {
-	Id            string `json:"id"`
-	CreatorId     string `json:"creator_id"`
-	CampaignId    string `json:"campaign_id"`
-	Url           string `json:"url"`
-	Views         int    `json:"views"`
-	LastSyncedAt  string `json:"last_synced_at"`
-	SyncFrequency int    `json:"sync_frequency,omitempty"`
-	CreatedAt     string `json:"created_at"`
+	Id             string `json:"id"`
+	CreatorId      string `json:"creator_id"`
+	CampaignId     string `json:"campaign_id"`
+	Url            string `json:"url"`
+	Views          int    `json:"views"`
+	AccountedViews int    `json:"accounted_views"` // views already paid out
+	VideoStatus    string `json:"video_status"`
+	PayoutsHeld    bool   `json:"payouts_held"` // under fraud review
+	PaidViews      int    `json:"paid_views"`   // views that earned, at most the campaign cap
+	// earned by the creator over all their submissions to the campaign
+	CreatorEarnings float64 `json:"creator_earnings"`
+	LastSyncedAt    string  `json:"last_synced_at"`
+	SyncFrequency   int     `json:"sync_frequency,omitempty"`
+	CreatedAt       string  `json:"created_at"`
 }
 
+// Update payload of a creator, the status and earnings are
+// set by the review and the payouts only
 type UpdateSubmission struct {
-	Id       string   `json:"id"`
-	Status   *int     `json:"status"`
-	Url      *string  `json:"url"`
-	Views    *int     `json:"views"`
-	Earnings *float64 `json:"earnings"`
+	Id    string           `json:"id"`
+	Url   *string          `json:"url"`
+	Video *SubmissionVideo `json:"-"` // the new video, fetched from its platform
+}
+
+// metadata of a video replacing the one of a submission
+type SubmissionVideo struct {
+	Platform     string
+	VideoID      string
+	Title        string
+	ThumbnailURL string
+	Views        int
+	LikeCount    int
+	VideoStatus  string
 }
 
 type Filter struct {
@@ -62,15 +81,17 @@ type Filter struct {
 	Time       *string `json:"time"`
 }
 
+// MakeSubmission stores a new submission. The views it had when submitted
+// are not earned, so they start out accounted.
 func (s *SubmissionStore) MakeSubmission(ctx context.Context, sub Submission) error {
 	query := `
 		INSERT INTO submissions
 		(
 			id, creator_id, campaign_id, url, status, video_title, video_platform,
 			platform_video_id, thumbnail_url, views, like_count, video_status, earnings,
-			sync_frequency
+			sync_frequency, accounted_views
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE(NULLIF($12, ''), 'available'), $13, $14, $10)
 	`
 	_, err := s.db.ExecContext(ctx, query,
 		sub.Id, sub.CreatorId, sub.CampaignId, sub.Url, sub.Status,
@@ -112,7 +133,7 @@ func (s *SubmissionStore) FindSubmissionById(ctx context.Context, id string) (*S
 	query := `
 		SELECT id, creator_id, campaign_id, url, status, video_title, video_platform,
 			platform_video_id, thumbnail_url, views, like_count, video_status, earnings,
-			sync_frequency, created_at, last_synced_at
+			sync_frequency, created_at, last_synced_at, COALESCE(review_reason, '')
 		FROM submissions
 		WHERE id = $1
 	`
@@ -129,6 +150,7 @@ func (s *SubmissionStore) FindSubmissionById(ctx context.Context, id string) (*S
 		&sub.SyncFrequency,
 		&sub.CreatedAt,
 		&sub.LastSyncedAt,
+		&sub.ReviewReason,
 	)
 	if err != nil {
 		// internal server error/ invalid query
@@ -300,50 +322,146 @@ func (s *SubmissionStore) FindMySubmissions(ctx context.Context, time_ string,
 	return output, nil
 }
 
-// This function updates a submission entity
+// This function updates a submission entity. A new video can only replace
+// the one under review and goes back to the review queue with its own
+// metadata, its views so far are not earned
 func (s *SubmissionStore) UpdateSubmission(ctx context.Context, payload UpdateSubmission) error {
-	var queryBuilder strings.Builder
-	queryBuilder.WriteString("UPDATE submissions SET ")
-	var args []any
-	var conditions []string
-	var i int = 1
-	if payload.Status != nil {
-		conditions = append(conditions, fmt.Sprintf("status = $%d", i))
-		args = append(args, *payload.Status)
-		i++
+	if payload.Url == nil {
+		return errors.New("invalid field to update")
 	}
-	if payload.Url != nil {
-		conditions = append(conditions, fmt.Sprintf("url = $%d", i))
-		args = append(args, *payload.Url)
-		i++
+	if payload.Video == nil {
+		return fmt.Errorf("%w: video metadata is required", ErrInvalidArgs)
 	}
-	if payload.Views != nil {
-		conditions = append(conditions, fmt.Sprintf("views = $%d", i))
-		args = append(args, *payload.Views)
-		i++
+	video := payload.Video
+	query := `
+		UPDATE submissions
+		SET url = $1, status = $2, video_platform = $3, platform_video_id = $4,
+			video_title = $5, thumbnail_url = $6, views = $7, accounted_views = $7,
+			like_count = $8, video_status = $9
+		WHERE id = $10 AND status IN ($2, $11)
+	`
+	res, err := s.db.ExecContext(ctx, query,
+		*payload.Url, SubmissionPending, video.Platform, video.VideoID,
+		video.Title, video.ThumbnailURL, video.Views,
+		video.LikeCount, video.VideoStatus,
+		payload.Id, SubmissionRevision,
+	)
+	if err != nil {
+		log.Printf("Error updating submission: %v\n", err.Error())
+		return err
 	}
-	if payload.Earnings != nil {
-		conditions = append(conditions, fmt.Sprintf("earnings = $%d", i))
-		args = append(args, *payload.Earnings)
-		i++
+	if count, _ := res.RowsAffected(); count == 0 {
+		return ErrNotPendingReview
 	}
-	// building query
-	queryBuilder.WriteString(strings.Join(conditions, ", "))
-	queryBuilder.WriteString(fmt.Sprintf(" WHERE id = $%d", i))
-	args = append(args, payload.Id)
-	query := queryBuilder.String()
 
-	if len(conditions) == 0 {
-		return errors.New("invalid field to update")
+	// successfully updated submission details
+	return nil
+}
+
+// ReviewSubmission records the decision of a brand on a pending submission.
+// An approved submission starts syncing and is paid the flat fee of a running
+// campaign, a rejected one never syncs and a revision waits for the creator
+// to submit a new video. A rejection or a revision needs a reason. It reports
+// whether the flat fee used up the budget and ended the campaign.
+func (s *SubmissionStore) ReviewSubmission(ctx context.Context, id, reviewerID string, status int, reason string) (bool, error) {
+	switch status {
+	case SubmissionApproved:
+	case SubmissionRejected, SubmissionRevision:
+		if strings.TrimSpace(reason) == "" {
+			return false, fmt.Errorf("%w: reason is required", ErrInvalidArgs)
+		}
+	default:
+		return false, fmt.Errorf("%w: review status", ErrInvalidArgs)
 	}
-	_, err := s.db.ExecContext(ctx, query, args...)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Printf("Error updating brand: %v\n", err.Error())
-		return err
+		log.Printf("error beginning transaction: %v\n", err.Error())
+		return false, err
 	}
+	defer tx.Rollback()
 
-	// successfully updated submission details
-	return nil
+	var creatorID, campaignID string
+	err = tx.QueryRowContext(ctx, `SELECT creator_id, campaign_id FROM submissions WHERE id = $1`, id).Scan(
+		&creatorID, &campaignID,
+	)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("error fetching submission(%s): %v\n", id, err.Error())
+		return false, err
+	}
+	// the campaign is locked before the submission, like the payouts do
+	brandID, budget, campaignStatus, err := lockCampaign(ctx, tx, campaignID)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE submissions
+		SET status = $1, review_reason = NULLIF($2, ''), reviewed_by = $3, reviewed_at = now()
+		WHERE id = $4 AND status = $5
+	`
+	res, err := tx.ExecContext(ctx, query, status, reason, reviewerID, id, SubmissionPending)
+	if err != nil {
+		log.Printf("error reviewing submission(%s): %v\n", id, err.Error())
+		return false, err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return false, ErrNotPendingReview
+	}
+	var exhausted bool
+	if status == SubmissionApproved && campaignStatus == ActiveStatus {
+		exhausted, err = payFlatFee(ctx, tx, id, creatorID, campaignID, brandID, budget)
+		if err != nil {
+			log.Printf("error paying the flat fee of submission(%s): %v\n", id, err)
+			return false, err
+		}
+	}
+	return exhausted, tx.Commit()
+}
+
+// lists the submissions of a campaign waiting for a review, the oldest first
+func (s *SubmissionStore) GetPendingSubmissions(ctx context.Context, campaignID string, offset, limit int) ([]Submission, error) {
+	query := `
+		SELECT id, creator_id, campaign_id, url, status, video_title, video_platform,
+			platform_video_id, thumbnail_url, views, like_count, video_status, earnings,
+			sync_frequency, created_at, last_synced_at
+		FROM submissions
+		WHERE campaign_id = $1 AND status = $2
+		ORDER BY created_at ASC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := s.db.QueryContext(ctx, query, campaignID, SubmissionPending, limit, offset)
+	if err != nil {
+		log.Printf("error fetching pending submissions: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []Submission
+	for rows.Next() {
+		var sub Submission
+		err := rows.Scan(
+			&sub.Id,
+			&sub.CreatorId,
+			&sub.CampaignId,
+			&sub.Url,
+			&sub.Status,
+			&sub.VideoTitle, &sub.VideoPlatform, &sub.VideoID,
+			&sub.ThumbnailURL, &sub.Views, &sub.LikeCount, &sub.VideoStatus,
+			&sub.Earnings,
+			&sub.SyncFrequency,
+			&sub.CreatedAt,
+			&sub.LastSyncedAt,
+		)
+		if err != nil {
+			log.Printf("error scanning pending submission: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, sub)
+	}
+	return output, rows.Err()
 }
 
 func (s *SubmissionStore) ChangeViews(ctx context.Context, delta int, id string) error {
@@ -373,7 +491,8 @@ func (s *SubmissionStore) ChangeViews(ctx context.Context, delta int, id string)
 }
 
 func (s *SubmissionStore) GetSubmissionsForSync(ctx context.Context) ([]PollingSubmission, error) {
-	// filter out the active submissions
+	// filter out the approved submissions of active campaigns, submissions
+	// confirmed as fraud are not tracked anymore
 	// select the submissions which have there sync frequency
 	// less than the interval passed from last_sync
 	query := `
@@ -381,19 +500,32 @@ func (s *SubmissionStore) GetSubmissionsForSync(ctx context.Context) ([]PollingS
             s.id, 
             s.url, 
             s.views,
+            s.accounted_views,
+            s.video_status,
+            s.payouts_held,
+            s.paid_views,
+            (
+                SELECT COALESCE(SUM(o.earnings), 0) FROM submissions o
+                WHERE o.campaign_id = s.campaign_id AND o.creator_id = s.creator_id
+            ) AS creator_earnings,
             s.sync_frequency,
             s.last_synced_at,
             s.creator_id,
             s.campaign_id,
 			s.created_at
         FROM submissions s
-        JOIN status st ON s.status = st.id
-        WHERE st.name = 'active'
+        JOIN campaigns c ON c.id = s.campaign_id
+        WHERE s.status = $2
+		AND c.status = $1
 		AND s.last_synced_at <= NOW() - (s.sync_frequency::text || ' minutes')::INTERVAL
+		AND NOT EXISTS (
+			SELECT 1 FROM fraud_flags f
+			WHERE f.submission_id = s.id AND f.status = $3
+		)
         ORDER BY s.last_synced_at ASC
         LIMIT 500;
     `
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query, ActiveStatus, SubmissionApproved, FraudConfirmed)
 	if err != nil {
 		log.Printf("error while fetching submissions to sync: %s\n", err.Error())
 		return nil, ErrServer
@@ -409,6 +541,11 @@ func (s *SubmissionStore) GetSubmissionsForSync(ctx context.Context) ([]PollingS
 			&sub.Id,
 			&sub.Url,
 			&sub.Views,
+			&sub.AccountedViews,
+			&sub.VideoStatus,
+			&sub.PayoutsHeld,
+			&sub.PaidViews,
+			&sub.CreatorEarnings,
 			&sub.SyncFrequency,
 			&sub.LastSyncedAt,
 			&sub.CreatorId,
@@ -451,3 +588,36 @@ func (s *SubmissionStore) UpdateSyncFrequency(ctx context.Context, id string, fr
 	}
 	return nil
 }
+
+// SetVideoStatus records the availability of a submission's video along with
+// its new sync frequency and marks it synced. A positive skipTo moves the
+// accounted views up to it, so the views made while the video was paused are
+// never paid.
+func (s *SubmissionStore) SetVideoStatus(ctx context.Context, id, status string, freq, skipTo int) error {
+	query := `
+		UPDATE submissions
+		SET video_status = $2,
+			video_status_changed_at = CASE WHEN video_status = $2
+				THEN video_status_changed_at ELSE now() END,
+			sync_frequency = $3,
+			views = GREATEST(views, $4),
+			accounted_views = GREATEST(accounted_views, $4),
+			last_synced_at = now()
+		WHERE id = $1
+	`
+	res, err := s.db.ExecContext(ctx, query, id, status, freq, skipTo)
+	if err != nil {
+		log.Printf("error updating video status for id = %s: %v\n", id, err)
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("could not determine rows affected: %v\n", err)
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if count == 0 {
+		log.Printf("no submission found with id = %q\n", id)
+		return sql.ErrNoRows
+	}
+	return nil
+}
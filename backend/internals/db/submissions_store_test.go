@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"testing"
@@ -217,7 +218,7 @@ func TestUpdateSubmissions(t *testing.T) {
 	camp := SeedCampaign(ctx, bid, ActiveStatus, 1)
 
 	// mock submissions
-	ids := SeedSubmissions(ctx, camp[0], 1, DraftStatus)
+	ids := SeedSubmissions(ctx, camp[0], 1, SubmissionRevision)
 	defer func() {
 		destroySubmissions(ctx, ids)
 		destroyCampaign(ctx, camp)
@@ -225,27 +226,36 @@ func TestUpdateSubmissions(t *testing.T) {
 		destroyCreator(ctx, creator)
 		cancel()
 	}()
-	new_stat := 3
 	new_url := "new_url.com"
-	new_views := 1000
-	new_earnings := 1000.0
+	new_video := &SubmissionVideo{
+		Platform:    "youtube",
+		VideoID:     "new_video",
+		Views:       1000,
+		VideoStatus: "available",
+	}
 	t.Run("updating with valid params", func(t *testing.T) {
 		payload := UpdateSubmission{
-			Id:       ids[0],
-			Status:   &new_stat,
-			Url:      &new_url,
-			Views:    &new_views,
-			Earnings: &new_earnings,
+			Id:    ids[0],
+			Url:   &new_url,
+			Video: new_video,
 		}
 		err := MockSubStore.UpdateSubmission(ctx, payload)
 		if err != nil {
 			t.Fail()
 		}
+		// the new video goes back to the review
 		updatedSub, _ := MockSubStore.FindSubmissionById(ctx, ids[0])
-		if updatedSub.Status != new_stat ||
+		if updatedSub.Status != SubmissionPending ||
 			updatedSub.Url != new_url ||
-			updatedSub.Views != new_views ||
-			updatedSub.Earnings != new_earnings {
+			updatedSub.Views != new_video.Views ||
+			updatedSub.VideoID != new_video.VideoID {
+			t.Fail()
+		}
+	})
+	t.Run("approved video is kept", func(t *testing.T) {
+		MockSubStore.ReviewSubmission(ctx, ids[0], "reviewer", SubmissionApproved, "")
+		payload := UpdateSubmission{Id: ids[0], Url: &new_url, Video: new_video}
+		if err := MockSubStore.UpdateSubmission(ctx, payload); !errors.Is(err, ErrNotPendingReview) {
 			t.Fail()
 		}
 	})
@@ -378,7 +388,7 @@ func TestGetSubmissionsForSync(t *testing.T) {
 	t.Run("Time before sync_frequency", func(t *testing.T) {
 		// submissions created
 		SubsCount := 10
-		submissionIds := SeedSubmissions(ctx, campaignIds[0], SubsCount, ActiveStatus)
+		submissionIds := SeedSubmissions(ctx, campaignIds[0], SubsCount, SubmissionApproved)
 		polling_subs, err := MockSubStore.GetSubmissionsForSync(ctx)
 		if err != nil {
 			log.Printf("could not get submissions for polling\n")
@@ -402,7 +412,7 @@ func TestGetSubmissionsForSync(t *testing.T) {
 			CreatorId:  uid,
 			CampaignId: campaignIds[0],
 			Url:        "example.com",
-			Status:     ActiveStatus,
+			Status:     SubmissionApproved,
 			Views:      10000, // dummy values
 			Earnings:   400.0, // dummy values
 			// shift the created time back by 10 min
@@ -448,3 +458,61 @@ func TestGetSubmissionsForSync(t *testing.T) {
 		destroySubmissions(ctx, []string{sub.Id})
 	})
 }
+
+func TestSetVideoStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	creator := generateCreator(ctx, "0001")
+	bid := uuid.New().String()
+	generateBrand(bid)
+	camp := SeedCampaign(ctx, bid, ActiveStatus, 1)
+	sub := Submission{
+		Id:         "0001",
+		CreatorId:  creator,
+		CampaignId: camp[0],
+		Url:        "example.com",
+		Status:     ActiveStatus,
+		Views:      1000,
+	}
+	defer func() {
+		MockSubStore.DeleteSubmission(ctx, sub.Id)
+		destroyCampaign(ctx, camp)
+		destroyBrand(bid)
+		destroyCreator(ctx, creator)
+		cancel()
+	}()
+	MockSubStore.MakeSubmission(ctx, sub)
+
+	t.Run("defaults to available", func(t *testing.T) {
+		stored, err := MockSubStore.FindSubmissionById(ctx, sub.Id)
+		if err != nil || stored.VideoStatus != "available" {
+			t.Fail()
+		}
+	})
+	t.Run("paused", func(t *testing.T) {
+		if err := MockSubStore.SetVideoStatus(ctx, sub.Id, "private", 1440, 0); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := MockSubStore.FindSubmissionById(ctx, sub.Id)
+		if stored.VideoStatus != "private" || stored.SyncFrequency != 1440 || stored.Views != 1000 {
+			t.Fail()
+		}
+	})
+	t.Run("resumed skips the paused views", func(t *testing.T) {
+		if err := MockSubStore.SetVideoStatus(ctx, sub.Id, "available", 5, 1500); err != nil {
+			t.Fatal(err)
+		}
+		var views, accounted int
+		MockSubStore.db.QueryRowContext(ctx,
+			`SELECT views, accounted_views FROM submissions WHERE id = $1`, sub.Id,
+		).Scan(&views, &accounted)
+		if views != 1500 || accounted != 1500 {
+			log.Printf("views: %d, accounted: %d\n", views, accounted)
+			t.Fail()
+		}
+	})
+	t.Run("invalid submission id", func(t *testing.T) {
+		if err := MockSubStore.SetVideoStatus(ctx, "invalid-id", "deleted", 1440, 0); err == nil {
+			t.Fail()
+		}
+	})
+}
@@ -69,137 +69,89 @@ func DecodeUUID(token string) [16]byte {
 	return byteArray
 }
 
-// This function executes a transaction and logs the transaction into transactions table
-// type is payout(case sensitive)
-func (txs *TransactionStore) Payout(ctx context.Context, ts *Transaction) error {
-	tx, err := txs.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-	var debit_balance float64
-	creditQuery := `UPDATE accounts SET amount = amount + $1 WHERE id = $2 AND active = $3`
-	debitQuery := `
-		UPDATE accounts SET amount = amount - $1 WHERE id = $2 AND active = $3
-		RETURNING amount
-	`
+// logFailed records a failed transaction outside the rolled back db transaction
+// so the attempt stays visible in the transactions table
+func (txs *TransactionStore) logFailed(ctx context.Context, ts *Transaction) {
 	logQuery := `
 		INSERT INTO transactions (id, from_id, to_id, amount, status, type)
 	    VALUES ($1, $2, $3, $4, $5, $6)
 		ON CONFLICT (id) DO NOTHING
 	`
-	FromAcc := DecodeUUID(ts.FromId)
-	ToAcc := DecodeUUID(ts.ToId)
-	if DecideLock(FromAcc, ToAcc) {
-		// credit
-		if _, err := tx.ExecContext(ctx, creditQuery, ts.Amount, ts.ToId, true); err != nil {
-			ts.Status = FailedTxStatus
-			_, _ = tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type)
-			return fmt.Errorf("credit failed: %w", err)
-		}
-
-		// debit
-		if err := tx.QueryRowContext(ctx, debitQuery, ts.Amount, ts.FromId, true).Scan(&debit_balance); err != nil {
-			ts.Status = FailedTxStatus
-			_, _ = tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type)
-			return fmt.Errorf("debit failed: %w", err)
-		}
-	} else {
-		// debit
-		if err := tx.QueryRowContext(ctx, debitQuery, ts.Amount, ts.FromId, true).Scan(&debit_balance); err != nil {
-			ts.Status = FailedTxStatus
-			_, _ = tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type)
-			return fmt.Errorf("debit failed: %w", err)
-		}
-		// credit
-		if _, err := tx.ExecContext(ctx, creditQuery, ts.Amount, ts.ToId, true); err != nil {
-			ts.Status = FailedTxStatus
-			_, _ = tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type)
-			return fmt.Errorf("credit failed: %w", err)
-		}
-	}
-
-	// success
-	ts.Status = SuccessTxStatus
-	if _, err := tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type); err != nil {
-		return fmt.Errorf("log transaction failed: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit failed: %w", err)
+	ts.Status = FailedTxStatus
+	if _, err := txs.db.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type); err != nil {
+		log.Printf("error logging failed transaction %s: %v\n", ts.Id, err.Error())
 	}
-	return nil
 }
 
-// (only allow brands to deposit)
-// deposit function should be given the same from_id and to_id
-// type is deposit (case sensitive)
-func (txs *TransactionStore) Deposit(ctx context.Context, ts *Transaction) error {
+// settle logs the transaction and posts its journal entry atomically
+func (txs *TransactionStore) settle(ctx context.Context, ts *Transaction, entry *JournalEntry) error {
 	tx, err := txs.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	creditQuery := `UPDATE accounts SET amount = amount + $1 WHERE id = $2 AND active = $3`
+	// a failed attempt can be retried with the same id, a settled one cannot
 	logQuery := `
 		INSERT INTO transactions (id, from_id, to_id, amount, status, type)
 	    VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (id) DO NOTHING
+		ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, created_at = now()
+		WHERE transactions.status = $7
 	`
-
-	// credit
-	if _, err := tx.ExecContext(ctx, creditQuery, ts.Amount, ts.ToId, true); err != nil {
-		ts.Status = FailedTxStatus
-		_, _ = tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type)
-		return fmt.Errorf("credit failed: %w", err)
-	}
-	// success
-	log.Printf("credit done\n")
-	ts.Status = SuccessTxStatus
-	if _, err := tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type); err != nil {
+	// the transactions row goes first so a replayed id is refused before
+	// anything is posted, the journal entry carries the same id
+	res, err := tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, SuccessTxStatus, ts.Type, FailedTxStatus)
+	if err != nil {
 		return fmt.Errorf("log transaction failed: %w", err)
 	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return ErrDuplicateTx
+	}
+	if err := postEntry(ctx, tx, entry); err != nil {
+		return err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit failed: %w", err)
 	}
+	ts.Status = SuccessTxStatus
+	return nil
+}
+
+// This function executes a transaction and logs the transaction into transactions table
+// type is payout(case sensitive)
+// the brand account is debited and the creator account credited in the ledger
+func (txs *TransactionStore) Payout(ctx context.Context, ts *Transaction) error {
+	entry := transfer(uuid.New().String(), ts.Id, EntryPayout, "", ts.FromId, ts.ToId, ts.Amount)
+	if err := txs.settle(ctx, ts, entry); err != nil {
+		txs.logFailed(ctx, ts)
+		return fmt.Errorf("payout failed: %w", err)
+	}
+	return nil
+}
+
+// (only allow brands to deposit)
+// deposit function should be given the same from_id and to_id
+// type is deposit (case sensitive)
+// the money comes in from the external system account
+func (txs *TransactionStore) Deposit(ctx context.Context, ts *Transaction) error {
+	entry := transfer(uuid.New().String(), ts.Id, EntryDeposit, "", ExternalAccountID, ts.ToId, ts.Amount)
+	if err := txs.settle(ctx, ts, entry); err != nil {
+		txs.logFailed(ctx, ts)
+		return fmt.Errorf("credit failed: %w", err)
+	}
 	return nil
 }
 
 // withdraw function should be given the same from_id and to_id
 // type is withdraw (case sensitive)
+// the money leaves to the external system account
 func (txs *TransactionStore) Withdraw(ctx context.Context, ts *Transaction) error {
-	tx, err := txs.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-	var debit_balance float64
-	debitQuery := `
-		UPDATE accounts SET amount = amount - $1 WHERE id = $2 AND active = $3
-		RETURNING amount
-	`
-	logQuery := `
-		INSERT INTO transactions (id, from_id, to_id, amount, status, type)
-	    VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (id) DO NOTHING
-	`
-	// debit
-	if err := tx.QueryRowContext(ctx, debitQuery, ts.Amount, ts.FromId, true).Scan(&debit_balance); err != nil {
-		ts.Status = FailedTxStatus
-		_, _ = tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type)
+	entry := transfer(uuid.New().String(), ts.Id, EntryWithdraw, "", ts.FromId, ExternalAccountID, ts.Amount)
+	if err := txs.settle(ctx, ts, entry); err != nil {
+		txs.logFailed(ctx, ts)
 		return fmt.Errorf("debit failed: %w", err)
 	}
-	// success
-	ts.Status = SuccessTxStatus
-	if _, err := tx.ExecContext(ctx, logQuery, ts.Id, ts.FromId, ts.ToId, ts.Amount, ts.Status, ts.Type); err != nil {
-		return fmt.Errorf("log transaction failed: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit failed: %w", err)
-	}
 	return nil
 }
 
@@ -239,15 +191,23 @@ func (ts *TransactionStore) OpenAccount(ctx context.Context, acc *Account) error
 	// }
 	// ]
 
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// the account opens empty, the initial amount is booked through the ledger
 	query := `
 		INSERT INTO accounts (id, holder_id, holder_type, amount, currency)
 		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING
 	`
-	_, err := ts.db.ExecContext(ctx, query,
+	res, err := tx.ExecContext(ctx, query,
 		acc.Id,
 		acc.HolderId,
 		acc.Type,
-		acc.Amount,
+		0,
 		acc.Currency,
 	)
 	if err != nil {
@@ -256,7 +216,22 @@ func (ts *TransactionStore) OpenAccount(ctx context.Context, acc *Account) error
 		log.Println(err.Error())
 		return err
 	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		// the same account was opened already
+		return ErrDuplicateTx
+	}
+	if acc.Amount > 0 {
+		entry := transfer(uuid.New().String(), "", EntryOpening, "opening balance",
+			ExternalAccountID, acc.Id, acc.Amount)
+		if err := postEntry(ctx, tx, entry); err != nil {
+			log.Printf("error posting opening balance for: %s: %v\n", acc.HolderId, err.Error())
+			return err
+		}
+	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
 	// successfully created account
 	return nil
 }
@@ -275,15 +250,32 @@ func (ts *TransactionStore) DisableAccount(ctx context.Context, id string) error
 	return nil
 }
 
+// DeleteAccount removes an account that never moved money. An account with
+// postings or transactions keeps its history and can only be disabled
 func (ts *TransactionStore) DeleteAccount(ctx context.Context, id string) error {
 	query := `
-		DELETE FROM accounts WHERE id = $1
+		DELETE FROM accounts a
+		WHERE a.id = $1
+		AND NOT EXISTS (SELECT 1 FROM ledger_postings p WHERE p.account_id = a.id)
+		AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.from_id = a.id OR t.to_id = a.id)
+		AND NOT EXISTS (SELECT 1 FROM withdrawal_requests w WHERE w.account_id = a.id)
 	`
-	_, err := ts.db.ExecContext(ctx, query, id)
+	res, err := ts.db.ExecContext(ctx, query, id)
 	if err != nil {
 		log.Printf("error deleting account: %s", id)
 		return err
 	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		var exists bool
+		err := ts.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM accounts WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrAccountHistory
+	}
 
 	return nil
 }
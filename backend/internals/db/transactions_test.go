@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"log"
 	"math"
 	"sync"
@@ -31,11 +32,58 @@ func generateAccounts(ctx context.Context, holder_id, type_ string) *Account {
 func destroyAccounts(ctx context.Context, args ...string) {
 	log.Printf("destroying account\n")
 	for _, v := range args {
+		destroyLedger(ctx, v)
 		MockTsStore.DeleteAccount(ctx, v)
 	}
 }
 
+// removes every journal entry touching the account (both legs) and takes
+// the postings back out of the other accounts so the system accounts still
+// reconcile once the test data is gone
+func destroyLedger(ctx context.Context, accID string) {
+	entries := `
+		SELECT DISTINCT entry_id FROM ledger_postings WHERE account_id = $1
+	`
+	rows, err := MockTsStore.db.QueryContext(ctx, entries, accID)
+	if err != nil {
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	restoreQuery := `
+		UPDATE accounts a SET amount = a.amount - p.net
+		FROM (
+			SELECT account_id,
+			SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END) AS net
+			FROM ledger_postings
+			WHERE entry_id = $1
+			GROUP BY account_id
+		) p
+		WHERE a.id = p.account_id AND a.id <> $2
+	`
+	for _, id := range ids {
+		tx, err := MockTsStore.db.BeginTx(ctx, nil)
+		if err != nil {
+			return
+		}
+		if _, err := tx.ExecContext(ctx, restoreQuery, id, accID); err != nil {
+			log.Printf("error restoring balances of entry %s: %v", id, err)
+		}
+		tx.ExecContext(ctx, `DELETE FROM ledger_postings WHERE entry_id = $1`, id)
+		tx.ExecContext(ctx, `DELETE FROM journal_entries WHERE id = $1`, id)
+		tx.Commit()
+	}
+}
+
 func destroyAllTransactions() {
+	MockTsStore.db.Exec(`DELETE FROM submission_payouts`)
+	MockTsStore.db.Exec(`DELETE FROM withdrawal_requests`)
 	query := `DELETE FROM transactions`
 	MockTsStore.db.Exec(query)
 }
@@ -403,6 +451,61 @@ func TestDisableAccount(t *testing.T) {
 	})
 }
 
+func TestDeleteAccount(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+
+	uid := uuid.New().String()
+	generateCreator(ctx, uid)
+	bid := uuid.New().String()
+	generateBrand(bid)
+
+	user_acc := generateAccounts(ctx, uid, "user")
+	brand_acc := generateAccounts(ctx, bid, "brand")
+	defer func() {
+		destroyAllTransactions()
+		destroyAccounts(ctx, user_acc.Id, brand_acc.Id)
+		destroyBrand(bid)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+	t.Run("account with a transfer is kept", func(t *testing.T) {
+		invoice := Transaction{
+			Id:     uuid.New().String(),
+			FromId: brand_acc.Id,
+			ToId:   user_acc.Id,
+			Amount: 100.0,
+			Type:   "payout",
+		}
+		if err := MockTsStore.Payout(ctx, &invoice); err != nil {
+			t.Fail()
+			return
+		}
+		if err := MockTsStore.DeleteAccount(ctx, user_acc.Id); !errors.Is(err, ErrAccountHistory) {
+			t.Fail()
+		}
+		if _, err := MockTsStore.GetAccount(ctx, user_acc.Id); err != nil {
+			t.Fail()
+		}
+		// it can still be closed
+		if err := MockTsStore.DisableAccount(ctx, user_acc.Id); err != nil {
+			t.Fail()
+		}
+	})
+	t.Run("unused account is deleted", func(t *testing.T) {
+		acc := Account{Id: uuid.New().String(), HolderId: uuid.New().String(), Type: "user", Currency: "inr"}
+		if err := MockTsStore.OpenAccount(ctx, &acc); err != nil {
+			t.Fail()
+			return
+		}
+		if err := MockTsStore.DeleteAccount(ctx, acc.Id); err != nil {
+			t.Fail()
+		}
+		if err := MockTsStore.DeleteAccount(ctx, acc.Id); !errors.Is(err, ErrNotFound) {
+			t.Fail()
+		}
+	})
+}
+
 func TestGetAllAccounts(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 
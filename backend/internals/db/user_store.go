@@ -26,8 +26,9 @@ type LinkStore struct {
 
 // Links model
 type Links struct {
-	Platform string `json:"platform"`
-	Url      string `json:"url"`
+	Platform    string `json:"platform"`
+	Url         string `json:"url"`
+	Subscribers int64  `json:"subscribers"`
 }
 
 // The User Model
@@ -41,6 +42,7 @@ type User struct {
 	Amount        float64 `json:"amount"`
 	Currency      string  `json:"currency"`
 	Age           int     `json:"age"`
+	Country       string  `json:"country"` // where the creator's audience is
 	Role          string  `json:"role"`
 	PlatformLinks []Links `json:"links"`
 	IsVerified    bool    `json:"is_verified"`
@@ -54,6 +56,7 @@ type UpdatePayload struct {
 	LastName  *string `json:"last_name"`
 	Email     *string `json:"email"`
 	Gender    *string `json:"gender"`
+	Country   *string `json:"country" binding:"omitempty,iso3166_1_alpha2"`
 }
 
 type UserStat struct {
@@ -143,7 +146,7 @@ func (u *UserStore) GetUserById(ctx context.Context, id string) (*User, error) {
 	// join the roles table to get the name of the role
 	query := `
 		SELECT u.id, u.first_name, u.last_name, u.email, u.password, u.gender, 
-		COALESCE(a.amount, 0), COALESCE(a.currency, ''), u.age, r.name, u.is_verified, u.created_at,
+		COALESCE(a.amount, 0), COALESCE(a.currency, ''), u.age, COALESCE(u.country, ''), r.name, u.is_verified, u.created_at,
 		EXISTS(SELECT 1 FROM accounts acc WHERE acc.holder_id = u.id) AS has_account
 		FROM users u
 		JOIN roles r ON r.id = u.role
@@ -162,6 +165,7 @@ func (u *UserStore) GetUserById(ctx context.Context, id string) (*User, error) {
 		&user.Amount,
 		&user.Currency,
 		&user.Age,
+		&user.Country,
 		&user.Role,
 		&user.IsVerified,
 		&user.CreatedAt,
@@ -184,7 +188,7 @@ func (u *UserStore) GetUserByEmail(ctx context.Context, email string) (*User, er
 	// filter by email and join the roles table to get the role name
 	query := `
 		SELECT u.id, u.first_name, u.last_name, u.email,
-		u.password, u.gender, COALESCE(a.amount, 0), COALESCE(a.currency, ''), u.age, r.name, u.is_verified, u.created_at
+		u.password, u.gender, COALESCE(a.amount, 0), COALESCE(a.currency, ''), u.age, COALESCE(u.country, ''), r.name, u.is_verified, u.created_at
 		FROM users u
 		JOIN roles r ON r.id = u.role
 		LEFT JOIN accounts a ON a.holder_id = u.id
@@ -202,6 +206,7 @@ func (u *UserStore) GetUserByEmail(ctx context.Context, email string) (*User, er
 		&user.Amount,
 		&user.Currency,
 		&user.Age,
+		&user.Country,
 		&user.Role,
 		&user.IsVerified,
 		&user.CreatedAt,
@@ -222,7 +227,7 @@ func (u *UserStore) GetUserByEmailForAuth(ctx context.Context, email string) (*U
 	// filter by email and join the roles table to get the role name
 	query := `
 		SELECT u.id, u.first_name, u.last_name, u.email,
-		u.password, u.gender, u.age, r.name, u.is_verified, u.created_at,
+		u.password, u.gender, u.age, COALESCE(u.country, ''), r.name, u.is_verified, u.created_at,
 		EXISTS(SELECT 1 FROM accounts acc WHERE acc.holder_id = u.id) AS has_account
 		FROM users u
 		JOIN roles r ON r.id = u.role
@@ -238,6 +243,7 @@ func (u *UserStore) GetUserByEmailForAuth(ctx context.Context, email string) (*U
 		&user.Password.hashed_pass,
 		&user.Gender,
 		&user.Age,
+		&user.Country,
 		&user.Role,
 		&user.IsVerified,
 		&user.CreatedAt,
@@ -257,8 +263,8 @@ func (u *UserStore) GetUserByEmailForAuth(ctx context.Context, email string) (*U
 // Function to create a new user
 func (u *UserStore) CreateUserWithoutVerification(ctx context.Context, user *User) error {
 	query := `
-		INSERT INTO users (id, first_name, last_name, email, password, gender, age, role, is_verified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO users (id, first_name, last_name, email, password, gender, age, role, is_verified, country)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
 	`
 	_, err := u.db.ExecContext(ctx, query,
 		user.Id,
@@ -270,6 +276,7 @@ func (u *UserStore) CreateUserWithoutVerification(ctx context.Context, user *Use
 		user.Age,
 		user.Role,
 		true,
+		user.Country,
 	)
 	if err != nil {
 		// For Debugging
@@ -293,8 +300,8 @@ func (u *UserStore) CreateUserWithoutVerification(ctx context.Context, user *Use
 // Function to create a new user
 func (u *UserStore) CreateUser(ctx context.Context, user *User) error {
 	query := `
-		INSERT INTO users (id, first_name, last_name, email, password, gender, age, role)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (id, first_name, last_name, email, password, gender, age, role, country)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
 	`
 	_, err := u.db.ExecContext(ctx, query,
 		user.Id,
@@ -305,6 +312,7 @@ func (u *UserStore) CreateUser(ctx context.Context, user *User) error {
 		user.Gender,
 		user.Age,
 		user.Role,
+		user.Country,
 	)
 	if err != nil {
 		// For Debugging
@@ -413,6 +421,11 @@ func (u *UserStore) UpdateUser(ctx context.Context, id string, payload UpdatePay
 		args = append(args, *payload.Gender)
 		i++
 	}
+	if payload.Country != nil {
+		setClauses = append(setClauses, fmt.Sprintf("country = $%d", i))
+		args = append(args, strings.ToUpper(*payload.Country))
+		i++
+	}
 
 	if len(setClauses) == 0 {
 		return errors.New("no fields to update")
@@ -477,31 +490,29 @@ func (u *UserStore) SetUserProfilePicture(ctx context.Context, id, fileKey strin
 
 func (u *UserStore) GetStats(ctx context.Context, user_id string) (*UserStat, error) {
 	var output UserStat
+	// earnings are the successful payouts into the creator's account
 	query := `
-			SELECT
+		SELECT
 			u.id AS user_id,
 
-			COUNT(DISTINCT a.id) AS total_applications,
-			COUNT(DISTINCT s.id) AS total_submissions,
+			(SELECT COUNT(*) FROM applications a WHERE a.creator_id = u.id) AS total_applications,
+			(SELECT COUNT(*) FROM submissions s WHERE s.creator_id = u.id) AS total_submissions,
 
-			COUNT(DISTINCT t.id) AS total_transactions,
-			COALESCE(SUM(t.amount), 0) AS total_earnings
+			COUNT(t.id) AS total_transactions,
+			COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'payout' AND t.status = $2), 0) AS total_earnings
 
 		FROM users u
 
-		LEFT JOIN applications a
-			ON a.creator_id = u.id
-
-		LEFT JOIN submissions s
-			ON s.creator_id = u.id
+		LEFT JOIN accounts acc
+			ON acc.holder_id = u.id AND acc.holder_type = 'user'
 
 		LEFT JOIN transactions t
-			ON t.to_id = u.id
+			ON t.to_id = acc.id
 
+		WHERE u.id = $1
 		GROUP BY u.id
-		HAVING u.id = $1
 	`
-	err := u.db.QueryRowContext(ctx, query, user_id).Scan(
+	err := u.db.QueryRowContext(ctx, query, user_id, SuccessTxStatus).Scan(
 		&output.UserID,
 		&output.TotalApplications,
 		&output.TotalSubmissions,
@@ -519,8 +530,8 @@ func (u *UserStore) GetStats(ctx context.Context, user_id string) (*UserStat, er
 
 func (l *LinkStore) AddLinks(ctx context.Context, id string, links []Links) error {
 	query := `
-		INSERT INTO platform_links (userid, platform, url)
-		VALUES ($1, $2, $3)
+		INSERT INTO platform_links (userid, platform, url, subscribers)
+		VALUES ($1, $2, $3, $4)
 	`
 	tx, err := l.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -529,7 +540,7 @@ func (l *LinkStore) AddLinks(ctx context.Context, id string, links []Links) erro
 		return err
 	}
 	for _, v := range links {
-		_, err := tx.ExecContext(ctx, query, id, v.Platform, v.Url)
+		_, err := tx.ExecContext(ctx, query, id, v.Platform, v.Url, v.Subscribers)
 		if err != nil {
 			// rollback the inserted links
 			tx.Rollback()
@@ -564,7 +575,7 @@ func (l *LinkStore) DeleteLinks(ctx context.Context, id string, platform string)
 func (l *LinkStore) GetLinks(ctx context.Context, id string) []Links {
 	var output []Links
 	query := `
-		SELECT platform, url
+		SELECT platform, url, subscribers
 		FROM platform_links
 		WHERE userid = $1
 	`
@@ -580,6 +591,7 @@ func (l *LinkStore) GetLinks(ctx context.Context, id string) []Links {
 		if err = rows.Scan(
 			&link.Platform,
 			&link.Url,
+			&link.Subscribers,
 		); err != nil {
 			log.Printf("error fetching links: %v\n", err.Error())
 			return nil
@@ -22,6 +22,10 @@ var (
 	MockBrandStore       BrandStore
 	MockCampaignStore    CampaignStore
 	MockApplicationStore ApplicationStore
+	MockBatchStore       BatchRepository
+	MockWithdrawalStore  WithdrawalStore
+	MockMetricsStore     MetricsStore
+	MockFraudStore       FraudStore
 )
 
 func Init() {
@@ -46,4 +50,8 @@ func Init() {
 	MockSubStore.db = MockDB
 	MockCampaignStore.db = MockDB
 	MockApplicationStore.db = MockDB
+	MockBatchStore.db = MockDB
+	MockWithdrawalStore.db = MockDB
+	MockMetricsStore.db = MockDB
+	MockFraudStore.db = MockDB
 }
@@ -0,0 +1,290 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// holds the funds of the pending withdrawal requests
+const WithdrawalHoldAccountID = "00000000-0000-0000-0000-000000000003"
+
+// macros for the withdrawal request states
+const (
+	WithdrawalPending  = "pending"
+	WithdrawalApproved = "approved"
+	WithdrawalRejected = "rejected"
+	WithdrawalPaid     = "paid"
+	WithdrawalFailed   = "failed"
+)
+
+// macros for withdrawal journal entries
+const (
+	EntryWithdrawHold   = "withdraw_hold"   // wallet -> withdrawal hold
+	EntryWithdrawRefund = "withdraw_refund" // withdrawal hold -> wallet
+)
+
+type WithdrawalStore struct {
+	db *sql.DB
+}
+
+type Withdrawal struct {
+	Id         string  `json:"id"`
+	AccountId  string  `json:"account_id"`
+	HolderId   string  `json:"holder_id"`
+	Amount     float64 `json:"amount"`
+	Currency   string  `json:"currency"`
+	Status     string  `json:"status"`
+	Reason     string  `json:"reason,omitempty"`
+	ReviewedBy string  `json:"reviewed_by,omitempty"`
+	TxId       string  `json:"tx_id,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+const withdrawalColumns = `
+	id, account_id, holder_id, amount, currency, status,
+	COALESCE(reason, ''), COALESCE(reviewed_by, ''), COALESCE(tx_id, ''),
+	created_at, updated_at
+`
+
+func scanWithdrawal(row interface{ Scan(...any) error }, w *Withdrawal) error {
+	return row.Scan(
+		&w.Id,
+		&w.AccountId,
+		&w.HolderId,
+		&w.Amount,
+		&w.Currency,
+		&w.Status,
+		&w.Reason,
+		&w.ReviewedBy,
+		&w.TxId,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	)
+}
+
+// RequestWithdrawal files a pending request and moves the amount out of the
+// wallet into the withdrawal hold so it cannot be spent twice
+func (ws *WithdrawalStore) RequestWithdrawal(ctx context.Context, w *Withdrawal) error {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	w.Status = WithdrawalPending
+	query := `
+		INSERT INTO withdrawal_requests (id, account_id, holder_id, amount, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING created_at, updated_at
+	`
+	err = tx.QueryRowContext(ctx, query,
+		w.Id, w.AccountId, w.HolderId, w.Amount, w.Currency, w.Status,
+	).Scan(&w.CreatedAt, &w.UpdatedAt)
+	if err == sql.ErrNoRows {
+		// the same request was filed already
+		return ErrDuplicateTx
+	}
+	if err != nil {
+		log.Printf("error filing withdrawal for %s: %v\n", w.HolderId, err.Error())
+		return err
+	}
+
+	entry := transfer(uuid.New().String(), "", EntryWithdrawHold,
+		fmt.Sprintf("withdrawal request %s", w.Id),
+		w.AccountId, WithdrawalHoldAccountID, w.Amount)
+	if err := postEntry(ctx, tx, entry); err != nil {
+		log.Printf("error holding withdrawal %s: %v\n", w.Id, err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ws *WithdrawalStore) GetWithdrawal(ctx context.Context, id string) (*Withdrawal, error) {
+	query := `SELECT ` + withdrawalColumns + ` FROM withdrawal_requests WHERE id = $1`
+	var w Withdrawal
+	if err := scanWithdrawal(ws.db.QueryRowContext(ctx, query, id), &w); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		log.Printf("error fetching withdrawal %s: %v\n", id, err.Error())
+		return nil, err
+	}
+	return &w, nil
+}
+
+// lists the requests in a state (all states if empty), oldest first
+func (ws *WithdrawalStore) GetWithdrawals(ctx context.Context, status string, offset, limit int) ([]Withdrawal, error) {
+	query := `
+		SELECT ` + withdrawalColumns + ` FROM withdrawal_requests
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at ASC
+		LIMIT $2 OFFSET $3
+	`
+	return ws.listWithdrawals(ctx, query, status, limit, offset)
+}
+
+// lists the requests of an account holder, latest first
+func (ws *WithdrawalStore) GetHolderWithdrawals(ctx context.Context, holderID string, offset, limit int) ([]Withdrawal, error) {
+	query := `
+		SELECT ` + withdrawalColumns + ` FROM withdrawal_requests
+		WHERE holder_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	return ws.listWithdrawals(ctx, query, holderID, limit, offset)
+}
+
+func (ws *WithdrawalStore) listWithdrawals(ctx context.Context, query string, args ...any) ([]Withdrawal, error) {
+	rows, err := ws.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("error fetching withdrawals: %v\n", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var output []Withdrawal
+	for rows.Next() {
+		var w Withdrawal
+		if err := scanWithdrawal(rows, &w); err != nil {
+			log.Printf("error scanning withdrawal: %v\n", err.Error())
+			return nil, err
+		}
+		output = append(output, w)
+	}
+	return output, nil
+}
+
+// lockWithdrawal locks a request and checks it is in the expected state
+func lockWithdrawal(ctx context.Context, tx *sql.Tx, id, expected string) (*Withdrawal, error) {
+	query := `SELECT ` + withdrawalColumns + ` FROM withdrawal_requests WHERE id = $1 FOR UPDATE`
+	var w Withdrawal
+	if err := scanWithdrawal(tx.QueryRowContext(ctx, query, id), &w); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if w.Status != expected {
+		return nil, ErrInvalidStatus
+	}
+	return &w, nil
+}
+
+func setWithdrawalStatus(ctx context.Context, tx *sql.Tx, w *Withdrawal) error {
+	query := `
+		UPDATE withdrawal_requests
+		SET status = $1, reason = NULLIF($2, ''), reviewed_by = NULLIF($3, ''),
+		tx_id = NULLIF($4, ''), updated_at = now()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+	return tx.QueryRowContext(ctx, query, w.Status, w.Reason, w.ReviewedBy, w.TxId, w.Id).Scan(&w.UpdatedAt)
+}
+
+// ApproveWithdrawal marks a pending request approved by an admin
+func (ws *WithdrawalStore) ApproveWithdrawal(ctx context.Context, id, adminID string) (*Withdrawal, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	w, err := lockWithdrawal(ctx, tx, id, WithdrawalPending)
+	if err != nil {
+		return nil, err
+	}
+	w.Status = WithdrawalApproved
+	w.ReviewedBy = adminID
+	if err := setWithdrawalStatus(ctx, tx, w); err != nil {
+		log.Printf("error approving withdrawal %s: %v\n", id, err.Error())
+		return nil, err
+	}
+
+	return w, tx.Commit()
+}
+
+// RejectWithdrawal refuses a pending request and returns the held funds
+func (ws *WithdrawalStore) RejectWithdrawal(ctx context.Context, id, adminID, reason string) (*Withdrawal, error) {
+	return ws.closeWithdrawal(ctx, id, WithdrawalPending, WithdrawalRejected, adminID, reason)
+}
+
+// FailWithdrawal marks an approved request that could not be paid out and
+// returns the held funds
+func (ws *WithdrawalStore) FailWithdrawal(ctx context.Context, id, reason string) (*Withdrawal, error) {
+	return ws.closeWithdrawal(ctx, id, WithdrawalApproved, WithdrawalFailed, "", reason)
+}
+
+func (ws *WithdrawalStore) closeWithdrawal(ctx context.Context, id, from, to, adminID, reason string) (*Withdrawal, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	w, err := lockWithdrawal(ctx, tx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	w.Status = to
+	w.Reason = reason
+	if adminID != "" {
+		w.ReviewedBy = adminID
+	}
+	if err := setWithdrawalStatus(ctx, tx, w); err != nil {
+		log.Printf("error closing withdrawal %s: %v\n", id, err.Error())
+		return nil, err
+	}
+	entry := transfer(uuid.New().String(), "", EntryWithdrawRefund,
+		fmt.Sprintf("withdrawal request %s %s", id, to),
+		WithdrawalHoldAccountID, w.AccountId, w.Amount)
+	if err := postEntry(ctx, tx, entry); err != nil {
+		log.Printf("error refunding withdrawal %s: %v\n", id, err)
+		return nil, err
+	}
+
+	return w, tx.Commit()
+}
+
+// PayWithdrawal settles an approved request: the held funds leave the platform
+// through a withdraw transaction
+func (ws *WithdrawalStore) PayWithdrawal(ctx context.Context, id string) (*Withdrawal, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	w, err := lockWithdrawal(ctx, tx, id, WithdrawalApproved)
+	if err != nil {
+		return nil, err
+	}
+	logQuery := `
+		INSERT INTO transactions (id, from_id, to_id, amount, currency, status, type)
+	    VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	w.TxId = uuid.New().String()
+	if _, err := tx.ExecContext(ctx, logQuery, w.TxId, w.AccountId, w.AccountId,
+		w.Amount, w.Currency, SuccessTxStatus, EntryWithdraw); err != nil {
+		return nil, fmt.Errorf("log transaction failed: %w", err)
+	}
+	entry := transfer(uuid.New().String(), w.TxId, EntryWithdraw,
+		fmt.Sprintf("withdrawal request %s", id),
+		WithdrawalHoldAccountID, ExternalAccountID, w.Amount)
+	if err := postEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	w.Status = WithdrawalPaid
+	if err := setWithdrawalStatus(ctx, tx, w); err != nil {
+		log.Printf("error paying withdrawal %s: %v\n", id, err.Error())
+		return nil, err
+	}
+
+	return w, tx.Commit()
+}
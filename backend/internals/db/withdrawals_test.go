@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithdrawalWorkflow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	const amount float64 = 1000.0
+	// create mock creator
+	uid := uuid.New().String()
+	generateCreator(ctx, uid)
+	user_acc := generateAccounts(ctx, uid, "user")
+	defer func() {
+		destroyAllTransactions()
+		destroyAccounts(ctx, user_acc.Id)
+		destroyCreator(ctx, uid)
+		cancel()
+	}()
+
+	newRequest := func(t *testing.T) *Withdrawal {
+		w := Withdrawal{
+			Id:        uuid.New().String(),
+			AccountId: user_acc.Id,
+			HolderId:  uid,
+			Amount:    amount,
+			Currency:  "inr",
+		}
+		if err := MockWithdrawalStore.RequestWithdrawal(ctx, &w); err != nil {
+			t.Fail()
+			return nil
+		}
+		return &w
+	}
+	balance := func() float64 {
+		acc, _ := MockTsStore.GetAccount(ctx, user_acc.Id)
+		return acc.Amount
+	}
+
+	t.Run("pending request holds the funds", func(t *testing.T) {
+		w := newRequest(t)
+		if w == nil || w.Status != WithdrawalPending {
+			t.Fail()
+			return
+		}
+		if balance() != user_acc.Amount-amount {
+			t.Fail()
+		}
+		// rejecting gives the funds back
+		rejected, err := MockWithdrawalStore.RejectWithdrawal(ctx, w.Id, "admin", "kyc missing")
+		if err != nil || rejected.Status != WithdrawalRejected {
+			t.Fail()
+			return
+		}
+		if balance() != user_acc.Amount {
+			t.Fail()
+		}
+		// a closed request cannot be approved
+		if _, err := MockWithdrawalStore.ApproveWithdrawal(ctx, w.Id, "admin"); err != ErrInvalidStatus {
+			t.Fail()
+		}
+	})
+	t.Run("approved request is paid out", func(t *testing.T) {
+		w := newRequest(t)
+		if w == nil {
+			return
+		}
+		if _, err := MockWithdrawalStore.ApproveWithdrawal(ctx, w.Id, "admin"); err != nil {
+			t.Fail()
+			return
+		}
+		paid, err := MockWithdrawalStore.PayWithdrawal(ctx, w.Id)
+		if err != nil || paid.Status != WithdrawalPaid || paid.TxId == "" {
+			t.Fail()
+			return
+		}
+		if balance() != user_acc.Amount-amount {
+			t.Fail()
+		}
+	})
+	t.Run("request beyond the balance is rejected", func(t *testing.T) {
+		w := Withdrawal{
+			Id:        uuid.New().String(),
+			AccountId: user_acc.Id,
+			HolderId:  uid,
+			Amount:    user_acc.Amount * 2,
+			Currency:  "inr",
+		}
+		if err := MockWithdrawalStore.RequestWithdrawal(ctx, &w); err == nil {
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,173 @@
+// Package fraud looks for bought views in the polled counters of a submission.
+package fraud
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// names of the detection rules
+const (
+	RuleVelocitySpike = "velocity_spike"
+	RuleLikeRatio     = "like_ratio"
+	RuleEarlyJump     = "early_jump"
+)
+
+// Sample is one polled reading of a video's counters
+type Sample struct {
+	Views int       `json:"views"`
+	Likes int       `json:"likes"`
+	At    time.Time `json:"at"`
+}
+
+// Input is what the detector knows about a submission at a poll
+type Input struct {
+	SubmittedAt    time.Time `json:"submitted_at"`
+	SubmittedViews int       `json:"submitted_views"` // views the video had when it was submitted
+	History        []Sample  `json:"history"`         // earlier samples in the window, oldest first
+	Current        Sample    `json:"current"`
+}
+
+// Finding is a rule that fired along with the numbers behind it
+type Finding struct {
+	Rule      string  `json:"rule"`
+	Detail    string  `json:"detail"`
+	Observed  float64 `json:"observed"`
+	Threshold float64 `json:"threshold"`
+}
+
+type Rules struct {
+	Window time.Duration // history looked at
+
+	// views per hour against the median of the submission's own history
+	SpikeFactor   float64
+	MinSpikeViews int // smaller jumps are never a spike
+	MinHistory    int // samples needed for a baseline
+	MinVelocity   float64
+
+	// likes per view gained in the window
+	MinLikeRatio  float64
+	MaxLikeRatio  float64
+	MinRatioViews int
+
+	// views gained right after the submission
+	EarlyWindow     time.Duration
+	EarlyJumpViews  int
+	EarlyJumpFactor float64
+}
+
+var DefaultRules = Rules{
+	Window: 24 * time.Hour,
+
+	SpikeFactor:   10,
+	MinSpikeViews: 5000,
+	MinHistory:    4,
+	MinVelocity:   10,
+
+	MinLikeRatio:  0.001,
+	MaxLikeRatio:  0.5,
+	MinRatioViews: 10000,
+
+	EarlyWindow:     6 * time.Hour,
+	EarlyJumpViews:  50000,
+	EarlyJumpFactor: 20,
+}
+
+// Detect runs every rule over the input and returns the ones that fired
+func (r Rules) Detect(in Input) []Finding {
+	var findings []Finding
+	for _, rule := range []func(Input) *Finding{r.velocitySpike, r.likeRatio, r.earlyJump} {
+		if finding := rule(in); finding != nil {
+			findings = append(findings, *finding)
+		}
+	}
+	return findings
+}
+
+// views per hour between two samples
+func velocity(from, to Sample) float64 {
+	hours := to.At.Sub(from.At).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return float64(to.Views-from.Views) / hours
+}
+
+func (r Rules) velocitySpike(in Input) *Finding {
+	if len(in.History) < r.MinHistory {
+		return nil
+	}
+	last := in.History[len(in.History)-1]
+	if in.Current.Views-last.Views < r.MinSpikeViews {
+		return nil
+	}
+
+	velocities := make([]float64, 0, len(in.History)-1)
+	for i := 1; i < len(in.History); i++ {
+		velocities = append(velocities, velocity(in.History[i-1], in.History[i]))
+	}
+	sort.Float64s(velocities)
+	baseline := max(velocities[len(velocities)/2], r.MinVelocity)
+
+	current := velocity(last, in.Current)
+	if current < baseline*r.SpikeFactor {
+		return nil
+	}
+	return &Finding{
+		Rule:      RuleVelocitySpike,
+		Detail:    fmt.Sprintf("%.0f views/hour against a median of %.0f", current, baseline),
+		Observed:  current / baseline,
+		Threshold: r.SpikeFactor,
+	}
+}
+
+func (r Rules) likeRatio(in Input) *Finding {
+	if len(in.History) == 0 {
+		return nil
+	}
+	first := in.History[0]
+	views := in.Current.Views - first.Views
+	if views < r.MinRatioViews {
+		return nil
+	}
+	ratio := float64(in.Current.Likes-first.Likes) / float64(views)
+	switch {
+	case ratio < r.MinLikeRatio:
+		return &Finding{
+			Rule:      RuleLikeRatio,
+			Detail:    fmt.Sprintf("%d views gained with %.4f likes per view", views, ratio),
+			Observed:  ratio,
+			Threshold: r.MinLikeRatio,
+		}
+	case ratio > r.MaxLikeRatio:
+		return &Finding{
+			Rule:      RuleLikeRatio,
+			Detail:    fmt.Sprintf("%d views gained with %.4f likes per view", views, ratio),
+			Observed:  ratio,
+			Threshold: r.MaxLikeRatio,
+		}
+	}
+	return nil
+}
+
+func (r Rules) earlyJump(in Input) *Finding {
+	if in.Current.At.Sub(in.SubmittedAt) > r.EarlyWindow {
+		return nil
+	}
+	gained := in.Current.Views - in.SubmittedViews
+	if gained < r.EarlyJumpViews {
+		return nil
+	}
+	growth := float64(gained) / float64(max(in.SubmittedViews, 1))
+	if growth < r.EarlyJumpFactor {
+		return nil
+	}
+	return &Finding{
+		Rule: RuleEarlyJump,
+		Detail: fmt.Sprintf("%d views gained within %s of the submission",
+			gained, in.Current.At.Sub(in.SubmittedAt).Round(time.Minute)),
+		Observed:  growth,
+		Threshold: r.EarlyJumpFactor,
+	}
+}
@@ -0,0 +1,95 @@
+package fraud
+
+import (
+	"testing"
+	"time"
+)
+
+// samples every 30 minutes gaining views per step
+func steady(start time.Time, views, likes, perStep, steps int) []Sample {
+	samples := make([]Sample, 0, steps)
+	for i := range steps {
+		samples = append(samples, Sample{
+			Views: views + i*perStep,
+			Likes: likes + i*perStep/20,
+			At:    start.Add(time.Duration(i) * 30 * time.Minute),
+		})
+	}
+	return samples
+}
+
+func rules(findings []Finding) map[string]bool {
+	fired := make(map[string]bool)
+	for _, finding := range findings {
+		fired[finding.Rule] = true
+	}
+	return fired
+}
+
+func TestDetect(t *testing.T) {
+	submitted := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	history := steady(submitted.Add(24*time.Hour), 20000, 1000, 500, 6)
+	last := history[len(history)-1]
+
+	t.Run("organic growth", func(t *testing.T) {
+		in := Input{
+			SubmittedAt:    submitted,
+			SubmittedViews: 1000,
+			History:        history,
+			Current:        Sample{Views: last.Views + 600, Likes: last.Likes + 30, At: last.At.Add(30 * time.Minute)},
+		}
+		if findings := DefaultRules.Detect(in); len(findings) != 0 {
+			t.Errorf("unexpected findings %+v", findings)
+		}
+	})
+	t.Run("velocity spike", func(t *testing.T) {
+		in := Input{
+			SubmittedAt: submitted,
+			History:     history,
+			// 40x the usual 1000 views per hour
+			Current: Sample{Views: last.Views + 20000, Likes: last.Likes + 1000, At: last.At.Add(30 * time.Minute)},
+		}
+		if fired := rules(DefaultRules.Detect(in)); !fired[RuleVelocitySpike] || fired[RuleLikeRatio] {
+			t.Errorf("fired %v", fired)
+		}
+	})
+	t.Run("views without likes", func(t *testing.T) {
+		flat := steady(submitted.Add(24*time.Hour), 20000, 1000, 2000, 6)
+		for i := range flat {
+			flat[i].Likes = 1000
+		}
+		in := Input{
+			SubmittedAt: submitted,
+			History:     flat,
+			Current:     Sample{Views: flat[5].Views + 2000, Likes: 1005, At: flat[5].At.Add(30 * time.Minute)},
+		}
+		if fired := rules(DefaultRules.Detect(in)); !fired[RuleLikeRatio] {
+			t.Errorf("fired %v", fired)
+		}
+	})
+	t.Run("jump after submission", func(t *testing.T) {
+		in := Input{
+			SubmittedAt:    submitted,
+			SubmittedViews: 200,
+			Current:        Sample{Views: 80000, Likes: 4000, At: submitted.Add(2 * time.Hour)},
+		}
+		if fired := rules(DefaultRules.Detect(in)); !fired[RuleEarlyJump] {
+			t.Errorf("fired %v", fired)
+		}
+		// the same views a day later are not early anymore
+		in.Current.At = submitted.Add(24 * time.Hour)
+		if fired := rules(DefaultRules.Detect(in)); fired[RuleEarlyJump] {
+			t.Errorf("fired %v", fired)
+		}
+	})
+	t.Run("short history has no baseline", func(t *testing.T) {
+		in := Input{
+			SubmittedAt: submitted,
+			History:     history[:2],
+			Current:     Sample{Views: last.Views + 20000, Likes: last.Likes + 1000, At: last.At.Add(30 * time.Minute)},
+		}
+		if fired := rules(DefaultRules.Detect(in)); fired[RuleVelocitySpike] {
+			t.Errorf("fired %v", fired)
+		}
+	})
+}
@@ -135,3 +135,56 @@ func GeneratePasswordResetEmail(email, token string) []byte {
 			"</html>",
 	)
 }
+
+// Function generates the email template for a withdrawal request status change
+func GenerateWithdrawalEmail(w db.Withdrawal) []byte {
+	reason := ""
+	if w.Reason != "" {
+		reason = "<p><strong>Reason:</strong> " + w.Reason + "</p>"
+	}
+	return []byte(
+		"<html>" +
+			"<body style='font-family: Arial, sans-serif; background-color:#f9fafb; padding:20px;'>" +
+			"<div style='max-width:600px; margin:auto; background:#ffffff; padding:20px; border-radius:8px; border:1px solid #e5e7eb;'>" +
+			"<h2 style='color:#111827;'>💸 Withdrawal " + w.Status + "</h2>" +
+
+			"<p><strong>Request ID:</strong> " + w.Id + "</p>" +
+			"<p><strong>Amount:</strong> " + fmt.Sprintf("%.2f %s", w.Amount, w.Currency) + "</p>" +
+			"<p><strong>Status:</strong> " + w.Status + "</p>" +
+			reason +
+			"<p><strong>Updated At:</strong> " + w.UpdatedAt + "</p>" +
+
+			"<p style='margin-top:20px; font-size:12px; color:#6b7280;'>This is an automated message from CampaignHub.</p>" +
+			"</div>" +
+			"</body>" +
+			"</html>",
+	)
+}
+
+// Function generates the email template for a campaign review decision
+func GenerateCampaignReviewEmail(campaign db.CampaignResp, decision, reason string) []byte {
+	headings := map[string]string{
+		db.CampaignApproved:         "Campaign approved",
+		db.CampaignRejected:         "Campaign rejected",
+		db.CampaignChangesRequested: "Changes requested on your campaign",
+	}
+	details := ""
+	if reason != "" {
+		details = "<p><strong>Reason:</strong> " + reason + "</p>"
+	}
+	return []byte(
+		"<html>" +
+			"<body style='font-family: Arial, sans-serif; background-color:#f9fafb; padding:20px;'>" +
+			"<div style='max-width:600px; margin:auto; background:#ffffff; padding:20px; border-radius:8px; border:1px solid #e5e7eb;'>" +
+			"<h2 style='color:#111827;'>📣 " + headings[decision] + "</h2>" +
+
+			"<p><strong>Campaign ID:</strong> " + campaign.Id + "</p>" +
+			"<p><strong>Title:</strong> " + campaign.Title + "</p>" +
+			details +
+
+			"<p style='margin-top:20px; font-size:12px; color:#6b7280;'>This is an automated message from CampaignHub.</p>" +
+			"</div>" +
+			"</body>" +
+			"</html>",
+	)
+}
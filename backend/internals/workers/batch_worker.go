@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"slices"
 	"time"
 
 	"github.com/Alter-Sitanshu/campaignHub/internals"
@@ -14,14 +16,22 @@ import (
 func NewBatchWorker(
 	cache *cache.Service,
 	repo *db.Store,
+	notifier Notifier,
+	budgetAlerts []float64,
 	interval time.Duration,
 ) *BatchWorker {
+	host, _ := os.Hostname()
 	return &BatchWorker{
-		cache:     cache,
-		repo:      repo,
-		interval:  interval,
-		batchSize: 100, // Process 100 updates at a time
-		stopChan:  make(chan struct{}),
+		cache:         cache,
+		repo:          repo,
+		interval:      interval,
+		batchSize:     100, // Process 100 updates at a time
+		consumer:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		claimIdle:     2 * interval,
+		maxDeliveries: 5,
+		notifier:      notifier,
+		budgetAlerts:  budgetAlerts,
+		stopChan:      make(chan struct{}),
 	}
 }
 
@@ -48,30 +58,27 @@ func (w *BatchWorker) Start(ctx context.Context) {
 	}
 }
 
+// processBatch applies one batch of queued updates. An update is acknowledged
+// only once everything it produced is committed, anything else is redelivered
+// on a later run until it runs out of retries and lands in the dead letters.
 func (w *BatchWorker) processBatch(ctx context.Context) {
-	// Get queue length
-	queueLength, err := w.cache.GetBatchQueueLength(ctx)
+	// Retrieve pending updates
+	queued, err := w.cache.ReadBatchUpdates(ctx, w.consumer, int64(w.batchSize), w.claimIdle, w.maxDeliveries)
 	if err != nil {
-		log.Printf("Failed to get queue length: %v", err)
+		log.Printf("Failed to fetch batch updates: %v", err)
 		return
 	}
 
-	if queueLength == 0 {
+	if len(queued) == 0 {
 		log.Println("No pending batch updates")
 		return
 	}
 
-	log.Printf("Processing %d pending updates...", queueLength)
-
-	// Retrieve pending updates
-	updates, err := w.cache.GetPendingBatchUpdates(ctx, int64(w.batchSize))
-	if err != nil {
-		log.Printf("Failed to fetch batch updates: %v", err)
-		return
-	}
+	log.Printf("Processing %d pending updates...", len(queued))
 
-	if len(updates) == 0 {
-		return
+	updates := make([]*internals.BatchUpdate, 0, len(queued))
+	for _, entry := range queued {
+		updates = append(updates, entry.Update)
 	}
 
 	// Group updates by type
@@ -79,27 +86,139 @@ func (w *BatchWorker) processBatch(ctx context.Context) {
 
 	// Update submissions
 	if err := w.repo.BatchInterface.BatchUpdateSubmissions(ctx, groupedUpdates.Submissions); err != nil {
+		// nothing is acknowledged, the whole batch comes back later
 		log.Printf("Batch submission update failed: %v", err)
+		return
+	}
+
+	// Pay the creators one aggregated payout per campaign
+	payouts := make([]*db.CampaignPayout, 0, len(groupedUpdates.Payouts))
+	for _, payout := range groupedUpdates.Payouts {
+		payouts = append(payouts, payout)
+	}
+	if err := w.repo.BatchInterface.BatchPayouts(ctx, payouts); err != nil {
+		log.Printf("Batch payout failed: %v", err)
 	}
 
-	// Update creator balances
-	if err := w.repo.BatchInterface.BatchUpdateCreatorBalances(ctx, groupedUpdates.CreatorBalances); err != nil {
-		log.Printf("Batch creator balance update failed: %v", err)
-	} else {
-		// Invalidate user profile cache for all creators so balance is refreshed from DB
-		for creatorID := range groupedUpdates.CreatorBalances {
-			if err := w.cache.Delete(ctx, fmt.Sprintf("user:%s", creatorID)); err != nil {
-				log.Printf("Failed to invalidate profile cache for creator %s: %v", creatorID, err)
+	// Acknowledge the updates whose payout settled (or had nothing to pay)
+	acked := make([]string, 0, len(queued))
+	for _, entry := range queued {
+		key := entry.Update.CampaignID + ":" + entry.Update.CreatorID
+		payout, ok := groupedUpdates.Payouts[key]
+		if ok && payout.Failed {
+			continue
+		}
+		if ok && slices.Contains(payout.Held, entry.Update.SubmissionID) {
+			// kept for an admin to replay once the fraud review clears the submission
+			if err := w.cache.DeadLetterBatchUpdate(ctx, entry, "payouts held for fraud review"); err != nil {
+				log.Printf("Failed to set aside held update %s: %v", entry.ID, err)
 			}
+			continue
+		}
+		acked = append(acked, entry.ID)
+	}
+	if err := w.cache.AckBatchUpdates(ctx, acked...); err != nil {
+		log.Printf("Failed to acknowledge batch updates: %v", err)
+	}
+
+	for _, payout := range payouts {
+		if payout.Failed {
+			// the cache follows the payout once it settles on a redelivery
+			continue
 		}
+		w.settleBudget(ctx, payout)
 	}
 
-	// Update campaign budgets
-	if err := w.repo.BatchInterface.BatchUpdateCampaignBudgets(ctx, groupedUpdates.CampaignBudgets); err != nil {
-		log.Printf("Batch campaign budget update failed: %v", err)
+	// Invalidate user profile cache for the paid creators so balance is refreshed from DB
+	for _, payout := range payouts {
+		if payout.TxId == "" && len(payout.Held) == 0 {
+			continue
+		}
+		if err := w.cache.Delete(ctx, fmt.Sprintf("user:%s", payout.CreatorID)); err != nil {
+			log.Printf("Failed to invalidate profile cache for creator %s: %v", payout.CreatorID, err)
+		}
+	}
+
+	log.Printf("Batch processing complete: %d/%d updates acknowledged", len(acked), len(queued))
+}
+
+// settleBudget brings the cached budget and earnings in line with what a
+// payout really paid, warns the brand when the budget left drops below one
+// of the alert thresholds and announces the end of an exhausted campaign
+func (w *BatchWorker) settleBudget(ctx context.Context, payout *db.CampaignPayout) {
+	if payout.Capped > 0 {
+		// the polling worker counted the earnings before the budget ran out
+		w.cache.UpdateUserBalance(ctx, payout.CreatorID, -payout.Capped)
+		for subID := range payout.Views {
+			w.cache.InvalidateSubmissionEarnings(ctx, subID)
+		}
+	}
+	if len(payout.Awards) > 0 && payout.TxId != "" {
+		// milestones are only known once the payout settled
+		var bonus float64
+		for _, award := range payout.Awards {
+			bonus += award.Amount
+			w.cache.InvalidateSubmissionEarnings(ctx, award.SubmissionID)
+		}
+		w.cache.UpdateUserBalance(ctx, payout.CreatorID, bonus)
+	}
+	if len(payout.Held) > 0 {
+		// the polling worker counted earnings that are not paid for now,
+		// the balance is read again from the ledger
+		w.cache.InvalidateUserBalance(ctx, payout.CreatorID)
+		for _, subID := range payout.Held {
+			w.cache.InvalidateSubmissionEarnings(ctx, subID)
+		}
+	}
+	if (payout.TxId == "" && !payout.Exhausted) || payout.Ended {
+		// an ended campaign has no budget left to track
+		return
+	}
+	if payout.Exhausted {
+		if err := w.cache.RemoveEndedCampaign(ctx, payout.CampaignID); err != nil {
+			log.Printf("Failed to remove campaign %s from the active ones: %v", payout.CampaignID, err)
+		}
+		w.notify(payout.BrandID, map[string]any{
+			"type":        "campaign:budget_exhausted",
+			"campaign_id": payout.CampaignID,
+			"funded":      payout.Funded,
+		})
+		w.notify(payout.CreatorID, map[string]any{
+			"type":        "campaign:budget_exhausted",
+			"campaign_id": payout.CampaignID,
+			"capped":      payout.Capped,
+		})
+		return
+	}
+	w.cache.SetCampaignBudget(ctx, payout.CampaignID, payout.Remaining)
+	if payout.Funded <= 0 {
+		return
 	}
+	before := (payout.Remaining + payout.Amount()) / payout.Funded
+	after := payout.Remaining / payout.Funded
+	// a payout crossing several thresholds only warns about the lowest one
+	crossed := -1.0
+	for _, threshold := range w.budgetAlerts {
+		if before > threshold && after <= threshold && (crossed < 0 || threshold < crossed) {
+			crossed = threshold
+		}
+	}
+	if crossed >= 0 {
+		w.notify(payout.BrandID, map[string]any{
+			"type":        "campaign:budget_low",
+			"campaign_id": payout.CampaignID,
+			"remaining":   payout.Remaining,
+			"funded":      payout.Funded,
+			"threshold":   crossed,
+		})
+	}
+}
 
-	log.Printf("Batch processing complete")
+func (w *BatchWorker) notify(userID string, payload map[string]any) {
+	if w.notifier == nil || userID == "" {
+		return
+	}
+	w.notifier.Notify(userID, payload)
 }
 
 // merges multiple updates for the same submission
@@ -107,8 +226,7 @@ func (w *BatchWorker) processBatch(ctx context.Context) {
 // that we need to make to the db
 func (w *BatchWorker) groupAndMergeUpdates(updates []*internals.BatchUpdate) *GroupedUpdates {
 	grouped := &GroupedUpdates{
-		CreatorBalances: make(map[string]float64),
-		CampaignBudgets: make(map[string]float64),
+		Payouts: make(map[string]*db.CampaignPayout),
 	}
 
 	// map to merge duplicate submission updates
@@ -120,7 +238,8 @@ func (w *BatchWorker) groupAndMergeUpdates(updates []*internals.BatchUpdate) *Gr
 			// Merge deltas
 			existing.ViewsDelta += update.ViewsDelta
 			existing.EarningsDelta += update.EarningsDelta
-			existing.NewViews = update.NewViews   // Use latest
+			existing.OldViews = min(existing.OldViews, update.OldViews)
+			existing.NewViews = max(existing.NewViews, update.NewViews)
 			existing.LikeCount = update.LikeCount // Use latest
 
 			// Update metadata if provided
@@ -131,14 +250,36 @@ func (w *BatchWorker) groupAndMergeUpdates(updates []*internals.BatchUpdate) *Gr
 			submissionMap[update.SubmissionID] = update
 		}
 
-		// Aggregate creator balances
-		if update.CreatorID != "" {
-			grouped.CreatorBalances[update.CreatorID] += update.EarningsDelta
-		}
-
-		// Aggregate campaign budgets
-		if update.CampaignID != "" {
-			grouped.CampaignBudgets[update.CampaignID] += update.EarningsDelta
+		// Aggregate earnings per (campaign, creator) pair
+		if update.CreatorID != "" && update.CampaignID != "" {
+			key := update.CampaignID + ":" + update.CreatorID
+			payout, exists := grouped.Payouts[key]
+			if !exists {
+				payout = &db.CampaignPayout{
+					CampaignID:  update.CampaignID,
+					CreatorID:   update.CreatorID,
+					Submissions: make(map[string]float64),
+					Views:       make(map[string]db.ViewRange),
+				}
+				grouped.Payouts[key] = payout
+			}
+			payout.Submissions[update.SubmissionID] += update.EarningsDelta
+			// an unreadable time counts as queued now, after any end of the campaign
+			queuedAt, err := time.Parse(time.RFC3339Nano, update.Timestamp)
+			if err != nil {
+				queuedAt = time.Now()
+			}
+			if queuedAt.After(payout.QueuedAt) {
+				payout.QueuedAt = queuedAt
+			}
+			// the share pays for the views covered by all the merged updates
+			views, seen := payout.Views[update.SubmissionID]
+			if !seen {
+				views = db.ViewRange{From: update.OldViews, To: update.NewViews}
+			}
+			views.From = min(views.From, update.OldViews)
+			views.To = max(views.To, update.NewViews)
+			payout.Views[update.SubmissionID] = views
 		}
 	}
 
@@ -0,0 +1,52 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+)
+
+// how often the metrics history is compacted
+const metricsCompactInterval = time.Hour
+
+func NewMetricsWorker(repo *db.Store, interval time.Duration) *MetricsWorker {
+	return &MetricsWorker{
+		repo:     repo,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start rolls the old metric points up into hours and days on every tick
+func (w *MetricsWorker) Start(ctx context.Context) {
+	log.Println("Metrics worker started...")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.compact(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			w.compact(ctx)
+		case <-w.stopChan:
+			log.Println("Metrics worker stopped")
+			return
+		case <-ctx.Done():
+			log.Println("Metrics worker context cancelled")
+			return
+		}
+	}
+}
+
+func (w *MetricsWorker) compact(ctx context.Context) {
+	if err := w.repo.MetricsInterface.CompactMetrics(ctx, time.Now()); err != nil {
+		log.Printf("Failed to compact submission metrics: %v", err)
+	}
+}
+
+func (w *MetricsWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stopChan) })
+}
@@ -12,51 +12,85 @@ import (
 )
 
 type GroupedUpdates struct {
-	Submissions     []*internals.BatchUpdate
-	CreatorBalances map[string]float64 // creatorID -> delta
-	CampaignBudgets map[string]float64 // campaignID -> delta
+	Submissions []*internals.BatchUpdate
+	Payouts     map[string]*db.CampaignPayout // campaignID:creatorID -> payout
 }
 type BatchWorker struct {
-	cache     *cache.Service
-	repo      *db.Store
-	interval  time.Duration
-	batchSize int
-	stopOnce  sync.Once // Guard against multiple close attempts concurrently
-	stopChan  chan struct{}
+	cache         *cache.Service
+	repo          *db.Store
+	interval      time.Duration
+	batchSize     int
+	consumer      string        // name of this worker in the consumer group
+	claimIdle     time.Duration // unacknowledged updates are redelivered after this
+	maxDeliveries int64         // updates are dead lettered after this many deliveries
+	notifier      Notifier
+	budgetAlerts  []float64 // fractions of the budget left that warn the brand
+	stopOnce      sync.Once // Guard against multiple close attempts concurrently
+	stopChan      chan struct{}
+}
+
+// Notifier pushes an event to a connected user
+type Notifier interface {
+	Notify(userID string, payload map[string]any)
 }
 
 type PollingWorker struct {
 	repo           *db.Store
 	cache          *cache.Service
 	platformClient *platform.Factory
+	notifier       Notifier
 	interval       time.Duration
 	stopOnce       sync.Once
 	stopChan       chan struct{}
 }
 
+type MetricsWorker struct {
+	repo     *db.Store
+	interval time.Duration
+	stopOnce sync.Once
+	stopChan chan struct{}
+}
+
+type SchedulerWorker struct {
+	repo     *db.Store
+	cache    *cache.Service
+	notifier Notifier
+	interval time.Duration
+	stopOnce sync.Once
+	stopChan chan struct{}
+}
+
 type AppWorkers struct {
-	Batch  *BatchWorker
-	Poll   *PollingWorker
-	cancel context.CancelFunc
+	Batch     *BatchWorker
+	Poll      *PollingWorker
+	Metrics   *MetricsWorker
+	Scheduler *SchedulerWorker
+	cancel    context.CancelFunc
 }
 
 func NewAppWorker(
 	cache *cache.Service, repo *db.Store,
-	factory *platform.Factory,
+	factory *platform.Factory, notifier Notifier,
+	budgetAlerts []float64,
 	BatchInterval, PollInterval time.Duration,
 ) *AppWorkers {
 	return &AppWorkers{
 		Batch: NewBatchWorker(
 			cache,
 			repo,
+			notifier,
+			budgetAlerts,
 			BatchInterval,
 		),
 		Poll: NewPollingWorker(
 			repo,
 			cache,
 			factory,
+			notifier,
 			PollInterval,
 		),
+		Metrics:   NewMetricsWorker(repo, metricsCompactInterval),
+		Scheduler: NewSchedulerWorker(repo, cache, notifier, scheduleInterval),
 	}
 }
 
@@ -3,25 +3,44 @@ package workers
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"time"
 
 	"github.com/Alter-Sitanshu/campaignHub/internals"
 	"github.com/Alter-Sitanshu/campaignHub/internals/cache"
 	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+	"github.com/Alter-Sitanshu/campaignHub/internals/fraud"
 	"github.com/Alter-Sitanshu/campaignHub/services/platform"
 )
 
+// smallest view change worth a batch update
+const minViewsDelta = 10
+
+// polling of the videos that stop earning (minutes)
+const (
+	unavailableRecheck   = 24 * 60 // unavailable videos are rechecked daily
+	resumedSyncFrequency = 5
+)
+
+// throttling while the platform quota is low
+const (
+	lowVelocityViews     = 100     // views per day below which a video is slow
+	maxDeferredFrequency = 24 * 60 // deferred videos are still polled daily (minutes)
+)
+
 func NewPollingWorker(
 	repo *db.Store,
 	cache *cache.Service,
 	platformClient *platform.Factory,
+	notifier Notifier,
 	interval time.Duration,
 ) *PollingWorker {
 	return &PollingWorker{
 		repo:           repo,
 		cache:          cache,
 		platformClient: platformClient,
+		notifier:       notifier,
 		interval:       interval,
 		stopChan:       make(chan struct{}),
 	}
@@ -50,6 +69,12 @@ func (w *PollingWorker) Start(ctx context.Context) {
 	}
 }
 
+// a polled submission with its parsed video id
+type pollTarget struct {
+	submission db.PollingSubmission
+	videoID    string
+}
+
 func (w *PollingWorker) poll(ctx context.Context) {
 	submissions, err := w.repo.SubmissionInterface.GetSubmissionsForSync(ctx)
 	if err != nil {
@@ -59,32 +84,288 @@ func (w *PollingWorker) poll(ctx context.Context) {
 
 	log.Printf("Poll signal caught: %d submissions...", len(submissions))
 
+	// Group by platform so every platform is asked in batches
+	byPlatform := make(map[string][]pollTarget)
 	for _, submission := range submissions {
-		if err := w.syncSubmission(ctx, submission); err != nil {
+		parsed, err := platform.ParseVideoURL(submission.Url)
+		if err != nil {
 			log.Printf("Error syncing submission %s: %v", submission.Id, err)
+			continue
+		}
+		byPlatform[parsed.Name] = append(byPlatform[parsed.Name], pollTarget{submission, parsed.VideoID})
+	}
+
+	for name, targets := range byPlatform {
+		targets = w.fitQuota(ctx, name, targets)
+		// videos asked from the platform in one call
+		size := platform.CapabilitiesOf(name).BatchSize
+		for start := 0; start < len(targets); start += size {
+			w.syncBatch(ctx, name, targets[start:min(start+size, len(targets))])
 		}
 	}
 }
 
-func (w *PollingWorker) syncSubmission(ctx context.Context, submission db.PollingSubmission) error {
-	// Parse video URL
-	parsed, err := platform.ParseVideoURL(submission.Url)
+// fitQuota trims the poll to what the platform quota can still pay for.
+// Once the quota runs low the old and slow videos are skipped and polled less
+// often so the units go to the videos that are still earning.
+func (w *PollingWorker) fitQuota(ctx context.Context, name string, targets []pollTarget) []pollTarget {
+	report, err := w.platformClient.QuotaStatus(ctx, name)
 	if err != nil {
-		return err
+		log.Printf("Failed to read %s quota: %v", name, err)
+		return targets
+	}
+	if report == nil {
+		// platform without quota
+		return targets
+	}
+	if report.Remaining <= 0 {
+		log.Printf("Skipping %d %s submissions: quota exhausted until %s", len(targets), name, report.ResetsAt)
+		return nil
+	}
+
+	if report.Low() {
+		kept := make([]pollTarget, 0, len(targets))
+		for _, target := range targets {
+			if isLowPriority(target.submission) {
+				// the original frequency comes back once the quota recovers
+				first, err := w.cache.DeferSync(ctx, name, target.submission.Id, target.submission.SyncFrequency)
+				if err != nil {
+					log.Printf("Failed to defer submission %s: %v", target.submission.Id, err)
+					kept = append(kept, target)
+					continue
+				}
+				// slowed down once per low quota window, not on every poll
+				if first {
+					freq := min(max(target.submission.SyncFrequency, 1)*2, maxDeferredFrequency)
+					w.repo.SubmissionInterface.UpdateSyncFrequency(ctx, target.submission.Id, freq)
+				}
+				continue
+			}
+			kept = append(kept, target)
+		}
+		log.Printf("%s quota low (%d left): deferred %d submissions", name, report.Remaining, len(targets)-len(kept))
+		targets = kept
+	} else {
+		w.restoreDeferred(ctx, name)
+	}
+
+	// never plan more calls than the units left can pay for
+	if platform.BatchUnits(name, len(targets)) > report.Remaining {
+		targets = targets[:platform.AffordableVideos(name, report.Remaining)]
+	}
+	return targets
+}
+
+// restoreDeferred puts back the sync frequency of the submissions slowed down
+// while the quota was low
+func (w *PollingWorker) restoreDeferred(ctx context.Context, name string) {
+	deferred, err := w.cache.TakeDeferredSyncs(ctx, name)
+	if err != nil {
+		log.Printf("Failed to read deferred %s submissions: %v", name, err)
+		return
 	}
+	for submissionID, freq := range deferred {
+		if err := w.repo.SubmissionInterface.UpdateSyncFrequency(ctx, submissionID, freq); err != nil {
+			log.Printf("Failed to restore sync frequency of submission %s: %v", submissionID, err)
+		}
+	}
+	if len(deferred) > 0 {
+		log.Printf("%s quota recovered: restored %d deferred submissions", name, len(deferred))
+	}
+}
 
-	// Fetch metadata from platform
-	metadata, err := w.platformClient.GetVideoDetailsForWorkers(ctx, parsed.Name, parsed.VideoID)
+// old videos and videos gaining few views are polled last when quota is scarce
+func isLowPriority(submission db.PollingSubmission) bool {
+	created, err := time.Parse(time.RFC3339, submission.CreatedAt)
+	if err != nil {
+		return false
+	}
+	ageInDays := time.Since(created).Hours() / 24
+	if ageInDays >= 7 {
+		return true
+	}
+	return float64(submission.Views)/max(ageInDays, 1) < lowVelocityViews
+}
+
+// fetches one chunk of videos in a single call and syncs their submissions
+func (w *PollingWorker) syncBatch(ctx context.Context, name string, targets []pollTarget) {
+	ids := make([]string, 0, len(targets))
+	for _, target := range targets {
+		ids = append(ids, target.videoID)
+	}
+
+	videos, err := w.platformClient.GetVideosDetails(ctx, name, ids)
+	if err != nil {
+		log.Printf("Failed to fetch %s videos: %v", name, err)
+		return
+	}
+
+	// recent counters of the submissions for the fraud checks
+	submissionIDs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		submissionIDs = append(submissionIDs, target.submission.Id)
+	}
+	history, err := w.repo.MetricsInterface.GetRecentMetrics(ctx, submissionIDs, time.Now().Add(-fraud.DefaultRules.Window))
+	if err != nil {
+		log.Printf("Failed to fetch %s metrics history: %v", name, err)
+	}
+
+	points := make([]db.MetricPoint, 0, len(targets))
+	for _, target := range targets {
+		metadata, ok := videos[target.videoID]
+		if !ok {
+			// only the platform can say a video is gone, a video missing from the
+			// response is retried on the next poll
+			log.Printf("Skipping submission %s: %s returned no data for video %s", target.submission.Id, name, target.videoID)
+			continue
+		}
+		if metadata.Status == "" {
+			metadata.Status = platform.VideoAvailable
+		}
+
+		var err error
+		switch {
+		case metadata.Status != target.submission.VideoStatus:
+			err = w.changeVideoStatus(ctx, target.submission, metadata)
+		case platform.Unavailable(metadata.Status):
+			// still unavailable, earnings stay paused until the next recheck
+			err = w.repo.SubmissionInterface.SetVideoStatus(ctx, target.submission.Id,
+				metadata.Status, unavailableRecheck, 0)
+		default:
+			if !target.submission.PayoutsHeld && history != nil {
+				target.submission.PayoutsHeld = w.checkFraud(ctx, target.submission, metadata, history[target.submission.Id])
+			}
+			var point *db.MetricPoint
+			point, err = w.syncSubmission(ctx, target.submission, metadata)
+			if point != nil {
+				points = append(points, *point)
+			}
+		}
+		if err != nil {
+			log.Printf("Error syncing submission %s: %v", target.submission.Id, err)
+		}
+	}
+
+	// keep the history of the synced counters
+	if err := w.repo.MetricsInterface.RecordMetrics(ctx, points); err != nil {
+		log.Printf("Failed to record %s metrics: %v", name, err)
+	}
+}
+
+// checkFraud runs the detector over the polled counters and holds the payouts
+// of a suspicious submission until an admin reviews it
+func (w *PollingWorker) checkFraud(ctx context.Context, submission db.PollingSubmission, metadata *platform.VideoMetadata, history []db.MetricPoint) bool {
+	in := fraud.Input{
+		SubmittedViews: submission.Views,
+		Current:        fraud.Sample{Views: metadata.ViewCount, Likes: metadata.LikeCount, At: time.Now()},
+	}
+	in.SubmittedAt, _ = time.Parse(time.RFC3339, submission.CreatedAt)
+	for i, point := range history {
+		at, _ := time.Parse(time.RFC3339, point.RecordedAt)
+		if i == 0 {
+			// only matters for new submissions, whose first point is in the window
+			in.SubmittedViews = point.Views - point.ViewsDelta
+		}
+		in.History = append(in.History, fraud.Sample{Views: point.Views, Likes: point.LikeCount, At: at})
+	}
+
+	findings := fraud.DefaultRules.Detect(in)
+	if len(findings) == 0 {
+		return false
+	}
+	rules := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		rules = append(rules, finding.Rule)
+	}
+	evidence, err := json.Marshal(map[string]any{
+		"findings": findings,
+		"input":    in,
+	})
+	if err != nil {
+		log.Printf("Failed to encode fraud evidence of submission %s: %v", submission.Id, err)
+		return false
+	}
+
+	held, err := w.repo.FraudInterface.FlagSubmission(ctx, &db.FraudFlag{
+		SubmissionId: submission.Id,
+		Rules:        rules,
+		Evidence:     evidence,
+	})
+	if err != nil {
+		log.Printf("Failed to flag submission %s: %v", submission.Id, err)
+		return false
+	}
+	if held {
+		log.Printf("Held the payouts of submission %s: %v", submission.Id, rules)
+	}
+	return held
+}
+
+// changeVideoStatus records a video changing availability. Unavailable videos
+// stop earning and are only rechecked daily, a video coming back earns again
+// from the views it has by then.
+func (w *PollingWorker) changeVideoStatus(ctx context.Context, submission db.PollingSubmission, metadata *platform.VideoMetadata) error {
+	wasPaused := platform.Unavailable(submission.VideoStatus)
+	paused := platform.Unavailable(metadata.Status)
+
+	freq, skipTo := submission.SyncFrequency, 0
+	switch {
+	case paused:
+		freq = unavailableRecheck
+	case wasPaused:
+		freq, skipTo = resumedSyncFrequency, metadata.ViewCount
+	}
+	err := w.repo.SubmissionInterface.SetVideoStatus(ctx, submission.Id, metadata.Status, freq, skipTo)
 	if err != nil {
-		log.Printf("error: %s", err.Error())
 		return err
 	}
+	log.Printf("Video of submission %s is now %s (was %s)", submission.Id, metadata.Status, submission.VideoStatus)
+
+	if paused != wasPaused {
+		// the next claim starts over from the accounted views
+		w.cache.ResetQueuedViews(ctx, submission.Id)
+		w.notifyVideoStatus(ctx, submission, metadata.Status)
+	}
+	return nil
+}
+
+// tells the creator and the brand that a submission stopped or resumed earning
+func (w *PollingWorker) notifyVideoStatus(ctx context.Context, submission db.PollingSubmission, status string) {
+	if w.notifier == nil {
+		return
+	}
+	payload := map[string]any{
+		"type":          "submission:video_status",
+		"submission_id": submission.Id,
+		"campaign_id":   submission.CampaignId,
+		"video_status":  status,
+		"earning":       !platform.Unavailable(status),
+	}
+	w.notifier.Notify(submission.CreatorId, payload)
+
+	campaign, err := w.repo.CampaignInterace.GetCampaign(ctx, submission.CampaignId)
+	if err != nil {
+		log.Printf("Failed to notify the brand of submission %s: %v", submission.Id, err)
+		return
+	}
+	w.notifier.Notify(campaign.BrandId, payload)
+}
+
+// syncSubmission caches the fetched counters and queues the earnings of the new
+// views. It returns the metrics point of the sync, nil if it was ignored.
+func (w *PollingWorker) syncSubmission(ctx context.Context, submission db.PollingSubmission, metadata *platform.VideoMetadata) (*db.MetricPoint, error) {
 	// Calculate changes
 	viewsDelta := metadata.ViewCount - submission.Views
 	if viewsDelta < 0 {
 		// some inconsistency from the API side.
 		// ignore this update
-		return nil
+		return nil, nil
+	}
+	point := &db.MetricPoint{
+		SubmissionId: submission.Id,
+		Views:        metadata.ViewCount,
+		LikeCount:    metadata.LikeCount,
+		Source:       "polling_worker",
 	}
 
 	// Update cache immediately (real-time data)
@@ -99,21 +380,48 @@ func (w *PollingWorker) syncSubmission(ctx context.Context, submission db.Pollin
 	}
 	w.cache.SetVideoMetadata(ctx, submission.Id, cacheMetadata)
 
+	if submission.PayoutsHeld {
+		// the views wait above the watermark until the fraud review
+		return point, nil
+	}
+
 	// Only queue batch update if significant change
-	if abs(viewsDelta) >= 10 {
+	if abs(viewsDelta) >= minViewsDelta {
+		// Claim the views not queued yet so overlapping polls never queue
+		// the same range twice
+		from, claimed, err := w.cache.ClaimQueuedViews(ctx, submission.Id,
+			metadata.ViewCount, submission.AccountedViews, minViewsDelta)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			return point, nil
+		}
+		viewsDelta = metadata.ViewCount - from
+
 		// Get campaign for CPM calculation
 		campaign, err := w.repo.CampaignInterace.GetCampaign(ctx, submission.CampaignId)
 		if err != nil {
-			return err
+			w.cache.ResetQueuedViews(ctx, submission.Id)
+			return nil, err
+		}
+
+		rules, err := w.repo.CampaignInterace.GetPayoutRules(ctx, submission.CampaignId)
+		if err != nil {
+			w.cache.ResetQueuedViews(ctx, submission.Id)
+			return nil, err
 		}
 
-		// Calculate earnings
-		earningsDelta := float64(viewsDelta) * campaign.CPM / 1000.0
+		// Calculate earnings at the CPM tiers the views fall in, views over
+		// the campaign caps are still tracked but no longer earn
+		payable := campaign.PayableViews(viewsDelta, submission.PaidViews)
+		earningsDelta := db.ViewEarnings(campaign.CPM, rules, from, from+payable)
+		earningsDelta = campaign.PayableEarnings(earningsDelta, submission.CreatorEarnings)
 
 		// Create batch update
 		batchUpdate := &internals.BatchUpdate{
 			SubmissionID:  submission.Id,
-			OldViews:      submission.Views,
+			OldViews:      from,
 			NewViews:      metadata.ViewCount,
 			ViewsDelta:    viewsDelta,
 			VideoTitle:    metadata.Title,
@@ -121,14 +429,18 @@ func (w *PollingWorker) syncSubmission(ctx context.Context, submission db.Pollin
 			EarningsDelta: earningsDelta,
 			CampaignID:    submission.CampaignId,
 			CreatorID:     submission.CreatorId,
-			Timestamp:     time.Now().String(),
+			Timestamp:     time.Now().UTC().Format(time.RFC3339Nano),
 			Source:        "polling_worker",
 		}
 
 		// Queue for batch processing
 		if err := w.cache.QueueBatchUpdate(ctx, batchUpdate); err != nil {
 			log.Printf("Failed to queue batch update: %v", err)
+			// give the range back, the next poll claims it from the db watermark
+			w.cache.ResetQueuedViews(ctx, submission.Id)
+			return nil, err
 		}
+		point.EarningsDelta = earningsDelta
 		// Adjust sync frequency based on video age
 		w.adjustSyncFrequency(ctx, submission)
 
@@ -138,7 +450,7 @@ func (w *PollingWorker) syncSubmission(ctx context.Context, submission db.Pollin
 		w.cache.DecrementCampaignBudget(ctx, submission.CampaignId, earningsDelta)
 	}
 
-	return nil
+	return point, nil
 }
 
 func (w *PollingWorker) Stop() {
@@ -0,0 +1,119 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Alter-Sitanshu/campaignHub/internals/cache"
+	"github.com/Alter-Sitanshu/campaignHub/internals/db"
+)
+
+// how often the campaign schedules are checked
+const scheduleInterval = time.Minute
+
+func NewSchedulerWorker(
+	repo *db.Store,
+	cache *cache.Service,
+	notifier Notifier,
+	interval time.Duration,
+) *SchedulerWorker {
+	return &SchedulerWorker{
+		repo:     repo,
+		cache:    cache,
+		notifier: notifier,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start activates and ends the scheduled campaigns on every tick
+func (w *SchedulerWorker) Start(ctx context.Context) {
+	log.Println("Scheduler worker started...")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.schedule(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			w.schedule(ctx)
+		case <-w.stopChan:
+			log.Println("Scheduler worker stopped")
+			return
+		case <-ctx.Done():
+			log.Println("Scheduler worker context cancelled")
+			return
+		}
+	}
+}
+
+// schedule moves the due campaigns through the campaign state machine and
+// refreshes the same caches as the activate and stop endpoints, a campaign
+// that fails is picked up again on the next tick
+func (w *SchedulerWorker) schedule(ctx context.Context) {
+	now := time.Now()
+
+	starting, err := w.repo.CampaignInterace.GetCampaignsToStart(ctx, now)
+	if err != nil {
+		log.Printf("Failed to fetch the campaigns to start: %v", err)
+	}
+	for _, id := range starting {
+		err := w.repo.CampaignInterace.TransitionCampaign(ctx, db.CampaignTransition{
+			CampaignID: id,
+			To:         db.ActiveStatus,
+			ActorID:    db.CampaignSystemActor,
+			Reason:     "scheduled start",
+		})
+		if err != nil {
+			log.Printf("Failed to start campaign %s: %v", id, err)
+			continue
+		}
+		w.cache.AddActiveCampaign(ctx, id)
+		w.cache.InvalidateCampaign(ctx, id)
+		w.notifyBrand(ctx, id, "campaign:started")
+	}
+
+	ending, err := w.repo.CampaignInterace.GetCampaignsToEnd(ctx, now)
+	if err != nil {
+		log.Printf("Failed to fetch the campaigns to end: %v", err)
+	}
+	for _, id := range ending {
+		err := w.repo.CampaignInterace.TransitionCampaign(ctx, db.CampaignTransition{
+			CampaignID: id,
+			To:         db.ExpiredStatus,
+			ActorID:    db.CampaignSystemActor,
+			Reason:     "scheduled end",
+		})
+		if err != nil {
+			log.Printf("Failed to end campaign %s: %v", id, err)
+			continue
+		}
+		w.cache.RemoveEndedCampaign(ctx, id)
+		w.notifyBrand(ctx, id, "campaign:ended")
+	}
+
+	if len(starting) > 0 || len(ending) > 0 {
+		log.Printf("Scheduler started %d and ended %d campaigns", len(starting), len(ending))
+	}
+}
+
+func (w *SchedulerWorker) notifyBrand(ctx context.Context, campaignID, event string) {
+	if w.notifier == nil {
+		return
+	}
+	campaign, err := w.repo.CampaignInterace.GetCampaign(ctx, campaignID)
+	if err != nil {
+		log.Printf("Failed to notify the brand of campaign %s: %v", campaignID, err)
+		return
+	}
+	w.notifier.Notify(campaign.BrandId, map[string]any{
+		"type":        event,
+		"campaign_id": campaignID,
+	})
+}
+
+func (w *SchedulerWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stopChan) })
+}
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -70,6 +72,12 @@ func main() {
 		FactoryCfg: api.FactoryConfig{
 			YouTubeAPIKey: env.GetString("YTAPIKEY", ""),
 			MetaToken:     env.GetString("METAKEY", ""),
+			MetaUserID:    env.GetString("META_IG_USER_ID", ""),
+			TikTokKey:     env.GetString("TIKTOK_CLIENT_KEY", ""),
+			TikTokSecret:  env.GetString("TIKTOK_CLIENT_SECRET", ""),
+			YTDailyQuota:  env.GetInt("YT_DAILY_QUOTA", 10000),
+			YTQuotaReset:  env.GetString("YT_QUOTA_RESET", "00:00"),              // HH:MM
+			YTQuotaZone:   env.GetString("YT_QUOTA_ZONE", "America/Los_Angeles"), // Google resets at Pacific midnight
 		},
 		B2Cfg: api.B2Config{
 			KeyID:    env.GetString("B2SecretAccessKey", ""),
@@ -77,6 +85,9 @@ func main() {
 			Region:   env.GetString("B2Region", "us-east-001"),
 			Endpoint: env.GetString("B2Endpoint", ""),
 		},
+		WorkerCfg: api.WorkerConfig{
+			BudgetAlerts: env.GetString("BUDGET_ALERTS", "0.5,0.2,0.1"),
+		},
 	}
 
 	// Making DB connection
@@ -136,10 +147,11 @@ func main() {
 		log.Fatalf("error intialising the cache layer: %v\n", err.Error())
 	}
 	// initialising the Media Factory
-	factory, err := platform.NewFactory(
-		config.FactoryCfg.YouTubeAPIKey,
-		config.FactoryCfg.MetaToken,
-	)
+	factory, err := platform.NewFactory(map[string]string{
+		"youtube":   config.FactoryCfg.YouTubeAPIKey,
+		"instagram": config.FactoryCfg.MetaUserID + ":" + config.FactoryCfg.MetaToken,
+		"tiktok":    config.FactoryCfg.TikTokKey + ":" + config.FactoryCfg.TikTokSecret,
+	})
 	if err != nil {
 		log.Fatalf("error making media factory: %v\n", err.Error())
 	}
@@ -147,11 +159,14 @@ func main() {
 	// attaching the services to the application
 	appStore := db.NewStore(db_)
 	appCache := cache.NewService(CacheClient)
+	factory.UseQuota(appCache, "youtube", quotaConfig(config.FactoryCfg))
 	appHub := chats.NewHub(db_, appCache)
 	appWorker := workers.NewAppWorker(
 		appCache,
 		appStore,
 		factory,
+		appHub,
+		budgetAlerts(config.WorkerCfg),
 		BatchInterval,
 		PollInterval,
 	)
@@ -203,3 +218,37 @@ func main() {
 	// shutdown successful
 	log.Printf("Server Shutdown Successfully\n")
 }
+
+// builds the YouTube quota settings, falling back to midnight UTC on bad values
+func quotaConfig(cfg api.FactoryConfig) platform.QuotaConfig {
+	quota := platform.QuotaConfig{DailyBudget: int64(cfg.YTDailyQuota)}
+	if reset, err := time.Parse("15:04", cfg.YTQuotaReset); err == nil {
+		quota.ResetAt = time.Duration(reset.Hour())*time.Hour + time.Duration(reset.Minute())*time.Minute
+	} else {
+		log.Printf("invalid quota reset time %q: %v\n", cfg.YTQuotaReset, err)
+	}
+	if loc, err := time.LoadLocation(cfg.YTQuotaZone); err == nil {
+		quota.Location = loc
+	} else {
+		log.Printf("invalid quota zone %q: %v\n", cfg.YTQuotaZone, err)
+	}
+	return quota
+}
+
+// budgetAlerts parses the budget alert thresholds, invalid ones are skipped
+func budgetAlerts(cfg api.WorkerConfig) []float64 {
+	var alerts []float64
+	for _, field := range strings.Split(cfg.BudgetAlerts, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(field, 64)
+		if err != nil || threshold <= 0 || threshold >= 1 {
+			log.Printf("invalid budget alert %q\n", field)
+			continue
+		}
+		alerts = append(alerts, threshold)
+	}
+	return alerts
+}
@@ -5,77 +5,135 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
 	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// availability of a video on its platform, stored in submissions.video_status
+const (
+	VideoAvailable     = "available"
+	VideoUnlisted      = "unlisted"       // playable through the link only
+	VideoRegionBlocked = "region_blocked" // playable outside the blocked regions
+	VideoAgeRestricted = "age_restricted"
+	VideoPrivate       = "private"
+	VideoDeleted       = "deleted" // not found on the platform
 )
 
+// Unavailable reports if a video in this status stops earning.
+// Such videos are only rechecked until they come back.
+func Unavailable(status string) bool {
+	switch status {
+	case VideoAgeRestricted, VideoPrivate, VideoDeleted:
+		return true
+	}
+	return false
+}
+
 type Client interface {
 	GetVideoDetails(ctx context.Context, VideoID string) (*VideoMetadata, error)
 	GetVideoDetailsForWorkers(ctx context.Context, VideoID string) (*VideoMetadata, error)
+	// metadata of many videos keyed by video id, without thumbnails. A video
+	// known to be gone comes back with the VideoDeleted status, a video left
+	// out of the map is unknown and asked again later.
+	GetVideosDetails(ctx context.Context, VideoIDs []string) (map[string]*VideoMetadata, error)
 }
 
 type Factory struct {
-	youtube   Client
-	instagram Client
+	clients    map[string]Client        // platform -> API client
+	limiters   map[string]*rate.Limiter // platform -> request rate limit
+	quotaStore QuotaStore
+	quotas     map[string]QuotaConfig // platform -> daily quota
 }
 
 type Platform struct {
 	Name    string
 	VideoID string
+	URL     string // canonical URL of the video
 }
 
-func NewFactory(youtubeAPIKey, instagramToken string) (*Factory, error) {
-	return &Factory{
-		youtube:   NewYTClient(youtubeAPIKey),
-		instagram: NewInstagramClient(instagramToken),
-	}, nil
+// NewFactory builds the client of every registered platform from its
+// credential, keyed by platform name
+func NewFactory(credentials map[string]string) (*Factory, error) {
+	f := &Factory{
+		clients:  make(map[string]Client),
+		limiters: make(map[string]*rate.Limiter),
+		quotas:   make(map[string]QuotaConfig),
+	}
+	for _, adapter := range Adapters() {
+		f.clients[adapter.Name] = adapter.NewClient(credentials[adapter.Name])
+		if perMinute := adapter.Capabilities.RateLimit; perMinute > 0 {
+			f.limiters[adapter.Name] = rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+		}
+	}
+	return f, nil
 }
 
 func (f *Factory) GetClient(platform string) (Client, error) {
-	switch platform {
-	case "youtube":
-		return f.youtube, nil
-	case "instagram":
-		return f.instagram, nil
-	default:
+	client, ok := f.clients[platform]
+	if !ok {
 		return nil, fmt.Errorf("unsupported platform: %s", platform)
 	}
+	return client, nil
 }
 
-func (f *Factory) GetVideoDetails(ctx context.Context, platform, VideoID string) (*VideoMetadata, error) {
+// prepare returns the client once the platform quota and rate limit allow
+// the given number of calls
+func (f *Factory) prepare(ctx context.Context, platform string, calls int) (Client, error) {
 	client, err := f.GetClient(platform)
 	if err != nil {
 		return nil, err
 	}
+	if err := f.spend(ctx, platform, calls); err != nil {
+		return nil, err
+	}
+	if limiter, ok := f.limiters[platform]; ok {
+		if err := limiter.WaitN(ctx, min(calls, limiter.Burst())); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func (f *Factory) GetVideoDetails(ctx context.Context, platform, VideoID string) (*VideoMetadata, error) {
+	client, err := f.prepare(ctx, platform, 1)
+	if err != nil {
+		return nil, err
+	}
 	return client.GetVideoDetails(ctx, VideoID)
 }
 
 func (f *Factory) GetVideoDetailsForWorkers(ctx context.Context, platform, VideoID string) (*VideoMetadata, error) {
-	client, err := f.GetClient(platform)
+	client, err := f.prepare(ctx, platform, 1)
 	if err != nil {
 		return nil, err
 	}
 	return client.GetVideoDetailsForWorkers(ctx, VideoID)
 }
 
-// ParseVideoURL extracts platform and video ID from URL
-func ParseVideoURL(url string) (*Platform, error) {
-	// YouTube (Primary)
-	youtubeRegex := regexp.MustCompile(`(?:youtube\.com/(?:watch\?v=|shorts/)|youtu\.be/)([a-zA-Z0-9_-]{11})`)
-	if match := youtubeRegex.FindStringSubmatch(url); match != nil {
-		return &Platform{Name: "youtube", VideoID: match[1]}, nil
-	}
-
-	// Instagram
-	instagramRegex := regexp.MustCompile(`instagram\.com\/(?:p|reel)\/([a-zA-Z0-9_-]+)`)
-	if match := instagramRegex.FindStringSubmatch(url); match != nil {
-		return &Platform{Name: "instagram", VideoID: match[1]}, nil
+func (f *Factory) GetVideosDetails(ctx context.Context, platform string, VideoIDs []string) (map[string]*VideoMetadata, error) {
+	client, err := f.prepare(ctx, platform, BatchCalls(platform, len(VideoIDs)))
+	if err != nil {
+		return nil, err
 	}
+	return client.GetVideosDetails(ctx, VideoIDs)
+}
 
-	// TikTok
-	tiktokRegex := regexp.MustCompile(`tiktok\.com\/@[\w.-]+\/video\/(\d+)`)
-	if match := tiktokRegex.FindStringSubmatch(url); match != nil {
-		return &Platform{Name: "tiktok", VideoID: match[1]}, nil
+// ParseVideoURL extracts platform and video ID from URL using the matchers of
+// the registered platforms
+func ParseVideoURL(url string) (*Platform, error) {
+	for _, adapter := range Adapters() {
+		for _, matcher := range adapter.Matchers {
+			match := matcher.FindStringSubmatch(url)
+			if match == nil {
+				continue
+			}
+			parsed := &Platform{Name: adapter.Name, VideoID: match[1], URL: url}
+			if adapter.CanonicalURL != nil {
+				parsed.URL = adapter.CanonicalURL(match[1])
+			}
+			return parsed, nil
+		}
 	}
 
 	return nil, fmt.Errorf("unsupported URL format: %s", url)
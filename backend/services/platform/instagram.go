@@ -2,29 +2,301 @@ package platform
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// reels polled per batch
+const IGMaxBatchSize = 50
+
+const igBaseURL = "https://graph.facebook.com/v23.0"
+
+// Graph API error code of an expired or revoked access token
+const igTokenErrorCode = 190
+
+// media pages of an account searched for a reel, newest first
+const (
+	igDiscoveryPages    = 4
+	igDiscoveryPageSize = 50
+)
+
+var (
+	ErrTokenExpired    = errors.New("platform access token expired")
+	ErrIGMediaNotFound = errors.New("instagram media not found")
+)
+
+var (
+	igShortcode = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	igUsername  = regexp.MustCompile(`^[a-zA-Z0-9._]{1,30}$`)
 )
 
 type Instagram struct {
+	userID     string // professional account making the business discovery calls
 	token      string
+	baseURL    string
 	httpClient *http.Client
+	authors    sync.Map // shortcode -> username of the account that posted it
+}
+
+type IGMedia struct {
+	Id           string `json:"id"`
+	Permalink    string `json:"permalink"`
+	Caption      string `json:"caption"`
+	LikeCount    int    `json:"like_count"`
+	ViewCount    int    `json:"view_count"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	MediaURL     string `json:"media_url"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// recent media of an account found through business discovery
+type IGDiscovery struct {
+	BusinessDiscovery struct {
+		Media struct {
+			Data   []IGMedia `json:"data"`
+			Paging struct {
+				Cursors struct {
+					After string `json:"after"`
+				} `json:"cursors"`
+			} `json:"paging"`
+		} `json:"media"`
+	} `json:"business_discovery"`
+}
+
+type IGError struct {
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// fields asked for every reel. Media insights only answer to the token of the
+// account owning the media, the counts of creators come from business discovery.
+const igMediaFields = "id,permalink,caption,like_count,view_count,thumbnail_url,media_url,timestamp"
+
+func init() {
+	Register(&Adapter{
+		Name: "instagram",
+		Matchers: []*regexp.Regexp{
+			regexp.MustCompile(`instagram\.com\/(?:p|reel)\/([a-zA-Z0-9_-]+)`),
+		},
+		CanonicalURL: func(VideoID string) string {
+			return "https://www.instagram.com/reel/" + VideoID + "/"
+		},
+		// the credential is "<ig user id>:<access token>"
+		NewClient: func(credential string) Client {
+			userID, token, _ := strings.Cut(credential, ":")
+			return NewInstagramClient(userID, token)
+		},
+		Capabilities: Capabilities{
+			BatchSize:  IGMaxBatchSize,
+			Thumbnails: true,
+			RateLimit:  3, // about 200 calls per hour
+		},
+	})
 }
 
 // consttructor for Instagram Client
-func NewInstagramClient(token string) *Instagram {
+func NewInstagramClient(userID, token string) *Instagram {
 	return &Instagram{
+		userID:     userID,
 		token:      token,
+		baseURL:    igBaseURL,
 		httpClient: &http.Client{},
 	}
 }
 
-// It was too much of hedaache using the Meta API
-// Implement it later IF YOU WANT
-// Returns the View Count of an Instagram Reel
+// igAuthor finds the account that posted a reel from its shortcode through
+// oEmbed. Authors are kept since they never change.
+func (i *Instagram) igAuthor(ctx context.Context, shortcode string) (string, error) {
+	if !igShortcode.MatchString(shortcode) {
+		return "", fmt.Errorf("invalid video id")
+	}
+	if author, ok := i.authors.Load(shortcode); ok {
+		return author.(string), nil
+	}
+	var embed struct {
+		AuthorName string `json:"author_name"`
+	}
+	query := url.Values{"url": {"https://www.instagram.com/reel/" + shortcode + "/"}, "fields": {"author_name"}}
+	if err := i.get(ctx, "/instagram_oembed", query, &embed); err != nil {
+		return "", err
+	}
+	if !igUsername.MatchString(embed.AuthorName) {
+		return "", ErrIGMediaNotFound
+	}
+	i.authors.Store(shortcode, embed.AuthorName)
+	return embed.AuthorName, nil
+}
+
+// discoverMedia walks the recent media of an account through business
+// discovery until every wanted shortcode shows up. Reels not found within
+// igDiscoveryPages are left out.
+func (i *Instagram) discoverMedia(ctx context.Context, author string, shortcodes []string) (map[string]IGMedia, error) {
+	wanted := make(map[string]bool, len(shortcodes))
+	for _, shortcode := range shortcodes {
+		wanted[shortcode] = true
+	}
+	found := make(map[string]IGMedia, len(shortcodes))
+	var after string
+	for range igDiscoveryPages {
+		var page IGDiscovery
+		fields := fmt.Sprintf("business_discovery.username(%s){media%s.limit(%d){%s}}",
+			author, after, igDiscoveryPageSize, igMediaFields)
+		if err := i.get(ctx, "/"+i.userID, url.Values{"fields": {fields}}, &page); err != nil {
+			return nil, err
+		}
+		media := page.BusinessDiscovery.Media
+		for _, item := range media.Data {
+			// permalinks look like https://www.instagram.com/reel/<shortcode>/
+			shortcode := path.Base(strings.TrimSuffix(item.Permalink, "/"))
+			if wanted[shortcode] {
+				found[shortcode] = item
+			}
+		}
+		if len(found) == len(wanted) || media.Paging.Cursors.After == "" || len(media.Data) == 0 {
+			break
+		}
+		after = fmt.Sprintf(".after(%s)", media.Paging.Cursors.After)
+	}
+	return found, nil
+}
+
+// get calls the Graph API and decodes the response into dest
+func (i *Instagram) get(ctx context.Context, path string, query url.Values, dest any) error {
+	query.Set("access_token", i.token)
+	req, err := http.NewRequestWithContext(ctx, "GET", i.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		log.Printf("error: %v", err.Error())
+		return err
+	}
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		log.Printf("error: %v", err.Error())
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr IGError
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != nil {
+			if apiErr.Error.Code == igTokenErrorCode {
+				log.Printf("error: instagram token rejected: %s", apiErr.Error.Message)
+				return ErrTokenExpired
+			}
+			return fmt.Errorf("instagram api returned status %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return fmt.Errorf("instagram api returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+// maps a reel to the platform metadata
+func (m *IGMedia) metadata(shortcode string) *VideoMetadata {
+	return &VideoMetadata{
+		VideoID:    shortcode,
+		Platform:   "instagram",
+		Title:      m.Caption,
+		ViewCount:  m.ViewCount,
+		LikeCount:  m.LikeCount,
+		UploadedAt: m.Timestamp,
+		Status:     VideoAvailable,
+	}
+}
+
+func (i *Instagram) fetchMedia(ctx context.Context, VideoID string) (*IGMedia, error) {
+	author, err := i.igAuthor(ctx, VideoID)
+	if err != nil {
+		log.Printf("error: resolving reel %q: %v\n", VideoID, err)
+		return nil, err
+	}
+	found, err := i.discoverMedia(ctx, author, []string{VideoID})
+	if err != nil {
+		return nil, err
+	}
+	media, ok := found[VideoID]
+	if !ok {
+		return nil, ErrIGMediaNotFound
+	}
+	return &media, nil
+}
+
+// Returns the metadata of an Instagram Reel with its thumbnail
 func (i *Instagram) GetVideoDetails(ctx context.Context, VideoID string) (*VideoMetadata, error) {
-	return nil, nil
+	media, err := i.fetchMedia(ctx, VideoID)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := media.metadata(VideoID)
+	thumbURL := media.ThumbnailURL
+	if thumbURL == "" {
+		// image posts carry no separate thumbnail
+		thumbURL = media.MediaURL
+	}
+	raw, contentType, err := DownloadFile(thumbURL)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Thumbnails = Thumbnail{
+		Raw:         raw,
+		ContentType: contentType,
+	}
+	return metadata, nil
 }
 
 func (i *Instagram) GetVideoDetailsForWorkers(ctx context.Context, VideoID string) (*VideoMetadata, error) {
-	return nil, nil
+	media, err := i.fetchMedia(ctx, VideoID)
+	if err != nil {
+		return nil, err
+	}
+	return media.metadata(VideoID), nil
+}
+
+// GetVideosDetails fetches many reels, walking the media of each author once.
+// Reels that cannot be resolved or read are left out of the map, only an
+// expired token fails the call.
+func (i *Instagram) GetVideosDetails(ctx context.Context, VideoIDs []string) (map[string]*VideoMetadata, error) {
+	output := make(map[string]*VideoMetadata, len(VideoIDs))
+
+	// author -> shortcodes of their reels
+	byAuthor := make(map[string][]string)
+	for _, shortcode := range VideoIDs {
+		author, err := i.igAuthor(ctx, shortcode)
+		if errors.Is(err, ErrTokenExpired) {
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("error: resolving reel %q: %v\n", shortcode, err)
+			continue
+		}
+		byAuthor[author] = append(byAuthor[author], shortcode)
+	}
+
+	for author, shortcodes := range byAuthor {
+		found, err := i.discoverMedia(ctx, author, shortcodes)
+		if errors.Is(err, ErrTokenExpired) {
+			return nil, err
+		}
+		if err != nil {
+			// e.g. the author switched to a personal account
+			log.Printf("error: reading reels of %q: %v\n", author, err)
+			continue
+		}
+		for shortcode, media := range found {
+			output[shortcode] = media.metadata(shortcode)
+		}
+	}
+
+	return output, nil
 }
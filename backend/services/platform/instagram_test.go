@@ -0,0 +1,164 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Graph API stand-in serving the reels of one creator. Cgone was deleted and
+// Cbad was posted by an account business discovery cannot read. Like the real
+// API, reading a media id the app user does not own is refused.
+func mockGraphAPI(embeds, mediaReads *atomic.Int32) *httptest.Server {
+	var server *httptest.Server
+	// business discovery pages of the creator
+	page := func(shortcodes []string, after string) IGDiscovery {
+		var out IGDiscovery
+		media := &out.BusinessDiscovery.Media
+		for _, shortcode := range shortcodes {
+			media.Data = append(media.Data, IGMedia{
+				Id:           "m_" + shortcode,
+				Permalink:    "https://www.instagram.com/reel/" + shortcode + "/",
+				Caption:      "reel " + shortcode,
+				LikeCount:    42,
+				ViewCount:    1500,
+				ThumbnailURL: server.URL + "/thumb.jpg",
+				Timestamp:    "2026-01-02T10:00:00+0000",
+			})
+		}
+		media.Paging.Cursors.After = after
+		return out
+	}
+	failed := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"Unsupported get request","type":"GraphMethodException","code":100}}`))
+	}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case r.URL.Path == "/thumb.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("jpeg"))
+		case query.Get("access_token") != "valid":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"Error validating access token","type":"OAuthException","code":190}}`))
+		case r.URL.Path == "/instagram_oembed":
+			embeds.Add(1)
+			switch {
+			case strings.Contains(query.Get("url"), "/Cgone/"):
+				failed(w)
+			case strings.Contains(query.Get("url"), "/Cbad/"):
+				w.Write([]byte(`{"author_name":"personal"}`))
+			default:
+				w.Write([]byte(`{"author_name":"creator"}`))
+			}
+		case r.URL.Path == "/app_user":
+			fields := query.Get("fields")
+			if !strings.Contains(fields, "username(creator)") ||
+				!strings.Contains(fields, "view_count") || strings.Contains(fields, "insights") {
+				failed(w)
+				return
+			}
+			if strings.Contains(fields, ".after(p2)") {
+				json.NewEncoder(w).Encode(page([]string{"C3abcDEF"}, ""))
+				return
+			}
+			json.NewEncoder(w).Encode(page([]string{"C1abcDEF", "C2abcDEF"}, "p2"))
+		default:
+			// media of other accounts, with or without insights
+			mediaReads.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"(#10) Application does not have permission for this action","type":"OAuthException","code":10,"fbtrace_id":"AbCdEf"}}`))
+		}
+	}))
+	return server
+}
+
+func TestInstagram(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var embeds, mediaReads atomic.Int32
+	server := mockGraphAPI(&embeds, &mediaReads)
+	defer server.Close()
+
+	client := NewInstagramClient("app_user", "valid")
+	client.baseURL = server.URL
+
+	t.Run("reel details", func(t *testing.T) {
+		video, err := client.GetVideoDetails(ctx, "C1abcDEF")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if video.VideoID != "C1abcDEF" || video.Platform != "instagram" ||
+			video.ViewCount != 1500 || video.LikeCount != 42 ||
+			!strings.HasPrefix(video.Title, "reel ") ||
+			string(video.Thumbnails.Raw) != "jpeg" {
+			t.Fail()
+		}
+	})
+	t.Run("reel lookup", func(t *testing.T) {
+		// older reels are on the next pages of the account
+		media, err := client.fetchMedia(ctx, "C3abcDEF")
+		if err != nil || media.Id != "m_C3abcDEF" || media.ViewCount != 1500 {
+			t.Fail()
+		}
+		// authors are not asked again
+		calls := embeds.Load()
+		if _, err := client.fetchMedia(ctx, "C3abcDEF"); err != nil || embeds.Load() != calls {
+			t.Fail()
+		}
+		if _, err := client.fetchMedia(ctx, "Cnotposted"); !errors.Is(err, ErrIGMediaNotFound) {
+			t.Fail()
+		}
+		if _, err := client.fetchMedia(ctx, "bad*code"); err == nil {
+			t.Fail()
+		}
+	})
+	t.Run("batch", func(t *testing.T) {
+		videos, err := client.GetVideosDetails(ctx, []string{"C1abcDEF", "C2abcDEF"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) != 2 || videos["C2abcDEF"].ViewCount != 1500 {
+			t.Fail()
+		}
+	})
+	t.Run("bad reels are left out of the batch", func(t *testing.T) {
+		videos, err := client.GetVideosDetails(ctx, []string{"C1abcDEF", "Cgone", "Cbad", "C2abcDEF"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) != 2 || videos["C1abcDEF"] == nil || videos["C2abcDEF"] == nil {
+			t.Fail()
+		}
+	})
+	t.Run("media of creators is never read directly", func(t *testing.T) {
+		if mediaReads.Load() != 0 {
+			t.Fatalf("%d reads hit the media endpoint", mediaReads.Load())
+		}
+		// which is refused for a media id the app user does not own
+		var media IGMedia
+		err := client.get(ctx, "/m_C1abcDEF", url.Values{"fields": {"id,view_count"}}, &media)
+		if err == nil || !strings.Contains(err.Error(), "does not have permission") {
+			t.Fail()
+		}
+	})
+	t.Run("expired token", func(t *testing.T) {
+		expired := NewInstagramClient("app_user", "expired")
+		expired.baseURL = server.URL
+		if _, err := expired.GetVideoDetailsForWorkers(ctx, "C1abcDEF"); err != ErrTokenExpired {
+			t.Fail()
+		}
+		if _, err := expired.GetVideosDetails(ctx, []string{"C1abcDEF"}); err != ErrTokenExpired {
+			t.Fail()
+		}
+	})
+}
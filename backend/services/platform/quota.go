@@ -0,0 +1,150 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+var ErrQuotaExhausted = errors.New("api quota exhausted")
+
+// below this share of the daily budget the quota counts as low
+const QuotaLowRatio = 0.2
+
+// QuotaStore keeps the API units spent per platform, quota day and API method
+type QuotaStore interface {
+	// ReserveQuota charges the units unless the day's total would go over
+	// the budget, atomically across the pollers
+	ReserveQuota(ctx context.Context, platform, day, method string, units, budget int64) (bool, error)
+	GetQuotaUsage(ctx context.Context, platform, day string) (map[string]int64, error)
+}
+
+type QuotaConfig struct {
+	DailyBudget int64          // units per quota day
+	ResetAt     time.Duration  // time of the day the quota resets
+	Location    *time.Location // zone of the reset time (UTC if nil)
+}
+
+type QuotaReport struct {
+	Platform  string           `json:"platform"`
+	Budget    int64            `json:"budget"`
+	Spent     int64            `json:"spent"`
+	Remaining int64            `json:"remaining"`
+	ByMethod  map[string]int64 `json:"by_method"`
+	ResetsAt  time.Time        `json:"resets_at"`
+}
+
+// Low reports if the remaining units are close to running out
+func (r *QuotaReport) Low() bool {
+	return float64(r.Remaining) < float64(r.Budget)*QuotaLowRatio
+}
+
+// UseQuota turns on the quota accounting of a platform
+func (f *Factory) UseQuota(store QuotaStore, platform string, cfg QuotaConfig) {
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	f.quotaStore = store
+	f.quotas[platform] = cfg
+}
+
+// quotaDay names the quota day running at now and returns when it ends
+func quotaDay(cfg QuotaConfig, now time.Time) (string, time.Time) {
+	shifted := now.In(cfg.Location).Add(-cfg.ResetAt)
+	start := time.Date(shifted.Year(), shifted.Month(), shifted.Day(), 0, 0, 0, 0, cfg.Location)
+	return start.Format("2006-01-02"), start.AddDate(0, 0, 1).Add(cfg.ResetAt)
+}
+
+// spend charges the units of calls to the platform's lookup method.
+// It refuses with ErrQuotaExhausted when the budget cannot cover them.
+func (f *Factory) spend(ctx context.Context, platform string, calls int) error {
+	cfg, ok := f.quotas[platform]
+	caps := CapabilitiesOf(platform)
+	if !ok || caps.QuotaMethod == "" || f.quotaStore == nil {
+		return nil
+	}
+	day, _ := quotaDay(cfg, time.Now())
+	ok, err := f.quotaStore.ReserveQuota(ctx, platform, day, caps.QuotaMethod,
+		max(caps.QuotaCost, 1)*int64(calls), cfg.DailyBudget)
+	if err != nil {
+		// do not stop the lookups when the counter is unavailable
+		log.Printf("error recording %s quota: %v\n", platform, err)
+		return nil
+	}
+	if !ok {
+		return ErrQuotaExhausted
+	}
+	return nil
+}
+
+// QuotaStatus reports the quota of a platform, nil if it is not accounted
+func (f *Factory) QuotaStatus(ctx context.Context, platform string) (*QuotaReport, error) {
+	cfg, ok := f.quotas[platform]
+	if !ok || f.quotaStore == nil {
+		return nil, nil
+	}
+	day, resetsAt := quotaDay(cfg, time.Now())
+	usage, err := f.quotaStore.GetQuotaUsage(ctx, platform, day)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QuotaReport{
+		Platform: platform,
+		Budget:   cfg.DailyBudget,
+		ByMethod: usage,
+		ResetsAt: resetsAt,
+	}
+	for _, units := range usage {
+		report.Spent += units
+	}
+	report.Remaining = max(report.Budget-report.Spent, 0)
+	return report, nil
+}
+
+// QuotaReports reports the quota of every accounted platform
+func (f *Factory) QuotaReports(ctx context.Context) ([]QuotaReport, error) {
+	platforms := make([]string, 0, len(f.quotas))
+	for platform := range f.quotas {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	output := make([]QuotaReport, 0, len(platforms))
+	for _, platform := range platforms {
+		report, err := f.QuotaStatus(ctx, platform)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, *report)
+	}
+	return output, nil
+}
+
+// QuotaLow reports if the platform quota is close to running out
+func (f *Factory) QuotaLow(ctx context.Context, platform string) bool {
+	report, err := f.QuotaStatus(ctx, platform)
+	if err != nil || report == nil {
+		return false
+	}
+	return report.Low()
+}
+
+// BatchCalls returns the number of API calls needed to look up n videos
+func BatchCalls(platform string, n int) int {
+	size := CapabilitiesOf(platform).BatchSize
+	return (n + size - 1) / size
+}
+
+// BatchUnits returns the quota units spent to look up n videos
+func BatchUnits(platform string, n int) int64 {
+	return int64(BatchCalls(platform, n)) * max(CapabilitiesOf(platform).QuotaCost, 1)
+}
+
+// AffordableVideos returns how many videos the units can still look up
+func AffordableVideos(platform string, units int64) int {
+	caps := CapabilitiesOf(platform)
+	return int(units/max(caps.QuotaCost, 1)) * caps.BatchSize
+}
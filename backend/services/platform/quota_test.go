@@ -0,0 +1,78 @@
+package platform
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// in memory QuotaStore
+type memQuota map[string]map[string]int64
+
+func (m memQuota) ReserveQuota(ctx context.Context, platform, day, method string, units, budget int64) (bool, error) {
+	key := platform + ":" + day
+	var spent int64
+	for _, used := range m[key] {
+		spent += used
+	}
+	if spent+units > budget {
+		return false, nil
+	}
+	if m[key] == nil {
+		m[key] = make(map[string]int64)
+	}
+	m[key][method] += units
+	return true, nil
+}
+
+func (m memQuota) GetQuotaUsage(ctx context.Context, platform, day string) (map[string]int64, error) {
+	return m[platform+":"+day], nil
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	factory, _ := NewFactory(nil)
+	factory.UseQuota(memQuota{}, "youtube", QuotaConfig{DailyBudget: 10})
+
+	t.Run("spends per call", func(t *testing.T) {
+		// 120 ids take 3 calls of videos.list
+		if err := factory.spend(ctx, "youtube", BatchCalls("youtube", 120)); err != nil {
+			t.Fatal(err)
+		}
+		report, err := factory.QuotaStatus(ctx, "youtube")
+		if err != nil || report.Spent != 3 || report.Remaining != 7 || report.ByMethod["videos.list"] != 3 {
+			t.Fail()
+		}
+	})
+	t.Run("low and exhausted", func(t *testing.T) {
+		if factory.QuotaLow(ctx, "youtube") {
+			t.Fail()
+		}
+		factory.spend(ctx, "youtube", 6)
+		if !factory.QuotaLow(ctx, "youtube") {
+			t.Fail()
+		}
+		if err := factory.spend(ctx, "youtube", 2); err != ErrQuotaExhausted {
+			t.Fail()
+		}
+	})
+	t.Run("platform without quota", func(t *testing.T) {
+		if err := factory.spend(ctx, "instagram", 100); err != nil {
+			t.Fail()
+		}
+		if report, _ := factory.QuotaStatus(ctx, "instagram"); report != nil {
+			t.Fail()
+		}
+	})
+	t.Run("quota day", func(t *testing.T) {
+		cfg := QuotaConfig{ResetAt: 8 * time.Hour, Location: time.UTC}
+		day, resetsAt := quotaDay(cfg, time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC))
+		if day != "2026-03-09" || !resetsAt.Equal(time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)) {
+			t.Fail()
+		}
+		day, _ = quotaDay(cfg, time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC))
+		if day != "2026-03-10" {
+			t.Fail()
+		}
+	})
+}
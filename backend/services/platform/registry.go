@@ -0,0 +1,85 @@
+package platform
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Capabilities tell the callers how a platform can be used
+type Capabilities struct {
+	BatchSize   int    // video ids per GetVideosDetails call, 1 if it cannot batch
+	Thumbnails  bool   // GetVideoDetails returns a thumbnail
+	RateLimit   int    // requests per minute, 0 for no limit
+	QuotaMethod string // API method charged against the daily quota
+	QuotaCost   int64  // quota units per call of QuotaMethod
+}
+
+// SupportsBatching reports if many videos can be fetched in one call
+func (c Capabilities) SupportsBatching() bool {
+	return c.BatchSize > 1
+}
+
+// Adapter plugs a video platform into the factory.
+// Each platform registers its adapter from an init function.
+type Adapter struct {
+	Name string
+	// patterns of the video URLs, the first group captures the video id
+	Matchers []*regexp.Regexp
+	// CanonicalURL rebuilds the preferred URL of a video from its id
+	CanonicalURL func(VideoID string) string
+	// NewClient builds the API client from the platform credential
+	NewClient    func(credential string) Client
+	Capabilities Capabilities
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Adapter)
+	adapters   []*Adapter // registration order, URLs are matched in this order
+)
+
+// Register adds a platform adapter. It panics if the name is taken,
+// like database/sql.Register.
+func Register(adapter *Adapter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if adapter == nil || adapter.NewClient == nil {
+		panic("platform: Register adapter is nil")
+	}
+	if _, dup := registry[adapter.Name]; dup {
+		panic("platform: Register called twice for " + adapter.Name)
+	}
+	if adapter.Capabilities.BatchSize < 1 {
+		adapter.Capabilities.BatchSize = 1
+	}
+	registry[adapter.Name] = adapter
+	adapters = append(adapters, adapter)
+}
+
+// Lookup returns the adapter of a platform
+func Lookup(name string) (*Adapter, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	adapter, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported platform: %s", name)
+	}
+	return adapter, nil
+}
+
+// Adapters lists the registered adapters in registration order
+func Adapters() []*Adapter {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]*Adapter(nil), adapters...)
+}
+
+// CapabilitiesOf returns the capabilities of a platform, the zero value if unknown
+func CapabilitiesOf(name string) Capabilities {
+	adapter, err := Lookup(name)
+	if err != nil {
+		return Capabilities{BatchSize: 1}
+	}
+	return adapter.Capabilities
+}
@@ -0,0 +1,75 @@
+package platform
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+// client of a platform plugged in by the test only
+type fakeClient struct{ credential string }
+
+func (f *fakeClient) GetVideoDetails(ctx context.Context, VideoID string) (*VideoMetadata, error) {
+	return &VideoMetadata{VideoID: VideoID, Platform: "fake"}, nil
+}
+
+func (f *fakeClient) GetVideoDetailsForWorkers(ctx context.Context, VideoID string) (*VideoMetadata, error) {
+	return f.GetVideoDetails(ctx, VideoID)
+}
+
+func (f *fakeClient) GetVideosDetails(ctx context.Context, VideoIDs []string) (map[string]*VideoMetadata, error) {
+	output := make(map[string]*VideoMetadata, len(VideoIDs))
+	for _, id := range VideoIDs {
+		output[id], _ = f.GetVideoDetails(ctx, id)
+	}
+	return output, nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register(&Adapter{
+		Name:     "fake",
+		Matchers: []*regexp.Regexp{regexp.MustCompile(`fake\.tv/clip/(\w+)`)},
+		CanonicalURL: func(VideoID string) string {
+			return "https://fake.tv/clip/" + VideoID
+		},
+		NewClient: func(credential string) Client { return &fakeClient{credential} },
+	})
+
+	t.Run("parses registered urls", func(t *testing.T) {
+		parsed, err := ParseVideoURL("http://www.fake.tv/clip/abc123?ref=share")
+		if err != nil || parsed.Name != "fake" || parsed.VideoID != "abc123" || parsed.URL != "https://fake.tv/clip/abc123" {
+			t.Fail()
+		}
+		parsed, err = ParseVideoURL("https://youtu.be/1234567FrDE")
+		if err != nil || parsed.URL != "https://www.youtube.com/watch?v=1234567FrDE" {
+			t.Fail()
+		}
+	})
+	t.Run("factory builds the client", func(t *testing.T) {
+		factory, _ := NewFactory(map[string]string{"fake": "secret"})
+		client, err := factory.GetClient("fake")
+		if err != nil || client.(*fakeClient).credential != "secret" {
+			t.Fail()
+		}
+		videos, err := factory.GetVideosDetails(context.Background(), "fake", []string{"a", "b"})
+		if err != nil || len(videos) != 2 {
+			t.Fail()
+		}
+	})
+	t.Run("capabilities", func(t *testing.T) {
+		if CapabilitiesOf("fake").SupportsBatching() || !CapabilitiesOf("youtube").SupportsBatching() {
+			t.Fail()
+		}
+		if _, err := Lookup("vimeo"); err == nil {
+			t.Fail()
+		}
+	})
+	t.Run("duplicate name panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fail()
+			}
+		}()
+		Register(&Adapter{Name: "fake", NewClient: func(string) Client { return &fakeClient{} }})
+	})
+}
@@ -0,0 +1,335 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ids asked in one research query
+const TikTokMaxBatchSize = 20
+
+const tiktokBaseURL = "https://open.tiktokapis.com/v2"
+
+// fields asked for every video
+const tiktokVideoFields = "id,video_description,cover_image_url,view_count,like_count,share_count,create_time"
+
+// a research query spans at most 30 days of create dates, a day is kept on
+// each side for the time zone of TikTok
+const tiktokQueryDays = 28
+
+// error codes of an expired or revoked access token
+var tiktokTokenErrors = map[string]bool{
+	"access_token_invalid": true,
+	"scope_not_authorized": true,
+}
+
+type TikTok struct {
+	clientKey    string
+	clientSecret string
+	baseURL      string
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+type TikTokVideo struct {
+	Id               string `json:"id"`
+	VideoDescription string `json:"video_description"`
+	CoverImageURL    string `json:"cover_image_url"`
+	ViewCount        int    `json:"view_count"`
+	LikeCount        int    `json:"like_count"`
+	ShareCount       int    `json:"share_count"`
+	CreateTime       int64  `json:"create_time"` // unix seconds
+}
+
+type TikTokResponse struct {
+	Data struct {
+		Videos []TikTokVideo `json:"videos"`
+	} `json:"data"`
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type TikTokToken struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int    `json:"expires_in"` // seconds
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func init() {
+	Register(&Adapter{
+		Name: "tiktok",
+		Matchers: []*regexp.Regexp{
+			regexp.MustCompile(`tiktok\.com\/@[\w.-]+\/video\/(\d+)`),
+		},
+		// the canonical URL needs the author handle, the submitted one is kept.
+		// The credential is "<client key>:<client secret>" of a Research API app
+		NewClient: func(credential string) Client {
+			clientKey, clientSecret, _ := strings.Cut(credential, ":")
+			return NewTikTokClient(clientKey, clientSecret)
+		},
+		Capabilities: Capabilities{
+			BatchSize:  TikTokMaxBatchSize,
+			Thumbnails: true,
+			RateLimit:  600, // 600 calls per minute
+		},
+	})
+}
+
+// constructor for the TikTok Client
+func NewTikTokClient(clientKey, clientSecret string) *TikTok {
+	return &TikTok{
+		clientKey:    clientKey,
+		clientSecret: clientSecret,
+		baseURL:      tiktokBaseURL,
+		httpClient:   &http.Client{},
+	}
+}
+
+// accessToken returns the client token of the app, asking a new one once
+// the last is about to expire
+func (tk *TikTok) accessToken(ctx context.Context) (string, error) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	if tk.token != "" && time.Now().Before(tk.expiresAt) {
+		return tk.token, nil
+	}
+
+	form := url.Values{
+		"client_key":    {tk.clientKey},
+		"client_secret": {tk.clientSecret},
+		"grant_type":    {"client_credentials"},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", tk.baseURL+"/oauth/token/", strings.NewReader(form.Encode()))
+	if err != nil {
+		log.Printf("error: %v", err.Error())
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := tk.httpClient.Do(req)
+	if err != nil {
+		log.Printf("error: %v", err.Error())
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var data TikTokToken
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", fmt.Errorf("tiktok token returned status %d", resp.StatusCode)
+	}
+	if data.Error != "" || data.AccessToken == "" {
+		log.Printf("error: tiktok client credentials rejected: %s", data.ErrorDescription)
+		return "", ErrTokenExpired
+	}
+	tk.token = data.AccessToken
+	// renewed a minute early so no query carries a token expiring midway
+	tk.expiresAt = time.Now().Add(time.Duration(data.ExpiresIn)*time.Second - time.Minute)
+	return tk.token, nil
+}
+
+// drops the cached token after the API rejected it
+func (tk *TikTok) resetToken() {
+	tk.mu.Lock()
+	tk.token = ""
+	tk.mu.Unlock()
+}
+
+// tiktokCreated reads the creation day of a video from its id, the upper 32
+// bits of a TikTok id are the unix time it was posted
+func tiktokCreated(VideoID string) (time.Time, error) {
+	id, err := strconv.ParseUint(VideoID, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid video id")
+	}
+	created := time.Unix(int64(id>>32), 0).UTC()
+	return created.Truncate(24 * time.Hour), nil
+}
+
+// queryVideos asks the Research API for up to 20 videos posted within
+// tiktokQueryDays of each other
+func (tk *TikTok) queryVideos(ctx context.Context, VideoIDs []string, from, to time.Time) ([]TikTokVideo, error) {
+	body, err := json.Marshal(map[string]any{
+		"query": map[string]any{
+			"and": []map[string]any{{
+				"operation":    "IN",
+				"field_name":   "video_id",
+				"field_values": VideoIDs,
+			}},
+		},
+		"start_date": from.AddDate(0, 0, -1).Format("20060102"),
+		"end_date":   to.AddDate(0, 0, 1).Format("20060102"),
+		"max_count":  len(VideoIDs),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, status, err := tk.research(ctx, body)
+	if err == nil && tiktokTokenErrors[data.Error.Code] {
+		// the cached token was revoked early, ask a new one once
+		tk.resetToken()
+		data, status, err = tk.research(ctx, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tiktokTokenErrors[data.Error.Code] {
+		tk.resetToken()
+		log.Printf("error: tiktok token rejected: %s", data.Error.Message)
+		return nil, ErrTokenExpired
+	}
+	if status != http.StatusOK || (data.Error.Code != "" && data.Error.Code != "ok") {
+		return nil, fmt.Errorf("tiktok api returned status %d: %s", status, data.Error.Message)
+	}
+	return data.Data.Videos, nil
+}
+
+// research posts one video query with the client token
+func (tk *TikTok) research(ctx context.Context, body []byte) (*TikTokResponse, int, error) {
+	token, err := tk.accessToken(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	endpoint := fmt.Sprintf("%s/research/video/query/?fields=%s", tk.baseURL, tiktokVideoFields)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("error: %v", err.Error())
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := tk.httpClient.Do(req)
+	if err != nil {
+		log.Printf("error: %v", err.Error())
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var data TikTokResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, 0, fmt.Errorf("tiktok api returned status %d", resp.StatusCode)
+		}
+		log.Printf("error: %v", err.Error())
+		return nil, 0, err
+	}
+	return &data, resp.StatusCode, nil
+}
+
+// maps a video to the platform metadata
+func (v *TikTokVideo) metadata() *VideoMetadata {
+	return &VideoMetadata{
+		VideoID:    v.Id,
+		Platform:   "tiktok",
+		Title:      v.VideoDescription,
+		ViewCount:  v.ViewCount,
+		LikeCount:  v.LikeCount,
+		ShareCount: v.ShareCount,
+		UploadedAt: time.Unix(v.CreateTime, 0).UTC().Format(time.RFC3339),
+		Status:     VideoAvailable,
+	}
+}
+
+func (tk *TikTok) fetchVideo(ctx context.Context, VideoID string) (*TikTokVideo, error) {
+	created, err := tiktokCreated(VideoID)
+	if err != nil {
+		log.Printf("error: video id invalid %q\n", VideoID)
+		return nil, err
+	}
+	videos, err := tk.queryVideos(ctx, []string{VideoID}, created, created)
+	if err != nil {
+		return nil, err
+	}
+	if len(videos) == 0 {
+		log.Printf("error: %v", "invalid video id")
+		return nil, fmt.Errorf("video not found")
+	}
+	return &videos[0], nil
+}
+
+// Returns the metadata of a TikTok video with its cover image
+func (tk *TikTok) GetVideoDetails(ctx context.Context, VideoID string) (*VideoMetadata, error) {
+	video, err := tk.fetchVideo(ctx, VideoID)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := video.metadata()
+	raw, contentType, err := DownloadFile(video.CoverImageURL)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Thumbnails = Thumbnail{
+		Raw:         raw,
+		ContentType: contentType,
+	}
+	return metadata, nil
+}
+
+func (tk *TikTok) GetVideoDetailsForWorkers(ctx context.Context, VideoID string) (*VideoMetadata, error) {
+	video, err := tk.fetchVideo(ctx, VideoID)
+	if err != nil {
+		return nil, err
+	}
+	return video.metadata(), nil
+}
+
+// GetVideosDetails fetches many videos, up to 20 per API call. The ids are
+// sorted by the day they were posted so each query spans few days.
+// Videos missing from the response are left out of the map.
+func (tk *TikTok) GetVideosDetails(ctx context.Context, VideoIDs []string) (map[string]*VideoMetadata, error) {
+	type posted struct {
+		id      string
+		created time.Time
+	}
+	ids := make([]posted, 0, len(VideoIDs))
+	for _, id := range VideoIDs {
+		created, err := tiktokCreated(id)
+		if err != nil {
+			log.Printf("error: video id invalid %q\n", id)
+			continue
+		}
+		ids = append(ids, posted{id, created})
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a].created.Before(ids[b].created) })
+
+	output := make(map[string]*VideoMetadata, len(VideoIDs))
+	for start := 0; start < len(ids); {
+		from := ids[start].created
+		end := start
+		batch := make([]string, 0, TikTokMaxBatchSize)
+		for end < len(ids) && len(batch) < TikTokMaxBatchSize &&
+			!ids[end].created.After(from.AddDate(0, 0, tiktokQueryDays)) {
+			batch = append(batch, ids[end].id)
+			end++
+		}
+		videos, err := tk.queryVideos(ctx, batch, from, ids[end-1].created)
+		if err != nil {
+			return nil, err
+		}
+		for _, video := range videos {
+			output[video.Id] = video.metadata()
+		}
+		start = end
+	}
+
+	return output, nil
+}
@@ -0,0 +1,174 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TikTok ids start with the unix time the video was posted
+func tiktokID(posted time.Time, n int) string {
+	return strconv.FormatUint(uint64(posted.Unix())<<32|uint64(n), 10)
+}
+
+// Research API stand-in. It hands out client tokens for key:secret, only the
+// latest one is accepted, and knows every video but missingID.
+func mockTikTokAPI(tokens *atomic.Int32, missingID string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cover.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("jpeg"))
+			return
+		case "/oauth/token/":
+			r.ParseForm()
+			if r.Form.Get("grant_type") != "client_credentials" ||
+				r.Form.Get("client_key") != "key" || r.Form.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client","error_description":"Client key or secret is incorrect."}`))
+				return
+			}
+			json.NewEncoder(w).Encode(TikTokToken{
+				AccessToken: fmt.Sprintf("clt.%d", tokens.Add(1)),
+				ExpiresIn:   7200,
+			})
+			return
+		case "/research/video/query/":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var resp TikTokResponse
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer clt.%d", tokens.Load()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			resp.Error.Code = "access_token_invalid"
+			resp.Error.Message = "The access token is invalid or not found in the request."
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		var body struct {
+			Query struct {
+				And []struct {
+					Operation   string   `json:"operation"`
+					FieldName   string   `json:"field_name"`
+					FieldValues []string `json:"field_values"`
+				} `json:"and"`
+			} `json:"query"`
+			StartDate string `json:"start_date"`
+			EndDate   string `json:"end_date"`
+			MaxCount  int    `json:"max_count"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		from, _ := time.Parse("20060102", body.StartDate)
+		to, err := time.Parse("20060102", body.EndDate)
+		if len(body.Query.And) != 1 || body.Query.And[0].Operation != "IN" ||
+			body.Query.And[0].FieldName != "video_id" || err != nil ||
+			to.Before(from) || to.Sub(from) > 30*24*time.Hour ||
+			len(body.Query.And[0].FieldValues) > TikTokMaxBatchSize {
+			w.WriteHeader(http.StatusBadRequest)
+			resp.Error.Code = "invalid_params"
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		for _, id := range body.Query.And[0].FieldValues {
+			created, _ := tiktokCreated(id)
+			if id == missingID || created.Before(from) || created.After(to) {
+				continue
+			}
+			resp.Data.Videos = append(resp.Data.Videos, TikTokVideo{
+				Id:               id,
+				VideoDescription: "clip " + id,
+				CoverImageURL:    server.URL + "/cover.jpg",
+				ViewCount:        900,
+				LikeCount:        80,
+				ShareCount:       7,
+				CreateTime:       1767261600,
+			})
+		}
+		resp.Error.Code = "ok"
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return server
+}
+
+func TestTikTok(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	posted := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	missing := tiktokID(posted, 999)
+	var tokens atomic.Int32
+	server := mockTikTokAPI(&tokens, missing)
+	defer server.Close()
+
+	client := NewTikTokClient("key", "secret")
+	client.baseURL = server.URL
+
+	t.Run("video details", func(t *testing.T) {
+		video, err := client.GetVideoDetails(ctx, tiktokID(posted, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if video.Platform != "tiktok" || video.ViewCount != 900 || video.LikeCount != 80 ||
+			video.ShareCount != 7 || video.UploadedAt != "2026-01-01T10:00:00Z" ||
+			string(video.Thumbnails.Raw) != "jpeg" {
+			t.Fail()
+		}
+	})
+	t.Run("batch spread over months", func(t *testing.T) {
+		ids := []string{missing, "not-a-tiktok-id"}
+		for i := range 45 {
+			ids = append(ids, tiktokID(posted.AddDate(0, 0, -3*i), i))
+		}
+		videos, err := client.GetVideosDetails(ctx, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) != 45 || videos[missing] != nil {
+			t.Fail()
+		}
+		// the client token is reused until it expires
+		if tokens.Load() != 1 {
+			t.Fail()
+		}
+	})
+	t.Run("video not found", func(t *testing.T) {
+		if _, err := client.GetVideoDetailsForWorkers(ctx, missing); err == nil {
+			t.Fail()
+		}
+		if _, err := client.GetVideoDetailsForWorkers(ctx, "abc"); err == nil {
+			t.Fail()
+		}
+	})
+	t.Run("revoked token is renewed", func(t *testing.T) {
+		// another instance asked a newer token
+		tokens.Add(1)
+		if _, err := client.GetVideoDetailsForWorkers(ctx, tiktokID(posted, 1)); err != nil {
+			t.Fatal(err)
+		}
+		if tokens.Load() != 3 {
+			t.Fail()
+		}
+	})
+	t.Run("rejected credentials", func(t *testing.T) {
+		rejected := NewTikTokClient("key", "wrong")
+		rejected.baseURL = server.URL
+		if _, err := rejected.GetVideoDetailsForWorkers(ctx, tiktokID(posted, 1)); err != ErrTokenExpired {
+			t.Fail()
+		}
+	})
+	t.Run("factory", func(t *testing.T) {
+		factory, _ := NewFactory(map[string]string{"tiktok": "key:secret"})
+		if _, err := factory.GetClient("tiktok"); err != nil {
+			t.Fail()
+		}
+	})
+}
@@ -6,11 +6,19 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
+// videos.list accepts at most 50 ids per call
+const YTMaxBatchSize = 50
+
+const ytBaseURL = "https://www.googleapis.com/youtube/v3"
+
 type YTClient struct {
 	APIKey     string
+	baseURL    string
 	httpClient *http.Client
 }
 
@@ -35,9 +43,27 @@ type Snippet struct {
 	UploadedAt string       `json:"publishedAt"`
 }
 
+type YTStatus struct {
+	UploadStatus  string `json:"uploadStatus"`
+	PrivacyStatus string `json:"privacyStatus"`
+}
+
+type YTContentDetails struct {
+	ContentRating struct {
+		YTRating string `json:"ytRating"`
+	} `json:"contentRating"`
+	RegionRestriction struct {
+		Allowed []string `json:"allowed"`
+		Blocked []string `json:"blocked"`
+	} `json:"regionRestriction"`
+}
+
 type Video struct {
-	Details    Snippet `json:"snippet"`
-	Statistics Stats   `json:"statistics"`
+	Id         string           `json:"id"`
+	Details    Snippet          `json:"snippet"`
+	Statistics Stats            `json:"statistics"`
+	Status     YTStatus         `json:"status"`
+	Content    YTContentDetails `json:"contentDetails"`
 }
 
 type YoutubeResponse struct {
@@ -56,14 +82,54 @@ type VideoMetadata struct {
 	Title      string    `json:"title"`
 	ViewCount  int       `json:"view_count"`
 	LikeCount  int       `json:"like_count"`
+	ShareCount int       `json:"share_count,omitempty"`
 	Thumbnails Thumbnail `json:"thumbnails,omitempty"`
 	UploadedAt string    `json:"uploaded_at"`
+	Status     string    `json:"status"` // one of the Video* availability statuses
+}
+
+// availability of the video from its status and content details
+func (v *Video) availability() string {
+	restriction := v.Content.RegionRestriction
+	switch {
+	case v.Status.UploadStatus == "deleted" || v.Status.UploadStatus == "rejected":
+		return VideoDeleted
+	case v.Status.PrivacyStatus == "private":
+		return VideoPrivate
+	case v.Content.ContentRating.YTRating == "ytAgeRestricted":
+		return VideoAgeRestricted
+	case len(restriction.Blocked) > 0 || len(restriction.Allowed) > 0:
+		return VideoRegionBlocked
+	case v.Status.PrivacyStatus == "unlisted":
+		return VideoUnlisted
+	}
+	return VideoAvailable
+}
+
+func init() {
+	Register(&Adapter{
+		Name: "youtube",
+		Matchers: []*regexp.Regexp{
+			regexp.MustCompile(`(?:youtube\.com/(?:watch\?v=|shorts/)|youtu\.be/)([a-zA-Z0-9_-]{11})`),
+		},
+		CanonicalURL: func(VideoID string) string {
+			return "https://www.youtube.com/watch?v=" + VideoID
+		},
+		NewClient: func(key string) Client { return NewYTClient(key) },
+		Capabilities: Capabilities{
+			BatchSize:   YTMaxBatchSize,
+			Thumbnails:  true,
+			QuotaMethod: "videos.list",
+			QuotaCost:   1,
+		},
+	})
 }
 
 // constructor for the YT Client
 func NewYTClient(key string) *YTClient {
 	return &YTClient{
 		APIKey:     key,
+		baseURL:    ytBaseURL,
 		httpClient: &http.Client{},
 	}
 }
@@ -74,8 +140,8 @@ func (yt *YTClient) GetVideoDetails(ctx context.Context, VideoID string) (*Video
 		return nil, fmt.Errorf("invalid video id")
 	}
 	url := fmt.Sprintf(
-		"https://www.googleapis.com/youtube/v3/videos?part=snippet,statistics&id=%s&key=%s",
-		VideoID, yt.APIKey,
+		"%s/videos?part=snippet,statistics,status,contentDetails&id=%s&key=%s",
+		yt.baseURL, VideoID, yt.APIKey,
 	)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -132,6 +198,7 @@ func (yt *YTClient) GetVideoDetails(ctx context.Context, VideoID string) (*Video
 		LikeCount:  likeCount,
 		Thumbnails: thumbs,
 		UploadedAt: video.Details.UploadedAt,
+		Status:     video.availability(),
 	}, nil
 }
 
@@ -141,8 +208,8 @@ func (yt *YTClient) GetVideoDetailsForWorkers(ctx context.Context, VideoID strin
 		return nil, fmt.Errorf("invalid video id")
 	}
 	url := fmt.Sprintf(
-		"https://www.googleapis.com/youtube/v3/videos?part=snippet,statistics&id=%s&key=%s",
-		VideoID, yt.APIKey,
+		"%s/videos?part=snippet,statistics,status,contentDetails&id=%s&key=%s",
+		yt.baseURL, VideoID, yt.APIKey,
 	)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -185,5 +252,66 @@ func (yt *YTClient) GetVideoDetailsForWorkers(ctx context.Context, VideoID strin
 		ViewCount:  viewCount,
 		LikeCount:  likeCount,
 		UploadedAt: video.Details.UploadedAt,
+		Status:     video.availability(),
 	}, nil
 }
+
+// GetVideosDetails fetches the stats of many videos, up to 50 per API call.
+// videos.list answers with every video it can still serve, the ids it leaves
+// out of a successful response were removed or made private and are reported
+// deleted.
+func (yt *YTClient) GetVideosDetails(ctx context.Context, VideoIDs []string) (map[string]*VideoMetadata, error) {
+	output := make(map[string]*VideoMetadata, len(VideoIDs))
+	for start := 0; start < len(VideoIDs); start += YTMaxBatchSize {
+		end := min(start+YTMaxBatchSize, len(VideoIDs))
+		url := fmt.Sprintf(
+			"%s/videos?part=snippet,statistics,status,contentDetails&id=%s&key=%s",
+			yt.baseURL, strings.Join(VideoIDs[start:end], ","), yt.APIKey,
+		)
+
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			log.Printf("error: %v", err.Error())
+			return nil, err
+		}
+
+		resp, err := yt.httpClient.Do(req)
+		if err != nil {
+			log.Printf("error: %v", err.Error())
+			return nil, err
+		}
+		var data YoutubeResponse
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("youtube api returned status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("error: %v", err.Error())
+			return nil, err
+		}
+
+		for _, video := range data.Items {
+			// Convert string counts to integers
+			viewCount, _ := strconv.Atoi(video.Statistics.ViewCount)
+			likeCount, _ := strconv.Atoi(video.Statistics.LikeCount)
+			output[video.Id] = &VideoMetadata{
+				VideoID:    video.Id,
+				Platform:   "youtube",
+				Title:      video.Details.Title,
+				ViewCount:  viewCount,
+				LikeCount:  likeCount,
+				UploadedAt: video.Details.UploadedAt,
+				Status:     video.availability(),
+			}
+		}
+		for _, id := range VideoIDs[start:end] {
+			if _, ok := output[id]; !ok {
+				output[id] = &VideoMetadata{VideoID: id, Platform: "youtube", Status: VideoDeleted}
+			}
+		}
+	}
+
+	return output, nil
+}
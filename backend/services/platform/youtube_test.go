@@ -2,7 +2,12 @@ package platform
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,7 +19,7 @@ func TestYoutubeAPI(t *testing.T) {
 
 	defer cancel()
 
-	client, err := NewFactory(env.GetString("YTAPIKEY", ""), "")
+	client, err := NewFactory(map[string]string{"youtube": env.GetString("YTAPIKEY", "")})
 	if err != nil {
 		t.Fail()
 	}
@@ -51,3 +56,92 @@ func TestYoutubeAPI(t *testing.T) {
 		}
 	})
 }
+
+func TestYoutubeBatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		ids := strings.Split(r.URL.Query().Get("id"), ",")
+		if len(ids) > YTMaxBatchSize {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var resp YoutubeResponse
+		for _, id := range ids {
+			if id == "missing" {
+				continue
+			}
+			video := Video{Id: id}
+			video.Statistics.ViewCount = "1000"
+			switch id {
+			case "private":
+				video.Status.PrivacyStatus = "private"
+			case "unlisted":
+				video.Status.PrivacyStatus = "unlisted"
+			case "adult":
+				video.Content.ContentRating.YTRating = "ytAgeRestricted"
+			case "blocked":
+				video.Content.RegionRestriction.Blocked = []string{"DE"}
+			}
+			resp.Items = append(resp.Items, video)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewYTClient("key")
+	client.baseURL = server.URL
+
+	t.Run("chunks of 50", func(t *testing.T) {
+		calls = 0
+		ids := make([]string, 0, 120)
+		for i := range 120 {
+			ids = append(ids, fmt.Sprintf("video%d", i))
+		}
+		videos, err := client.GetVideosDetails(ctx, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != 3 || len(videos) != 120 {
+			log.Printf("calls: %d, videos: %d\n", calls, len(videos))
+			t.Fail()
+		}
+		if videos["video7"].ViewCount != 1000 {
+			t.Fail()
+		}
+	})
+	t.Run("missing videos are reported deleted", func(t *testing.T) {
+		videos, err := client.GetVideosDetails(ctx, []string{"video1", "missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) != 2 || videos["missing"].Status != VideoDeleted || videos["video1"].Status != VideoAvailable {
+			t.Fail()
+		}
+	})
+	t.Run("availability", func(t *testing.T) {
+		videos, err := client.GetVideosDetails(ctx, []string{"video1", "private", "unlisted", "adult", "blocked"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]string{
+			"video1":   VideoAvailable,
+			"private":  VideoPrivate,
+			"unlisted": VideoUnlisted,
+			"adult":    VideoAgeRestricted,
+			"blocked":  VideoRegionBlocked,
+		}
+		for id, status := range expected {
+			if videos[id].Status != status {
+				log.Printf("%s: %s, expected %s\n", id, videos[id].Status, status)
+				t.Fail()
+			}
+		}
+		if !Unavailable(VideoPrivate) || Unavailable(VideoRegionBlocked) || Unavailable(VideoUnlisted) {
+			t.Fail()
+		}
+	})
+}